@@ -0,0 +1,186 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// 地址与Cosmos SDK的账户地址格式一致：20字节的公钥哈希 RIPEMD160(SHA256(压缩公钥))，
+// 以bech32编码（BIP-173），前缀为cosmos。bech32自带校验和，输错字符的地址会被拒绝。
+
+const (
+	AddressPrefix  = "cosmos" // 账户地址的bech32前缀
+	addressLength  = 20       // 地址中的哈希长度（字节）
+	bech32Charset  = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32MaxLen   = 90
+	bech32Checksum = 6
+)
+
+// addressOf 由压缩公钥计算账户地址，公钥格式无效时返回空字符串
+func addressOf(publicKey string) string {
+	raw, err := hex.DecodeString(publicKey)
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	sha := sha256.Sum256(raw)
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return encodeAddress(hasher.Sum(nil))
+}
+
+// encodeAddress 把20字节的哈希编码为bech32地址
+func encodeAddress(hash []byte) string {
+	address, err := bech32Encode(AddressPrefix, hash)
+	if err != nil {
+		// 只有前缀或数据长度不合法时才会出错，这里两者都是固定的
+		panic(err)
+	}
+	return address
+}
+
+// ValidateAddress 检查地址是否是前缀为cosmos、校验和正确的20字节小写bech32地址
+func ValidateAddress(address string) error {
+	if address == "" {
+		return fmt.Errorf("地址不能为空")
+	}
+	// 全大写的bech32也能通过校验和，但账户以地址字符串为键，只接受小写形式，
+	// 否则同一个地址的两种写法会对应两个不同的账户
+	if address != strings.ToLower(address) {
+		return fmt.Errorf("地址 %s 必须使用小写形式", address)
+	}
+	prefix, hash, err := bech32Decode(address)
+	if err != nil {
+		return fmt.Errorf("地址 %s 无效: %v", address, err)
+	}
+	if prefix != AddressPrefix {
+		return fmt.Errorf("地址 %s 的前缀应为 %s", address, AddressPrefix)
+	}
+	if len(hash) != addressLength {
+		return fmt.Errorf("地址 %s 的长度无效", address)
+	}
+	return nil
+}
+
+// bech32Encode 按BIP-173编码数据
+func bech32Encode(hrp string, data []byte) (string, error) {
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	if hrp == "" || len(hrp)+1+len(values)+bech32Checksum > bech32MaxLen {
+		return "", fmt.Errorf("前缀或数据长度无效")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range append(values, bech32CreateChecksum(hrp, values)...) {
+		sb.WriteByte(bech32Charset[v])
+	}
+	return sb.String(), nil
+}
+
+// bech32Decode 按BIP-173解码并验证校验和，返回前缀和数据
+func bech32Decode(s string) (string, []byte, error) {
+	if len(s) > bech32MaxLen {
+		return "", nil, fmt.Errorf("长度超过%d个字符", bech32MaxLen)
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, fmt.Errorf("不能混用大小写")
+	}
+	s = strings.ToLower(s)
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+1+bech32Checksum > len(s) {
+		return "", nil, fmt.Errorf("缺少分隔符或校验和")
+	}
+	hrp := s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("前缀包含无效字符")
+		}
+	}
+	values := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("包含无效字符 %q", s[i])
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(append(bech32ExpandHRP(hrp), values...)) != 1 {
+		return "", nil, fmt.Errorf("校验和错误")
+	}
+
+	data, err := convertBits(values[:len(values)-bech32Checksum], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32CreateChecksum(hrp string, values []byte) []byte {
+	polymod := bech32Polymod(append(append(bech32ExpandHRP(hrp), values...), make([]byte, bech32Checksum)...)) ^ 1
+	checksum := make([]byte, bech32Checksum)
+	for i := range checksum {
+		checksum[i] = byte(polymod>>uint(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// convertBits 在不同位宽的分组之间转换，用于8位字节和5位bech32字符之间的转换
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxValue := uint(1)<<to - 1
+	out := make([]byte, 0, len(data)*int(from)/int(to)+1)
+	for _, b := range data {
+		if uint(b)>>from != 0 {
+			return nil, fmt.Errorf("数据超出%d位", from)
+		}
+		acc = acc<<from | uint(b)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(to-bits)&maxValue))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("填充位无效")
+	}
+	return out, nil
+}
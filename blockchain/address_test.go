@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+// testAddress 由名字生成一个有效的测试地址
+func testAddress(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return encodeAddress(hash[:addressLength])
+}
+
+func TestBech32Vectors(t *testing.T) {
+	// BIP-173 中的有效编码
+	for _, s := range []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	} {
+		hrp, data, err := bech32Decode(s)
+		if err != nil {
+			t.Fatalf("%s 应能解码: %v", s, err)
+		}
+		if encoded, _ := bech32Encode(hrp, data); encoded != strings.ToLower(s) {
+			t.Fatalf("重新编码 %s 得到 %s", s, encoded)
+		}
+	}
+
+	for _, s := range []string{"A12uEL5L", "a12uel5m", "1nwldj5", "pzry9x0s0muk", "abc1", "x1b4n0q5v"} {
+		if _, _, err := bech32Decode(s); err == nil {
+			t.Fatalf("%s 应被拒绝", s)
+		}
+	}
+}
+
+func TestAddressValidation(t *testing.T) {
+	wallet, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(wallet.Address, AddressPrefix+"1") || len(wallet.Address) != 45 {
+		t.Fatalf("钱包地址 = %s", wallet.Address)
+	}
+	if err := ValidateAddress(wallet.Address); err != nil {
+		t.Fatal(err)
+	}
+
+	// 改动任意一个字符都会导致校验和错误
+	typo := []byte(wallet.Address)
+	last := len(typo) - 1
+	typo[last] = bech32Charset[(strings.IndexByte(bech32Charset, typo[last])+1)%len(bech32Charset)]
+	other, _ := bech32Encode("osmo", make([]byte, addressLength))
+	short, _ := bech32Encode(AddressPrefix, make([]byte, 10))
+	// 全大写的写法虽然是有效的bech32，但会对应另一个账户
+	upper := strings.ToUpper(wallet.Address)
+	for _, address := range []string{"", "cosmos-bob", string(typo), other, short, upper} {
+		if err := ValidateAddress(address); err == nil {
+			t.Fatalf("地址 %q 应被拒绝", address)
+		}
+	}
+}
+
+func TestTransferRejectsMalformedRecipient(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	bob := testAddress("bob")
+	typo := bob[:len(bob)-1] + "q"
+	if typo == bob {
+		typo = bob[:len(bob)-1] + "p"
+	}
+	if _, err := node.Transfer(alice.Address, typo, 100, 3); err == nil {
+		t.Fatal("校验和错误的接收方地址应被拒绝")
+	}
+	if _, err := node.Transfer(alice.Address, bob, 100, 3); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+	if node.GetBalance(bob) != 100 || node.walletManager.GetWallet(typo) != nil {
+		t.Fatal("只有地址正确的接收方应收到转账")
+	}
+}
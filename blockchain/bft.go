@@ -0,0 +1,850 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// VoteType 投票类型
+type VoteType string
+
+const (
+	VotePrevote   VoteType = "prevote"   // 预投票
+	VotePrecommit VoteType = "precommit" // 预提交
+)
+
+// Vote 是验证者在某个高度和轮次上的签名投票，BlockHash为空表示投给nil
+type Vote struct {
+	Type      VoteType `json:"type"`
+	Height    int      `json:"height"`
+	Round     int      `json:"round"`
+	BlockHash string   `json:"block_hash"`
+	Validator string   `json:"validator"`
+	Signature string   `json:"signature"`
+}
+
+// SignBytes 返回投票中需要签名的内容
+func (v *Vote) SignBytes() []byte {
+	data, _ := json.Marshal(struct {
+		Type      VoteType `json:"type"`
+		Height    int      `json:"height"`
+		Round     int      `json:"round"`
+		BlockHash string   `json:"block_hash"`
+	}{v.Type, v.Height, v.Round, v.BlockHash})
+	return data
+}
+
+// CommitCertificate 是区块的提交证明：同一轮中超过2/3验证者对该区块的预提交
+type CommitCertificate struct {
+	Height     int     `json:"height"`
+	Round      int     `json:"round"`
+	BlockHash  string  `json:"block_hash"`
+	Precommits []*Vote `json:"precommits"`
+}
+
+// BFTConfig BFT共识的时间参数
+type BFTConfig struct {
+	BlockTime        time.Duration // 提交区块后等待多久开始下一个高度
+	TimeoutPropose   time.Duration // 等待提议的时间
+	TimeoutPrevote   time.Duration // 预投票后等待其他预投票的时间
+	TimeoutPrecommit time.Duration // 预提交后等待其他预提交的时间
+	TimeoutDelta     time.Duration // 每进入新的一轮，各超时增加的时间
+}
+
+// DefaultBFTConfig 返回默认的BFT时间参数
+func DefaultBFTConfig() BFTConfig {
+	return BFTConfig{
+		BlockTime:        10 * time.Second,
+		TimeoutPropose:   3 * time.Second,
+		TimeoutPrevote:   time.Second,
+		TimeoutPrecommit: time.Second,
+		TimeoutDelta:     500 * time.Millisecond,
+	}
+}
+
+// bftInboxSize 每个验证者的消息队列长度，队列满时丢弃消息
+const bftInboxSize = 1024
+
+// bftMessage 验证者之间传递的消息：提议或投票
+//
+// 提议的区块可能是之前某轮由其他验证者创建并被锁定的区块，所以单独记录发出提议的验证者。
+// 进程内的通道无法伪造发送者，提议消息本身不再签名。
+type bftMessage struct {
+	Proposal *Block // 提议的区块
+	Proposer string // 发出提议的验证者
+	Round    int    // 提议所在的轮次
+	Vote     *Vote
+	Resend   bool // 有验证者上线，请求重发当前高度的提议和投票
+}
+
+// height 返回消息所属的高度
+func (m bftMessage) height() int {
+	if m.Vote != nil {
+		return m.Vote.Height
+	}
+	return m.Proposal.Height
+}
+
+// BFTNetwork 用通道连接同一进程内的BFT验证者
+//
+// 消息发送给所有正在运行的验证者（包括发送者自己），停止出块的验证者相当于离线，
+// 收不到任何消息，但其他验证者仍可以从它同步已提交的区块。
+type BFTNetwork struct {
+	mu      sync.RWMutex
+	peers   map[string]*BFTEngine // 正在运行的验证者，按验证者公钥索引
+	members map[*Node]bool        // 加入过网络的所有节点，用于同步区块
+}
+
+// NewBFTNetwork 创建进程内的BFT网络
+func NewBFTNetwork() *BFTNetwork {
+	return &BFTNetwork{
+		peers:   make(map[string]*BFTEngine),
+		members: make(map[*Node]bool),
+	}
+}
+
+// attach 把节点加入网络，其他验证者可以从它同步区块
+func (net *BFTNetwork) attach(n *Node) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.members[n] = true
+}
+
+// join 验证者上线，并请求其他验证者重发它错过的提议和投票
+func (net *BFTNetwork) join(e *BFTEngine) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.peers[e.node.validator] = e
+	net.members[e.node] = true
+	for _, peer := range net.peers {
+		if peer == e {
+			continue
+		}
+		select {
+		case peer.inbox <- bftMessage{Resend: true}:
+		default:
+		}
+	}
+}
+
+// leave 验证者离线
+func (net *BFTNetwork) leave(e *BFTEngine) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	if net.peers[e.node.validator] == e {
+		delete(net.peers, e.node.validator)
+	}
+}
+
+// broadcast 把消息放入所有在线验证者的队列
+func (net *BFTNetwork) broadcast(msg bftMessage) {
+	net.mu.RLock()
+	defer net.mu.RUnlock()
+	for _, peer := range net.peers {
+		select {
+		case peer.inbox <- msg:
+		default:
+		}
+	}
+}
+
+// nodes 返回加入过网络的所有节点
+func (net *BFTNetwork) nodes() []*Node {
+	net.mu.RLock()
+	defer net.mu.RUnlock()
+	result := make([]*Node, 0, len(net.members))
+	for node := range net.members {
+		result = append(result, node)
+	}
+	return result
+}
+
+// BFTEngine 是Tendermint风格的拜占庭容错共识引擎
+//
+// 每个高度分若干轮进行，每轮由轮值提议者提议区块，验证者依次发送预投票和预提交。
+// 区块在某一轮获得超过2/3验证者的预提交后提交，这些预提交作为提交证明保存在区块中。
+// 提议者离线或投票没有形成多数时，超时后进入下一轮，由下一个验证者提议。
+// 网络只把消息发给在线的验证者，队列满时也会丢弃消息，所以投票后总会安排超时：
+// 到期时还没有收到足够的投票，就重发提议和本节点的投票，继续等待。
+// 预投票中形成多数的区块会被锁定，之后的轮次只会对它投票，从而保证不会提交两个冲突的区块。
+type BFTEngine struct {
+	validators *ValidatorSet
+	network    *BFTNetwork
+	cfg        BFTConfig
+	inbox      chan bftMessage
+	node       *Node // 运行中的节点，只在Run期间有效
+}
+
+// NewBFTEngine 创建加入network的BFT共识引擎
+func NewBFTEngine(validators *ValidatorSet, network *BFTNetwork, cfg BFTConfig) *BFTEngine {
+	defaults := DefaultBFTConfig()
+	if cfg.BlockTime <= 0 {
+		cfg.BlockTime = defaults.BlockTime
+	}
+	if cfg.TimeoutPropose <= 0 {
+		cfg.TimeoutPropose = defaults.TimeoutPropose
+	}
+	if cfg.TimeoutPrevote <= 0 {
+		cfg.TimeoutPrevote = defaults.TimeoutPrevote
+	}
+	if cfg.TimeoutPrecommit <= 0 {
+		cfg.TimeoutPrecommit = defaults.TimeoutPrecommit
+	}
+	if cfg.TimeoutDelta < 0 {
+		cfg.TimeoutDelta = 0
+	}
+	return &BFTEngine{
+		validators: validators,
+		network:    network,
+		cfg:        cfg,
+		inbox:      make(chan bftMessage, bftInboxSize),
+	}
+}
+
+// Name 返回引擎名称
+func (e *BFTEngine) Name() string {
+	return "bft"
+}
+
+// Prepare 创世区块由第一个验证者创建，其余区块由共识过程决定提议者
+func (e *BFTEngine) Prepare(block, parent *Block, lookup func(hash string) *Block) error {
+	if parent == nil {
+		if isDocumentGenesis(block) {
+			return nil
+		}
+		if proposer := e.proposer(1, 0); block.Validator != proposer {
+			return fmt.Errorf("创世区块应由第一个验证者 %s 创建", shortKey(proposer))
+		}
+	}
+	return nil
+}
+
+// Seal 直接计算区块哈希
+func (e *BFTEngine) Seal(block *Block, abort func() bool) bool {
+	block.Hash = calculateBlockHash(block)
+	return true
+}
+
+// VerifyBlock 除创世区块外，区块必须由验证者签名并带有有效的提交证明
+func (e *BFTEngine) VerifyBlock(block, parent *Block, lookup func(hash string) *Block) error {
+	if err := verifyNoProofOfWork(block); err != nil {
+		return err
+	}
+	if parent == nil {
+		if block.Commit != nil {
+			return fmt.Errorf("创世区块不应包含提交证明")
+		}
+		if isDocumentGenesis(block) {
+			return nil
+		}
+		if proposer := e.proposer(1, 0); block.Validator != proposer {
+			return fmt.Errorf("创世区块应由第一个验证者 %s 创建", shortKey(proposer))
+		}
+		return nil
+	}
+
+	commit := block.Commit
+	if commit == nil {
+		return fmt.Errorf("缺少提交证明")
+	}
+	if commit.Height != block.Height || commit.BlockHash != block.Hash {
+		return fmt.Errorf("提交证明与区块不符")
+	}
+	if !e.validators.Contains(block.Validator) {
+		return fmt.Errorf("区块签名者 %s 不是验证者", shortKey(block.Validator))
+	}
+
+	signers := make(map[string]bool, len(commit.Precommits))
+	for _, vote := range commit.Precommits {
+		if vote.Type != VotePrecommit || vote.Height != commit.Height || vote.Round != commit.Round || vote.BlockHash != commit.BlockHash {
+			return fmt.Errorf("提交证明中包含不匹配的投票")
+		}
+		if signers[vote.Validator] {
+			return fmt.Errorf("验证者 %s 重复预提交", shortKey(vote.Validator))
+		}
+		if err := e.verifyVote(vote); err != nil {
+			return err
+		}
+		signers[vote.Validator] = true
+	}
+	if !e.hasQuorum(len(signers)) {
+		return fmt.Errorf("预提交不足：%d/%d，需要超过2/3", len(signers), e.validators.Size())
+	}
+	return nil
+}
+
+// BlockWeight 提交的区块不会被回滚，每个区块的权重相同
+func (e *BFTEngine) BlockWeight(block *Block) uint64 {
+	return 1
+}
+
+// proposer 返回指定高度和轮次的提议者，每个高度从不同的验证者开始轮换
+func (e *BFTEngine) proposer(height, round int) string {
+	return e.validators.Proposer(height + round)
+}
+
+// hasQuorum 判断count个验证者是否超过2/3
+func (e *BFTEngine) hasQuorum(count int) bool {
+	return count*3 > e.validators.Size()*2
+}
+
+// verifyVote 检查投票来自验证者集合并且签名有效
+func (e *BFTEngine) verifyVote(vote *Vote) error {
+	if !e.validators.Contains(vote.Validator) {
+		return fmt.Errorf("%s 不是验证者", shortKey(vote.Validator))
+	}
+	if err := VerifySignature(vote.Validator, vote.SignBytes(), vote.Signature); err != nil {
+		return fmt.Errorf("验证者 %s 的投票签名无效: %v", shortKey(vote.Validator), err)
+	}
+	return nil
+}
+
+// Run 加入网络并参与共识，直到stop被关闭
+func (e *BFTEngine) Run(n *Node, stop <-chan struct{}) {
+	e.node = n
+	e.network.join(e)
+	defer e.network.leave(e)
+
+	s := &bftState{
+		engine:   e,
+		node:     n,
+		stop:     stop,
+		timeouts: make(chan bftTimeout, 16),
+	}
+	s.startHeight()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case msg := <-e.inbox:
+			s.handleMessage(msg)
+		case t := <-s.timeouts:
+			s.handleTimeout(t)
+		}
+	}
+}
+
+// bftStep 一轮中的阶段
+type bftStep int
+
+const (
+	stepPropose   bftStep = iota // 等待提议
+	stepPrevote                  // 已预投票
+	stepPrecommit                // 已预提交
+	stepCommit                   // 已提交，等待开始下一个高度
+)
+
+// bftTimeout 到期的超时
+type bftTimeout struct {
+	height int
+	round  int
+	step   bftStep
+}
+
+// bftState 是一个验证者的共识状态，只在Run的goroutine中访问
+type bftState struct {
+	engine   *BFTEngine
+	node     *Node
+	stop     <-chan struct{}
+	timeouts chan bftTimeout
+
+	height     int
+	round      int
+	step       bftStep
+	proposals  map[int]*Block           // 按轮次保存收到的提议
+	prevotes   map[int]map[string]*Vote // 按轮次、验证者保存预投票
+	precommits map[int]map[string]*Vote // 按轮次、验证者保存预提交
+	scheduled  map[bftTimeout]bool      // 已安排的超时，每种超时每轮只安排一次
+
+	lockedRound int    // 锁定区块的轮次，-1表示没有锁定
+	lockedBlock *Block // 预投票中形成多数的区块
+	proposed    *Block // 本节点提议的区块
+	failed      []*FailedTransaction
+}
+
+// startHeight 同步落后的区块，然后从第0轮开始下一个高度
+func (s *bftState) startHeight() {
+	s.catchUp()
+
+	height := s.node.GetHeight()
+	if height == 0 {
+		// 还没有创世区块，稍后再从其他验证者同步
+		s.height = 0
+		s.step = stepCommit
+		s.schedule(bftTimeout{height: 0, step: stepCommit}, time.Second)
+		return
+	}
+
+	s.height = height + 1
+	s.proposals = make(map[int]*Block)
+	s.prevotes = make(map[int]map[string]*Vote)
+	s.precommits = make(map[int]map[string]*Vote)
+	s.scheduled = make(map[bftTimeout]bool)
+	s.lockedRound = -1
+	s.lockedBlock = nil
+	s.proposed = nil
+	s.failed = nil
+	s.startRound(0)
+}
+
+// startRound 进入新的一轮，轮到本节点时发出提议
+func (s *bftState) startRound(round int) {
+	s.round = round
+	s.step = stepPropose
+
+	if s.engine.proposer(s.height, round) == s.node.validator {
+		block := s.lockedBlock
+		if block == nil {
+			if s.proposed == nil {
+				proposed, failed, err := s.node.buildProposal()
+				if err != nil {
+					log.Printf("构造高度 %d 的提议失败: %v", s.height, err)
+				}
+				s.proposed, s.failed = proposed, failed
+			}
+			block = s.proposed
+		}
+		if block != nil {
+			s.engine.network.broadcast(bftMessage{Proposal: block, Proposer: s.node.validator, Round: round})
+		}
+	}
+	s.schedule(bftTimeout{height: s.height, round: round, step: stepPropose}, s.engine.cfg.TimeoutPropose)
+
+	// 处理提前收到的本轮消息
+	if s.proposals[round] != nil {
+		s.prevote()
+	}
+	s.checkVotes(round)
+}
+
+// handleMessage 处理收到的提议或投票
+func (s *bftState) handleMessage(msg bftMessage) {
+	if msg.Resend {
+		s.resend()
+		return
+	}
+	if height := msg.height(); height != s.height {
+		if height < s.height {
+			return
+		}
+		// 其他验证者已经进入更高的高度，说明本节点落后了
+		s.startHeight()
+		if height != s.height {
+			return
+		}
+	}
+
+	if msg.Vote != nil {
+		s.handleVote(msg.Vote)
+	} else {
+		s.handleProposal(msg.Proposal, msg.Proposer, msg.Round)
+	}
+}
+
+// handleProposal 检查并记录提议，当前轮次的提议会触发预投票
+func (s *bftState) handleProposal(block *Block, proposer string, round int) {
+	if s.proposals[round] != nil || proposer != s.engine.proposer(block.Height, round) {
+		return
+	}
+	if !s.engine.validators.Contains(block.Validator) {
+		return
+	}
+	if err := s.node.checkProposal(block); err != nil {
+		log.Printf("拒绝高度 %d 第 %d 轮的提议: %v", block.Height, round, err)
+		return
+	}
+
+	s.proposals[round] = block
+	if round == s.round && s.step == stepPropose {
+		s.prevote()
+	}
+	s.checkVotes(round)
+}
+
+// handleVote 检查并记录投票
+func (s *bftState) handleVote(vote *Vote) {
+	votes := s.prevotes
+	if vote.Type == VotePrecommit {
+		votes = s.precommits
+	}
+	if votes[vote.Round][vote.Validator] != nil {
+		return
+	}
+	if err := s.engine.verifyVote(vote); err != nil {
+		log.Printf("丢弃投票: %v", err)
+		return
+	}
+
+	if votes[vote.Round] == nil {
+		votes[vote.Round] = make(map[string]*Vote)
+	}
+	votes[vote.Round][vote.Validator] = vote
+	s.checkVotes(vote.Round)
+}
+
+// checkVotes 根据round轮的投票推进状态
+func (s *bftState) checkVotes(round int) {
+	if s.step == stepCommit {
+		return
+	}
+
+	// 任意一轮中超过2/3预提交同一个区块：提交
+	if hash, ok := s.majority(s.precommits[round]); ok && hash != "" {
+		if block := s.proposals[round]; block != nil && block.Hash == hash {
+			s.commit(block, round)
+			return
+		}
+	}
+
+	// 其他验证者已经在更高的轮次形成多数，跟上它们
+	if round > s.round && (s.engine.hasQuorum(len(s.prevotes[round])) || s.engine.hasQuorum(len(s.precommits[round]))) {
+		s.startRound(round)
+		return
+	}
+	if round != s.round {
+		return
+	}
+
+	if s.step == stepPrevote {
+		if hash, ok := s.majority(s.prevotes[round]); ok {
+			block := s.proposals[round]
+			switch {
+			case hash == "":
+				s.precommit("")
+			case block != nil && block.Hash == hash:
+				// 锁定多数预投票的区块
+				s.lockedRound = round
+				s.lockedBlock = block
+				s.precommit(hash)
+			}
+		} else if s.engine.hasQuorum(len(s.prevotes[round])) {
+			s.schedule(bftTimeout{height: s.height, round: round, step: stepPrevote}, s.engine.cfg.TimeoutPrevote)
+		}
+	}
+	if s.step >= stepPrevote && s.engine.hasQuorum(len(s.precommits[round])) {
+		s.schedule(bftTimeout{height: s.height, round: round, step: stepPrecommit}, s.engine.cfg.TimeoutPrecommit)
+	}
+}
+
+// majority 返回获得超过2/3投票的区块哈希
+func (s *bftState) majority(votes map[string]*Vote) (string, bool) {
+	counts := make(map[string]int)
+	for _, vote := range votes {
+		counts[vote.BlockHash]++
+		if s.engine.hasQuorum(counts[vote.BlockHash]) {
+			return vote.BlockHash, true
+		}
+	}
+	return "", false
+}
+
+// prevote 对本轮提议预投票：已锁定其他区块或没有提议时投nil
+func (s *bftState) prevote() {
+	hash := ""
+	if block := s.proposals[s.round]; block != nil {
+		if s.lockedBlock == nil || s.lockedBlock.Hash == block.Hash {
+			hash = block.Hash
+		}
+	}
+	s.step = stepPrevote
+	s.vote(VotePrevote, hash)
+	s.schedule(bftTimeout{height: s.height, round: s.round, step: stepPrevote}, s.engine.cfg.TimeoutPrevote)
+}
+
+// precommit 预提交hash，为空表示投nil
+func (s *bftState) precommit(hash string) {
+	s.step = stepPrecommit
+	s.vote(VotePrecommit, hash)
+	s.schedule(bftTimeout{height: s.height, round: s.round, step: stepPrecommit}, s.engine.cfg.TimeoutPrecommit)
+}
+
+// resend 重发本轮的提议和本节点在当前高度的所有投票，弥补其他验证者丢失的消息
+func (s *bftState) resend() {
+	if block := s.proposals[s.round]; block != nil {
+		s.engine.network.broadcast(bftMessage{Proposal: block, Proposer: s.engine.proposer(s.height, s.round), Round: s.round})
+	}
+	for _, votes := range []map[int]map[string]*Vote{s.prevotes, s.precommits} {
+		for _, byValidator := range votes {
+			if vote := byValidator[s.node.validator]; vote != nil {
+				s.engine.network.broadcast(bftMessage{Vote: vote})
+			}
+		}
+	}
+}
+
+// vote 签名并广播投票
+func (s *bftState) vote(voteType VoteType, hash string) {
+	vote := &Vote{
+		Type:      voteType,
+		Height:    s.height,
+		Round:     s.round,
+		BlockHash: hash,
+		Validator: s.node.validator,
+	}
+	signature, err := SignBytes(s.node.validatorKey, vote.SignBytes())
+	if err != nil {
+		log.Printf("投票签名失败: %v", err)
+		return
+	}
+	vote.Signature = signature
+	s.engine.network.broadcast(bftMessage{Vote: vote})
+}
+
+// commit 把带有提交证明的区块写入本节点的链，等待BlockTime后开始下一个高度
+func (s *bftState) commit(block *Block, round int) {
+	certificate := &CommitCertificate{
+		Height:    block.Height,
+		Round:     round,
+		BlockHash: block.Hash,
+	}
+	for _, vote := range s.precommits[round] {
+		if vote.BlockHash == block.Hash {
+			certificate.Precommits = append(certificate.Precommits, vote)
+		}
+	}
+
+	// 提议在验证者之间共享，提交时复制一份再附上本节点收集的证明
+	decided := *block
+	decided.Commit = certificate
+	var failed []*FailedTransaction
+	if s.proposed != nil && s.proposed.Hash == block.Hash {
+		failed = s.failed
+	}
+	if err := s.node.commitDecidedBlock(&decided, failed); err != nil && !errors.Is(err, errKnownBlock) {
+		log.Printf("提交区块 %d 失败: %v", block.Height, err)
+	}
+
+	s.step = stepCommit
+	s.schedule(bftTimeout{height: s.height, step: stepCommit}, s.engine.cfg.BlockTime)
+}
+
+// handleTimeout 处理到期的超时
+func (s *bftState) handleTimeout(t bftTimeout) {
+	if t.height != s.height {
+		return
+	}
+	switch t.step {
+	case stepCommit:
+		if s.step == stepCommit {
+			s.startHeight()
+		}
+	case stepPropose:
+		if t.round == s.round && s.step == stepPropose {
+			s.prevote()
+		}
+	case stepPrevote:
+		if t.round != s.round || s.step != stepPrevote {
+			return
+		}
+		if s.engine.hasQuorum(len(s.prevotes[t.round])) {
+			s.precommit("")
+			return
+		}
+		s.resend()
+		s.reschedule(t, s.engine.cfg.TimeoutPrevote)
+	case stepPrecommit:
+		if t.round != s.round || s.step == stepCommit {
+			return
+		}
+		if s.engine.hasQuorum(len(s.precommits[t.round])) {
+			s.startRound(s.round + 1)
+			return
+		}
+		s.resend()
+		s.reschedule(t, s.engine.cfg.TimeoutPrecommit)
+	}
+}
+
+// reschedule 重新安排已经到期的超时
+func (s *bftState) reschedule(t bftTimeout, d time.Duration) {
+	delete(s.scheduled, t)
+	s.schedule(t, d)
+}
+
+// schedule 安排一个超时，轮次越高等待越久
+func (s *bftState) schedule(t bftTimeout, d time.Duration) {
+	if t.step != stepCommit {
+		if s.scheduled[t] {
+			return
+		}
+		s.scheduled[t] = true
+		d += time.Duration(t.round) * s.engine.cfg.TimeoutDelta
+	}
+
+	time.AfterFunc(d, func() {
+		select {
+		case s.timeouts <- t:
+		case <-s.stop:
+		}
+	})
+}
+
+// catchUp 从网络中的其他节点同步本节点缺少的已提交区块
+func (s *bftState) catchUp() {
+	for _, peer := range s.engine.network.nodes() {
+		if peer == s.node || peer.GetHeight() <= s.node.GetHeight() {
+			continue
+		}
+		for {
+			blocks := peer.GetBlocksAfter(s.node.BlockLocator(), syncBatchSize)
+			if len(blocks) == 0 {
+				break
+			}
+			for _, block := range blocks {
+				if err := s.node.AddPeerBlock(block); err != nil && !errors.Is(err, errKnownBlock) {
+					log.Printf("同步区块 %d 失败: %v", block.Height, err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// buildProposal 加锁构造、封装并签名候选区块，供先投票再提交区块的共识引擎使用
+func (n *Node) buildProposal() (*Block, []*FailedTransaction, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	block, failed := n.buildBlock()
+	if block == nil {
+		return nil, nil, fmt.Errorf("无法构造区块")
+	}
+	if !n.engine.Seal(block, func() bool { return false }) {
+		return nil, nil, fmt.Errorf("封装区块失败")
+	}
+	signature, err := n.signBlock(block)
+	if err != nil {
+		return nil, nil, fmt.Errorf("签名失败: %v", err)
+	}
+	block.Signature = signature
+	return block, failed, nil
+}
+
+// checkProposal 检查提议的区块能否接在主链末尾（还没有提交证明）
+func (n *Node) checkProposal(block *Block) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tip := n.tip()
+	if tip == nil || block.PrevHash != tip.Hash {
+		return fmt.Errorf("提议的区块没有接在链尾之后")
+	}
+	if err := validateBlock(block, tip); err != nil {
+		return err
+	}
+	if err := verifyNoProofOfWork(block); err != nil {
+		return err
+	}
+	_, err := verifyBlockTransactions(n.walletManager, n.includedTxs, block, n.genesis)
+	return err
+}
+
+// commitDecidedBlock 接入已经达成共识的区块
+func (n *Node) commitDecidedBlock(block *Block, failed []*FailedTransaction) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.addBlock(block, failed)
+}
+
+// NewBFTCluster 创建在同一进程内通过BFT共识出块的一组验证者节点
+//
+// 每个节点使用base中的配置，数据保存在base.DataDir下各自的子目录中；
+// 验证者私钥保存在 base.DataDir/bft_validators.json，重启后验证者集合保持不变。
+// 第一个节点负责创建创世区块，其余节点启动后从它同步。
+//
+// 集群需要持有所有验证者的私钥，因此不能使用创世文件中的验证者集合或单独指定的验证者私钥。
+func NewBFTCluster(size int, base NodeConfig, cfg BFTConfig) ([]*Node, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("验证者数量至少为1")
+	}
+	if base.ValidatorKey != "" {
+		return nil, fmt.Errorf("BFT集群自行管理验证者私钥，不能指定验证者私钥")
+	}
+	if base.Genesis != nil && len(base.Genesis.Validators) > 0 {
+		return nil, fmt.Errorf("BFT集群自行生成验证者集合，创世文件中不能指定验证者")
+	}
+	keys, err := loadClusterKeys(size, base.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeys := make([]string, size)
+	for i, key := range keys {
+		if pubKeys[i], err = PublicKeyFromPrivate(key); err != nil {
+			return nil, err
+		}
+	}
+	validators, err := NewValidatorSet(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	network := NewBFTNetwork()
+	nodes := make([]*Node, 0, size)
+	for i, key := range keys {
+		nodeCfg := base
+		nodeCfg.Engine = NewBFTEngine(validators, network, cfg)
+		nodeCfg.ValidatorKey = key
+		if base.DataDir != "" {
+			nodeCfg.DataDir = filepath.Join(base.DataDir, fmt.Sprintf("validator-%d", i))
+		}
+		node, err := NewNode(nodeCfg)
+		if err != nil {
+			for _, created := range nodes {
+				created.Close()
+			}
+			return nil, err
+		}
+		network.attach(node)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// loadClusterKeys 读取数据目录中保存的验证者私钥，没有时生成并保存
+func loadClusterKeys(size int, dataDir string) ([]string, error) {
+	var keys []string
+	filename := filepath.Join(dataDir, "bft_validators.json")
+	if dataDir != "" {
+		data, err := ioutil.ReadFile(filename)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &keys); err != nil {
+				return nil, fmt.Errorf("解析验证者密钥文件失败: %v", err)
+			}
+			if len(keys) != size {
+				return nil, fmt.Errorf("数据目录中有 %d 个验证者，与配置的 %d 个不一致", len(keys), size)
+			}
+			return keys, nil
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("读取验证者密钥文件失败: %v", err)
+		}
+	}
+
+	for i := 0; i < size; i++ {
+		key, err := GeneratePrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if dataDir != "" {
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			return nil, err
+		}
+		data, _ := json.MarshalIndent(keys, "", "  ")
+		if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+			return nil, fmt.Errorf("保存验证者密钥失败: %v", err)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,229 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// testBFTConfig 测试使用的较短超时
+var testBFTConfig = BFTConfig{
+	BlockTime:        50 * time.Millisecond,
+	TimeoutPropose:   300 * time.Millisecond,
+	TimeoutPrevote:   100 * time.Millisecond,
+	TimeoutPrecommit: 100 * time.Millisecond,
+	TimeoutDelta:     50 * time.Millisecond,
+}
+
+// startBFTCluster 创建size个验证者并由第一个验证者创建创世区块，online中的验证者开始出块
+func startBFTCluster(t *testing.T, size int, online ...int) []*Node {
+	t.Helper()
+	nodes, err := NewBFTCluster(size, NodeConfig{}, testBFTConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range online {
+		nodes[i].StartMining()
+	}
+	t.Cleanup(func() {
+		for _, node := range nodes {
+			node.StopMining()
+		}
+	})
+	return nodes
+}
+
+// checkCommitted 检查各节点的前height个区块完全一致，并且都带有有效的提交证明
+func checkCommitted(t *testing.T, nodes []*Node, height int) {
+	t.Helper()
+	for h := 1; h <= height; h++ {
+		want := nodes[0].GetBlockByHeight(h).Hash
+		for _, node := range nodes[1:] {
+			if got := node.GetBlockByHeight(h).Hash; got != want {
+				t.Fatalf("高度 %d 的区块不一致: %s != %s", h, got, want)
+			}
+		}
+	}
+	for _, node := range nodes {
+		if err := node.ValidateChain(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBFTCommitsBlocksWithCertificates(t *testing.T) {
+	nodes := startBFTCluster(t, 4, 0, 1, 2, 3)
+	waitFor(t, "所有验证者提交3个区块", func() bool {
+		for _, node := range nodes {
+			if node.GetHeight() < 4 {
+				return false
+			}
+		}
+		return true
+	})
+	checkCommitted(t, nodes, 4)
+
+	for _, block := range nodes[2].GetAllBlocks()[1:] {
+		if block.Commit == nil || len(block.Commit.Precommits) < 3 {
+			t.Fatalf("区块 %d 缺少超过2/3的预提交", block.Height)
+		}
+	}
+}
+
+func TestBFTRoundChangeWhenProposerOffline(t *testing.T) {
+	// 高度2第0轮的提议者是验证者1，它一直离线
+	nodes := startBFTCluster(t, 4, 0, 2, 3)
+	online := []*Node{nodes[0], nodes[2], nodes[3]}
+	waitFor(t, "在线验证者提交区块", func() bool {
+		for _, node := range online {
+			if node.GetHeight() < 3 {
+				return false
+			}
+		}
+		return true
+	})
+	checkCommitted(t, online, 3)
+
+	block := nodes[0].GetBlockByHeight(2)
+	if block.Commit.Round == 0 || block.Validator == nodes[1].GetValidator() {
+		t.Fatalf("提议者离线时应在后续轮次由其他验证者提议，实际第 %d 轮", block.Commit.Round)
+	}
+
+	// 验证者重新上线后同步已提交的区块并参与共识
+	nodes[1].StartMining()
+	target := nodes[0].GetHeight() + 2
+	waitFor(t, "重新上线的验证者追上", func() bool {
+		for _, node := range nodes {
+			if node.GetHeight() < target {
+				return false
+			}
+		}
+		return true
+	})
+	checkCommitted(t, nodes, target)
+}
+
+func TestBFTResendsMessagesToLateValidators(t *testing.T) {
+	// 只有两个验证者在线时无法形成多数，它们的提议和投票发出时另外两个验证者还收不到
+	nodes := startBFTCluster(t, 4, 0, 1)
+	time.Sleep(2 * testBFTConfig.TimeoutPropose)
+	if nodes[0].GetHeight() != 1 {
+		t.Fatal("两个验证者不应提交区块")
+	}
+
+	// 后上线的验证者收到重发的消息，所有验证者都能继续提交区块
+	nodes[2].StartMining()
+	nodes[3].StartMining()
+	waitFor(t, "所有验证者提交区块", func() bool {
+		for _, node := range nodes {
+			if node.GetHeight() < 3 {
+				return false
+			}
+		}
+		return true
+	})
+	checkCommitted(t, nodes, 3)
+}
+
+func TestBFTRejectsInvalidCommit(t *testing.T) {
+	nodes := startBFTCluster(t, 4, 0, 1, 2, 3)
+	waitFor(t, "提交区块", func() bool { return nodes[0].GetHeight() >= 2 })
+	for _, node := range nodes {
+		node.StopMining()
+	}
+
+	parent := nodes[0].GetBlockByHeight(1)
+	committed := nodes[0].GetBlockByHeight(2)
+	engine := nodes[0].engine
+	noAncestors := func(string) *Block { return nil }
+	if err := engine.VerifyBlock(committed, parent, noAncestors); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有提交证明
+	block := *committed
+	block.Commit = nil
+	if err := engine.VerifyBlock(&block, parent, noAncestors); err == nil {
+		t.Fatal("没有提交证明的区块应被拒绝")
+	}
+
+	// 预提交不超过2/3
+	commit := *committed.Commit
+	commit.Precommits = commit.Precommits[:2]
+	block.Commit = &commit
+	if err := engine.VerifyBlock(&block, parent, noAncestors); err == nil {
+		t.Fatal("预提交不足的区块应被拒绝")
+	}
+
+	// 同一验证者的预提交重复计数
+	commit.Precommits = []*Vote{committed.Commit.Precommits[0], committed.Commit.Precommits[0], committed.Commit.Precommits[0]}
+	if err := engine.VerifyBlock(&block, parent, noAncestors); err == nil {
+		t.Fatal("重复的预提交应被拒绝")
+	}
+
+	// 篡改投票的轮次后签名失效
+	commit.Precommits = make([]*Vote, len(committed.Commit.Precommits))
+	for i, vote := range committed.Commit.Precommits {
+		forged := *vote
+		forged.Round++
+		commit.Precommits[i] = &forged
+	}
+	commit.Round++
+	if err := engine.VerifyBlock(&block, parent, noAncestors); err == nil {
+		t.Fatal("签名无效的预提交应被拒绝")
+	}
+}
+
+func TestBFTClusterRestartsFromGenesisDoc(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NodeConfig{DataDir: dir, Genesis: testGenesisDoc(testAddress("alice"))}
+
+	nodes, err := NewBFTCluster(4, cfg, testBFTConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes {
+		node.StartMining()
+	}
+	waitFor(t, "提交区块", func() bool { return nodes[0].GetHeight() >= 3 })
+	for _, node := range nodes {
+		node.StopMining()
+	}
+	height := nodes[0].GetHeight()
+	for _, node := range nodes {
+		node.Close()
+	}
+
+	// 重启后创世文件生成的创世区块没有提议者签名，也应通过验证
+	restarted, err := NewBFTCluster(4, cfg, testBFTConfig)
+	if err != nil {
+		t.Fatalf("使用创世文件的BFT节点应能重启: %v", err)
+	}
+	defer func() {
+		for _, node := range restarted {
+			node.Close()
+		}
+	}()
+	if got := restarted[0].GetHeight(); got != height {
+		t.Fatalf("重启后高度应为%d，实际为%d", height, got)
+	}
+	if err := restarted[0].ValidateChain(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBFTClusterRejectsExternalValidators(t *testing.T) {
+	key, _ := GeneratePrivateKey()
+	pubKey, _ := PublicKeyFromPrivate(key)
+
+	if _, err := NewBFTCluster(4, NodeConfig{ValidatorKey: key}, testBFTConfig); err == nil {
+		t.Fatal("BFT集群不应接受单独指定的验证者私钥")
+	}
+	doc := testGenesisDoc(testAddress("alice"))
+	doc.Validators = []GenesisValidator{{PubKey: pubKey}}
+	if _, err := NewBFTCluster(4, NodeConfig{Genesis: doc}, testBFTConfig); err == nil {
+		t.Fatal("BFT集群不应接受创世文件中的验证者集合")
+	}
+}
@@ -0,0 +1,249 @@
+package blockchain
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// BaseDenom 链的基础代币，用于挖矿奖励、初始余额和创世分配
+const BaseDenom = "stake"
+
+// denomPattern 代币名称：小写字母开头，3到32个字符
+var denomPattern = regexp.MustCompile(`^[a-z][a-z0-9/]{2,31}$`)
+
+// Coin 一种代币及其数量
+type Coin struct {
+	Denom  string `json:"denom"`
+	Amount int64  `json:"amount"`
+}
+
+// Coins 按代币名称记录的数量，对应Cosmos SDK中的sdk.Coins
+type Coins map[string]int64
+
+// AmountOf 返回指定代币的数量
+func (c Coins) AmountOf(denom string) int64 {
+	return c[denomOf(denom)]
+}
+
+// add 增加指定代币的数量，数量为0的代币会被删除
+func (c Coins) add(denom string, amount int64) {
+	denom = denomOf(denom)
+	c[denom] += amount
+	if c[denom] == 0 {
+		delete(c, denom)
+	}
+}
+
+// addAmounts 返回两个数量之和，结果超出int64范围时返回错误
+func addAmounts(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, fmt.Errorf("数量 %d 与 %d 之和超出范围", a, b)
+	}
+	return a + b, nil
+}
+
+// clone 复制代币数量，保证修改副本不会影响原来的记录
+func (c Coins) clone() Coins {
+	copied := make(Coins, len(c))
+	for denom, amount := range c {
+		copied[denom] = amount
+	}
+	return copied
+}
+
+// Denoms 按名称排序返回所有代币名称
+func (c Coins) Denoms() []string {
+	denoms := make([]string, 0, len(c))
+	for denom := range c {
+		denoms = append(denoms, denom)
+	}
+	sort.Strings(denoms)
+	return denoms
+}
+
+// denomOf 把交易中为空的代币名称解释为基础代币
+func denomOf(denom string) string {
+	if denom == "" {
+		return BaseDenom
+	}
+	return denom
+}
+
+// txDenom 把代币名称写入交易：基础代币留空，保证只使用基础代币的交易编码不变
+func txDenom(denom string) string {
+	if denom == BaseDenom {
+		return ""
+	}
+	return denom
+}
+
+// validateDenom 检查代币名称的格式
+func validateDenom(denom string) error {
+	if !denomPattern.MatchString(denom) {
+		return fmt.Errorf("代币名称 %s 无效：需要3到32个小写字母、数字或/，并以字母开头", denom)
+	}
+	return nil
+}
+
+// amountOf 返回账户中指定代币的余额
+func (a accountState) amountOf(denom string) int64 {
+	if denom = denomOf(denom); denom == BaseDenom {
+		return a.Balance
+	}
+	return a.Tokens[denom]
+}
+
+// add 修改账户中指定代币的余额
+//
+// 账户状态按值传递，其他代币的余额会先复制一份再修改，避免改动钱包或其他临时状态中的记录。
+func (a *accountState) add(denom string, amount int64) {
+	if denom = denomOf(denom); denom == BaseDenom {
+		a.Balance += amount
+		return
+	}
+	tokens := a.Tokens.clone()
+	tokens.add(denom, amount)
+	a.Tokens = tokens
+}
+
+// coins 返回账户中所有代币的余额，包括基础代币
+func (a accountState) coins() Coins {
+	coins := a.Tokens.clone()
+	if a.Balance != 0 {
+		coins[BaseDenom] = a.Balance
+	}
+	return coins
+}
+
+// DenomSupply 描述一种发行代币的创建者和流通情况
+type DenomSupply struct {
+	Denom       string `json:"denom"`
+	Creator     string `json:"creator"`     // 创建者，只有创建者可以继续发行
+	Circulating int64  `json:"circulating"` // 所有账户余额之和
+	Minted      int64  `json:"minted"`      // 发行总量
+	Burned      int64  `json:"burned"`      // 作为手续费被销毁的数量
+	Reconciled  bool   `json:"reconciled"`  // 流通量是否等于发行量减去销毁量
+}
+
+// denomCreator 加锁读取代币的创建者，代币不存在时返回空字符串
+func (wm *WalletManager) denomCreator(denom string) string {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.denoms[denom]
+}
+
+// GetCoins 获取钱包中所有代币的余额
+func (wm *WalletManager) GetCoins(address string) Coins {
+	account, _ := wm.account(address)
+	return account.coins()
+}
+
+// denomSupply 返回代币的发行和流通情况
+func (wm *WalletManager) denomSupply(denom string) *DenomSupply {
+	circulating, minted, burned := wm.supply(denom)
+	return &DenomSupply{
+		Denom:       denom,
+		Creator:     wm.denomCreator(denom),
+		Circulating: circulating,
+		Minted:      minted,
+		Burned:      burned,
+		Reconciled:  circulating == minted-burned,
+	}
+}
+
+// IssueToken 由创建者发行代币并放入交易池
+//
+// 代币名称第一次出现时，发行者成为它的创建者；之后只有创建者可以继续发行。
+// 发行的数量记入to的余额，手续费使用基础代币。
+func (n *Node) IssueToken(creator, to, denom string, amount, fee int64) (*Transaction, error) {
+	if to == "" {
+		to = creator
+	}
+	return n.submitTransaction(TxTypeIssue, creator, to, Coin{Denom: denom, Amount: amount}, Coin{Denom: BaseDenom, Amount: fee}, LockCondition{})
+}
+
+// TransferCoins 创建一笔指定代币的转账并放入交易池，手续费可以使用任意代币支付
+func (n *Node) TransferCoins(from, to string, amount, fee Coin) (*Transaction, error) {
+	return n.submitTransaction(TxTypeTransfer, from, to, amount, fee, LockCondition{})
+}
+
+// submitTransaction 用本地钱包签名一笔交易，检查通过后放入交易池
+func (n *Node) submitTransaction(txType, from, to string, amount, fee Coin, lock LockCondition) (*Transaction, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tx, err := n.walletManager.CreateCoinTransaction(txType, from, to, amount, fee, n.nextNonce(from), lock)
+	if err != nil {
+		return nil, err
+	}
+
+	// 验证交易（序号需要排在交易池中同一发送方的交易之后）
+	if err := n.validatePending(tx); err != nil {
+		return nil, err
+	}
+	if err := n.addPending(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetCoins 获取地址上所有代币的余额
+func (n *Node) GetCoins(address string) Coins {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.walletManager.GetCoins(address)
+}
+
+// GetDenomSupply 获取一种发行代币的流通情况
+func (n *Node) GetDenomSupply(denom string) (*DenomSupply, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.walletManager.denomCreator(denom) == "" {
+		return nil, fmt.Errorf("代币 %s 不存在", denom)
+	}
+	return n.walletManager.denomSupply(denom), nil
+}
+
+// GetDenoms 按名称排序获取所有发行代币的流通情况
+func (n *Node) GetDenoms() []*DenomSupply {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	n.walletManager.mu.RLock()
+	denoms := make([]string, 0, len(n.walletManager.denoms))
+	for denom := range n.walletManager.denoms {
+		denoms = append(denoms, denom)
+	}
+	n.walletManager.mu.RUnlock()
+	sort.Strings(denoms)
+
+	supplies := make([]*DenomSupply, 0, len(denoms))
+	for _, denom := range denoms {
+		supplies = append(supplies, n.walletManager.denomSupply(denom))
+	}
+	return supplies
+}
+
+// feeTransactions 为区块收取的每种代币的手续费生成一笔支付给出块者的系统交易，按代币名称排序
+func feeTransactions(height int, producer string, fees Coins, timestamp int64) []*Transaction {
+	txs := make([]*Transaction, 0, len(fees))
+	for _, denom := range fees.Denoms() {
+		if fees[denom] > 0 {
+			txs = append(txs, newFeeTransaction(height, producer, denom, fees[denom], timestamp))
+		}
+	}
+	return txs
+}
+
+// feeTxID 返回区块中某种代币的手续费支付交易的ID
+func feeTxID(height int, denom string) string {
+	if denom = denomOf(denom); denom != BaseDenom {
+		return fmt.Sprintf("fees-%d-%s", height, denom)
+	}
+	return fmt.Sprintf("fees-%d", height)
+}
@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestIssueAndTransferTokens(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDoc := func() *GenesisDoc {
+		doc := testGenesisDoc(alice.Address)
+		doc.FeePolicy.Destination = FeesProducer
+		doc.FeePolicy.MinFees = []Coin{{Denom: "gold", Amount: 2}}
+		return doc
+	}
+	a := newEconomicsNode(t, newDoc(), alice)
+	b, err := NewNode(NodeConfig{Genesis: newDoc()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := a.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 代币名称必须有效，基础代币不能由用户发行
+	if _, err := a.IssueToken(alice.Address, "", "G", 1000, 3); err == nil {
+		t.Fatal("无效的代币名称应被拒绝")
+	}
+	if _, err := a.IssueToken(alice.Address, "", BaseDenom, 1000, 3); err == nil {
+		t.Fatal("用户不能发行基础代币")
+	}
+
+	// alice第一次发行gold，成为它的创建者
+	if _, err := a.IssueToken(alice.Address, "", "gold", 1000, 3); err != nil {
+		t.Fatal(err)
+	}
+	a.generateNewBlock()
+	coins := a.GetCoins(alice.Address)
+	if coins.AmountOf("gold") != 1000 || coins.AmountOf(BaseDenom) != 5000-3 {
+		t.Fatalf("发行后alice的余额 = %v", coins)
+	}
+	if _, err := a.IssueToken(bob.Address, "", "gold", 1, 0); err == nil {
+		t.Fatal("只有创建者可以继续发行代币")
+	}
+
+	// 只能用手续费规则中列出的代币支付手续费，且不低于该代币的最低手续费
+	if _, err := a.TransferCoins(alice.Address, bob.Address, Coin{Denom: "gold", Amount: 300}, Coin{Denom: "gold", Amount: 1}); err == nil {
+		t.Fatal("低于gold最低手续费的转账应被拒绝")
+	}
+	if _, err := a.TransferCoins(alice.Address, bob.Address, Coin{Denom: BaseDenom, Amount: 300}, Coin{Denom: "silver", Amount: 100}); err == nil {
+		t.Fatal("用未列出的代币支付手续费应被拒绝")
+	}
+
+	// 转账gold并用gold支付手续费，手续费支付给出块者
+	if _, err := a.TransferCoins(alice.Address, bob.Address, Coin{Denom: "gold", Amount: 300}, Coin{Denom: "gold", Amount: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.TransferCoins(alice.Address, bob.Address, Coin{Denom: "gold", Amount: 2000}, Coin{Denom: BaseDenom, Amount: 2}); err == nil {
+		t.Fatal("代币余额不足的转账应被拒绝")
+	}
+	a.generateNewBlock()
+
+	block := a.GetBlockByHeight(3)
+	last := block.Transactions[len(block.Transactions)-1]
+	if last.ID != "fees-3-gold" || last.Amount != 2 || last.Denom != "gold" {
+		t.Fatalf("区块最后应是gold手续费支付，实际 %+v", last)
+	}
+	if a.GetCoins(bob.Address).AmountOf("gold") != 300 || a.GetCoins(alice.Address).AmountOf("gold") != 698 {
+		t.Fatal("转账后的gold余额不正确")
+	}
+	if a.GetCoins(a.GetMinerAddress()).AmountOf("gold") != 2 {
+		t.Fatal("出块者应收到gold手续费")
+	}
+
+	supply, err := a.GetDenomSupply("gold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if supply.Creator != alice.Address || supply.Minted != 1000 || supply.Circulating != 1000 || !supply.Reconciled {
+		t.Fatalf("gold的流通情况 = %+v", supply)
+	}
+	if denoms := a.GetDenoms(); len(denoms) != 1 || denoms[0].Denom != "gold" {
+		t.Fatalf("代币列表 = %+v", denoms)
+	}
+	if _, err := a.GetDenomSupply("silver"); err == nil {
+		t.Fatal("不存在的代币应返回错误")
+	}
+	if !a.GetSupply().Reconciled {
+		t.Fatal("基础代币的流通量与发行量不一致")
+	}
+
+	// 另一个节点同步区块后得到相同的多代币状态
+	for height := 2; height <= 3; height++ {
+		if err := b.AddPeerBlock(a.GetBlockByHeight(height)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.GetStateHash() != a.GetStateHash() {
+		t.Fatal("同步区块后两个节点的状态不一致")
+	}
+	if err := b.RebuildState(); err != nil {
+		t.Fatal(err)
+	}
+	if b.GetCoins(bob.Address).AmountOf("gold") != 300 {
+		t.Fatal("重放区块后bob的gold余额不正确")
+	}
+}
+
+func TestCoinsCopyOnWrite(t *testing.T) {
+	wallet := &Wallet{Address: testAddress("alice"), Balance: 10, Tokens: Coins{"gold": 5}}
+	wm := NewWalletManager()
+	wm.RestoreWallet(wallet)
+
+	account, _ := wm.account(wallet.Address)
+	account.add("gold", -5)
+	account.add("", 1)
+	if wallet.Tokens["gold"] != 5 || wallet.Balance != 10 {
+		t.Fatal("修改账户状态不应影响钱包中的余额")
+	}
+	if account.amountOf("gold") != 0 || account.amountOf(BaseDenom) != 11 {
+		t.Fatalf("账户余额 = %v", account.coins())
+	}
+	if _, ok := account.Tokens["gold"]; ok {
+		t.Fatal("余额为0的代币应被删除")
+	}
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConsensusEngine 决定何时生成区块、区块需要满足哪些额外规则，以及分叉时链的权重
+//
+// 节点负责交易的打包、执行和持久化，共识引擎只处理与共识相关的部分，
+// 这样可以在同一个节点上切换和比较不同的共识方式。
+type ConsensusEngine interface {
+	// Name 返回引擎名称
+	Name() string
+
+	// Prepare 在封装之前填写区块中与共识相关的字段，parent为nil表示创世区块
+	Prepare(block, parent *Block, lookup func(hash string) *Block) error
+
+	// Seal 封装区块并计算区块哈希；abort返回true时放弃封装并返回false
+	Seal(block *Block, abort func() bool) bool
+
+	// VerifyBlock 检查区块是否符合共识规则，parent为nil表示创世区块
+	VerifyBlock(block, parent *Block, lookup func(hash string) *Block) error
+
+	// BlockWeight 返回区块对链权重的贡献，分叉选择时选择累计权重最大的链
+	BlockWeight(block *Block) uint64
+
+	// Run 持续生产区块，直到stop被关闭
+	Run(n *Node, stop <-chan struct{})
+}
+
+// SoloEngine 是单个验证者按固定间隔出块的引擎
+type SoloEngine struct {
+	blockTime time.Duration
+}
+
+// NewSoloEngine 创建按固定间隔出块的单节点引擎
+func NewSoloEngine(blockTime time.Duration) *SoloEngine {
+	if blockTime <= 0 {
+		blockTime = 10 * time.Second
+	}
+	return &SoloEngine{blockTime: blockTime}
+}
+
+// Name 返回引擎名称
+func (e *SoloEngine) Name() string {
+	return "solo"
+}
+
+// Prepare 单节点引擎没有额外的区块字段
+func (e *SoloEngine) Prepare(block, parent *Block, lookup func(hash string) *Block) error {
+	return nil
+}
+
+// Seal 直接计算区块哈希
+func (e *SoloEngine) Seal(block *Block, abort func() bool) bool {
+	block.Hash = calculateBlockHash(block)
+	return true
+}
+
+// VerifyBlock 单节点引擎只要求区块没有工作量证明字段
+func (e *SoloEngine) VerifyBlock(block, parent *Block, lookup func(hash string) *Block) error {
+	return verifyNoProofOfWork(block)
+}
+
+// BlockWeight 每个区块的权重相同，最重的链就是最长的链
+func (e *SoloEngine) BlockWeight(block *Block) uint64 {
+	return 1
+}
+
+// Run 每隔固定时间生成一个区块
+func (e *SoloEngine) Run(n *Node, stop <-chan struct{}) {
+	ticker := time.NewTicker(e.blockTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n.generateNewBlock()
+		}
+	}
+}
+
+// EngineOptions 按名称创建共识引擎时使用的参数
+type EngineOptions struct {
+	BlockTime  time.Duration // 目标出块间隔
+	Difficulty uint32        // 工作量证明的初始难度
+	Validators []string      // 权威证明的验证者公钥
+}
+
+// NewConsensusEngine 按名称创建共识引擎，供命令行选择
+func NewConsensusEngine(name string, opts EngineOptions) (ConsensusEngine, error) {
+	switch name {
+	case "", "solo":
+		return NewSoloEngine(opts.BlockTime), nil
+	case "pow":
+		return NewPoWEngine(opts.Difficulty, opts.BlockTime), nil
+	case "poa":
+		validators, err := NewValidatorSet(opts.Validators)
+		if err != nil {
+			return nil, fmt.Errorf("权威证明需要在创世文件中配置验证者: %v", err)
+		}
+		return NewPoAEngine(validators, opts.BlockTime), nil
+	case "bft":
+		return nil, fmt.Errorf("BFT共识需要多个验证者，请使用 NewBFTCluster 创建")
+	default:
+		return nil, fmt.Errorf("不支持的共识引擎: %s（可选 solo、pow、poa、bft）", name)
+	}
+}
+
+// idleWait 暂时无法出块（例如还没有创世区块）时等待一段时间，返回false表示已停止出块
+func idleWait(stop <-chan struct{}, d time.Duration) bool {
+	select {
+	case <-stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,103 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// 与Cosmos SDK一致，使用secp256k1曲线上的ECDSA签名。
+// 私钥为32字节，公钥使用33字节压缩格式，签名为64字节的 R||S，均以十六进制字符串表示。
+
+// GeneratePrivateKey 生成一个新的secp256k1私钥
+func GeneratePrivateKey() (string, error) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return "", fmt.Errorf("生成私钥失败: %v", err)
+	}
+	return hex.EncodeToString(key.Serialize()), nil
+}
+
+// PublicKeyFromPrivate 由私钥计算压缩格式的公钥
+func PublicKeyFromPrivate(privateKeyHex string) (string, error) {
+	key, err := parsePrivateKey(privateKeyHex)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key.PubKey().SerializeCompressed()), nil
+}
+
+// SignBytes 使用私钥对数据的SHA-256摘要签名
+func SignBytes(privateKeyHex string, data []byte) (string, error) {
+	key, err := parsePrivateKey(privateKeyHex)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	sig := ecdsa.Sign(key, hash[:])
+
+	r, s := sig.R(), sig.S()
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+
+	raw := make([]byte, 64)
+	copy(raw[:32], rBytes[:])
+	copy(raw[32:], sBytes[:])
+	return hex.EncodeToString(raw), nil
+}
+
+// VerifySignature 使用公钥验证数据的签名
+func VerifySignature(publicKeyHex string, data []byte, signatureHex string) error {
+	pubKey, err := parsePublicKey(publicKeyHex)
+	if err != nil {
+		return err
+	}
+
+	raw, err := hex.DecodeString(signatureHex)
+	if err != nil || len(raw) != 64 {
+		return fmt.Errorf("签名格式无效")
+	}
+
+	var r, s secp256k1.ModNScalar
+	if overflow := r.SetByteSlice(raw[:32]); overflow || r.IsZero() {
+		return fmt.Errorf("签名格式无效")
+	}
+	if overflow := s.SetByteSlice(raw[32:]); overflow || s.IsZero() {
+		return fmt.Errorf("签名格式无效")
+	}
+
+	hash := sha256.Sum256(data)
+	if !ecdsa.NewSignature(&r, &s).Verify(hash[:], pubKey) {
+		return fmt.Errorf("签名验证失败")
+	}
+	return nil
+}
+
+func parsePrivateKey(privateKeyHex string) (*secp256k1.PrivateKey, error) {
+	raw, err := hex.DecodeString(privateKeyHex)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("私钥格式无效")
+	}
+
+	var scalar secp256k1.ModNScalar
+	if overflow := scalar.SetByteSlice(raw); overflow || scalar.IsZero() {
+		return nil, fmt.Errorf("私钥格式无效")
+	}
+	return secp256k1.NewPrivateKey(&scalar), nil
+}
+
+func parsePublicKey(publicKeyHex string) (*secp256k1.PublicKey, error) {
+	raw, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("公钥格式无效")
+	}
+
+	pubKey, err := secp256k1.ParsePubKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("公钥格式无效: %v", err)
+	}
+	return pubKey, nil
+}
@@ -0,0 +1,161 @@
+package blockchain
+
+import (
+	"fmt"
+)
+
+// 出块奖励的衰减方式
+const (
+	RewardConstant = "constant" // 奖励保持不变
+	RewardHalving  = "halving"  // 每隔Interval个区块减半
+	RewardDecay    = "decay"    // 每隔Interval个区块按DecayPercent减少
+)
+
+// 手续费的去向
+const (
+	FeesBurn     = "burn"     // 手续费销毁，从总供应中扣除
+	FeesProducer = "producer" // 手续费支付给出块者
+)
+
+// MonetaryPolicy 货币政策：出块奖励如何随高度变化，以及总供应上限
+type MonetaryPolicy struct {
+	Schedule     string `json:"schedule"`                // constant、halving或decay，为空时等同constant
+	Interval     int    `json:"interval,omitempty"`      // 每隔多少个区块调整一次奖励
+	DecayPercent int    `json:"decay_percent,omitempty"` // decay方式每次减少的百分比
+	SupplyCap    int64  `json:"supply_cap,omitempty"`    // 总发行量上限（包括创世分配和初始余额发放），0表示不限
+}
+
+// Validate 检查货币政策的参数
+func (p MonetaryPolicy) Validate() error {
+	switch p.Schedule {
+	case "", RewardConstant:
+	case RewardHalving:
+		if p.Interval <= 0 {
+			return fmt.Errorf("减半方式需要大于0的interval")
+		}
+	case RewardDecay:
+		if p.Interval <= 0 || p.DecayPercent <= 0 || p.DecayPercent >= 100 {
+			return fmt.Errorf("衰减方式需要大于0的interval和1到99之间的decay_percent")
+		}
+	default:
+		return fmt.Errorf("不支持的奖励方式: %s", p.Schedule)
+	}
+	if p.SupplyCap < 0 {
+		return fmt.Errorf("supply_cap不能为负")
+	}
+	return nil
+}
+
+// scheduledReward 按衰减方式计算指定高度的出块奖励，不考虑供应上限
+//
+// 创世区块没有奖励，第一个有奖励的区块是高度2。
+func (p MonetaryPolicy) scheduledReward(base int64, height int) int64 {
+	if height < 2 {
+		return 0
+	}
+	era := 0
+	if p.Interval > 0 {
+		era = (height - 2) / p.Interval
+	}
+
+	switch p.Schedule {
+	case RewardHalving:
+		if era >= 63 {
+			return 0
+		}
+		return base >> uint(era)
+	case RewardDecay:
+		reward := base
+		for i := 0; i < era && reward > 0; i++ {
+			reward = reward * int64(100-p.DecayPercent) / 100
+		}
+		return reward
+	default:
+		return base
+	}
+}
+
+// BlockReward 返回高度为height的区块的出块奖励
+//
+// minted是该区块之前的总发行量，奖励不会使总发行量超过供应上限。
+func (g *GenesisDoc) BlockReward(height int, minted int64) int64 {
+	reward := g.MonetaryPolicy.scheduledReward(g.MiningReward, height)
+	if limit := g.MonetaryPolicy.SupplyCap; limit > 0 && minted+reward > limit {
+		reward = limit - minted
+		if reward < 0 {
+			reward = 0
+		}
+	}
+	return reward
+}
+
+// burnsFees 判断手续费是否销毁
+func (g *GenesisDoc) burnsFees() bool {
+	return g.FeePolicy.Destination != FeesProducer
+}
+
+// newFeeTransaction 创建把区块中某种代币的手续费支付给出块者的系统交易，它们总是区块最后的交易
+func newFeeTransaction(height int, producer, denom string, fees, timestamp int64) *Transaction {
+	return &Transaction{
+		ID:        feeTxID(height, denom),
+		Type:      TxTypeFee,
+		From:      "system",
+		To:        producer,
+		Amount:    fees,
+		Denom:     txDenom(denom),
+		Timestamp: timestamp,
+		Signature: "system-fees",
+	}
+}
+
+// SupplyInfo 描述代币的发行和流通情况
+type SupplyInfo struct {
+	Denom          string `json:"denom"`           // 基础代币名称
+	Circulating    int64  `json:"circulating"`     // 所有账户余额之和
+	Minted         int64  `json:"minted"`          // 创世分配、初始余额发放和出块奖励的总和
+	Burned         int64  `json:"burned"`          // 销毁的手续费
+	SupplyCap      int64  `json:"supply_cap"`      // 总发行量上限，0表示不限
+	NextReward     int64  `json:"next_reward"`     // 下一个区块的出块奖励
+	Schedule       string `json:"schedule"`        // 出块奖励的衰减方式
+	FeeDestination string `json:"fee_destination"` // 手续费的去向
+	Reconciled     bool   `json:"reconciled"`      // 流通量是否等于发行量减去销毁量
+}
+
+// GetSupply 获取代币的发行和流通情况
+func (n *Node) GetSupply() SupplyInfo {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	circulating, minted, burned := n.walletManager.supply(BaseDenom)
+	schedule := n.genesis.MonetaryPolicy.Schedule
+	if schedule == "" {
+		schedule = RewardConstant
+	}
+	destination := FeesBurn
+	if !n.genesis.burnsFees() {
+		destination = FeesProducer
+	}
+	return SupplyInfo{
+		Denom:          BaseDenom,
+		Circulating:    circulating,
+		Minted:         minted,
+		Burned:         burned,
+		SupplyCap:      n.genesis.MonetaryPolicy.SupplyCap,
+		NextReward:     n.genesis.BlockReward(len(n.chain)+1, minted),
+		Schedule:       schedule,
+		FeeDestination: destination,
+		Reconciled:     circulating == minted-burned,
+	}
+}
+
+// supply 返回指定代币的账户余额（包括锁定资金）之和、总发行量和总销毁量
+func (wm *WalletManager) supply(denom string) (circulating, minted, burned int64) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	for address := range wm.wallets {
+		account, _ := wm.accountOf(address)
+		circulating += account.amountOf(denom) + lockedCoins(account.Locked).AmountOf(denom)
+	}
+	return circulating, wm.minted[denom], wm.burned[denom]
+}
@@ -0,0 +1,173 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestRewardSchedules(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  MonetaryPolicy
+		minted  int64
+		rewards map[int]int64
+	}{
+		{"固定", MonetaryPolicy{}, 0, map[int]int64{1: 0, 2: 100, 1000: 100}},
+		{"减半", MonetaryPolicy{Schedule: RewardHalving, Interval: 2}, 0, map[int]int64{2: 100, 3: 100, 4: 50, 6: 25, 200: 0}},
+		{"衰减", MonetaryPolicy{Schedule: RewardDecay, Interval: 1, DecayPercent: 10}, 0, map[int]int64{2: 100, 3: 90, 4: 81}},
+		{"供应上限", MonetaryPolicy{SupplyCap: 1000}, 990, map[int]int64{2: 10}},
+		{"达到上限", MonetaryPolicy{SupplyCap: 1000}, 1000, map[int]int64{2: 0}},
+	}
+	for _, c := range cases {
+		doc := &GenesisDoc{MiningReward: 100, MonetaryPolicy: c.policy}
+		for height, want := range c.rewards {
+			if got := doc.BlockReward(height, c.minted); got != want {
+				t.Fatalf("%s: 高度 %d 的奖励 = %d，期望 %d", c.name, height, got, want)
+			}
+		}
+	}
+
+	invalid := []MonetaryPolicy{
+		{Schedule: "linear"},
+		{Schedule: RewardHalving},
+		{Schedule: RewardDecay, Interval: 10, DecayPercent: 100},
+		{SupplyCap: -1},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("货币政策 %+v 应被拒绝", p)
+		}
+	}
+}
+
+// newEconomicsNode 创建使用doc的节点，并把alice的私钥放入节点
+func newEconomicsNode(t *testing.T, doc *GenesisDoc, alice *Wallet) *Node {
+	t.Helper()
+	node, err := NewNode(NodeConfig{Genesis: doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := *alice
+	restored.Balance = node.GetBalance(alice.Address)
+	node.walletManager.RestoreWallet(&restored)
+	return node
+}
+
+// checkSupply 检查流通量等于发行量减去销毁量
+func checkSupply(t *testing.T, node *Node, minted, burned int64) {
+	t.Helper()
+	supply := node.GetSupply()
+	if supply.Minted != minted || supply.Burned != burned {
+		t.Fatalf("发行量 %d、销毁量 %d，期望 %d、%d", supply.Minted, supply.Burned, minted, burned)
+	}
+	if !supply.Reconciled || supply.Circulating != minted-burned {
+		t.Fatalf("流通量 %d 与发行量和销毁量不一致", supply.Circulating)
+	}
+}
+
+func TestFeesBurnedAndSupplyCap(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := testGenesisDoc(alice.Address)
+	doc.MonetaryPolicy.SupplyCap = 5000 + 120
+	node := newEconomicsNode(t, doc, alice)
+
+	if _, err := node.Transfer(alice.Address, testAddress("bob"), 100, 3); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+	checkSupply(t, node, 5050, 3)
+
+	// 出块奖励在达到供应上限前被截断，之后的区块没有挖矿奖励
+	node.generateNewBlock()
+	node.generateNewBlock()
+	if reward := node.GetBlockByHeight(4).Transactions[0]; reward.Type != TxTypeMining || reward.Amount != 20 {
+		t.Fatalf("高度4的奖励应被截断为20，实际 %+v", reward)
+	}
+	node.generateNewBlock()
+	if txs := node.GetBlockByHeight(5).Transactions; len(txs) != 0 {
+		t.Fatalf("达到供应上限后不应再有奖励，实际 %d 笔交易", len(txs))
+	}
+	checkSupply(t, node, 5120, 3)
+	if node.GetSupply().NextReward != 0 {
+		t.Fatal("达到供应上限后下一个区块的奖励应为0")
+	}
+
+	// 重放区块得到相同的发行量和销毁量
+	if err := node.RebuildState(); err != nil {
+		t.Fatal(err)
+	}
+	checkSupply(t, node, 5120, 3)
+}
+
+func TestFeesPaidToProducer(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDoc := func() *GenesisDoc {
+		doc := testGenesisDoc(alice.Address)
+		doc.FeePolicy.Destination = FeesProducer
+		return doc
+	}
+	a := newEconomicsNode(t, newDoc(), alice)
+	b := newEconomicsNode(t, newDoc(), alice)
+
+	if _, err := a.Transfer(alice.Address, testAddress("bob"), 100, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Transfer(alice.Address, testAddress("bob"), 100, 4); err != nil {
+		t.Fatal(err)
+	}
+	a.generateNewBlock()
+
+	// 手续费支付交易是区块的最后一笔交易，出块者得到奖励和全部手续费
+	block := a.GetBlockByHeight(2)
+	last := block.Transactions[len(block.Transactions)-1]
+	if last.Type != TxTypeFee || last.Amount != 7 {
+		t.Fatalf("最后一笔交易应为7的手续费支付，实际 %+v", last)
+	}
+	miner := a.GetMinerAddress()
+	if a.GetBalance(miner) != 50+7 {
+		t.Fatalf("出块者余额 = %d，期望 57", a.GetBalance(miner))
+	}
+	checkSupply(t, a, 5050, 0)
+	if stats := a.GetMiningStats(); stats["total_fees"].(Coins)[BaseDenom] != 7 {
+		t.Fatalf("手续费收入 = %v，期望 7", stats["total_fees"])
+	}
+
+	// 篡改手续费支付金额后重新签名的区块被拒绝
+	forged := *block
+	forged.Transactions = append([]*Transaction{}, block.Transactions...)
+	inflated := *last
+	inflated.Amount = 70
+	forged.Transactions[len(forged.Transactions)-1] = &inflated
+	forged.TxRoot = CalculateTxRoot(forged.Transactions)
+	forged.Hash = calculateBlockHash(&forged)
+	if forged.Signature, err = a.signBlock(&forged); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddPeerBlock(&forged); err == nil {
+		t.Fatal("手续费支付金额错误的区块应被拒绝")
+	}
+
+	// 缺少手续费支付的区块同样被拒绝
+	forged.Transactions = block.Transactions[:len(block.Transactions)-1]
+	forged.TxRoot = CalculateTxRoot(forged.Transactions)
+	forged.Hash = calculateBlockHash(&forged)
+	if forged.Signature, err = a.signBlock(&forged); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddPeerBlock(&forged); err == nil {
+		t.Fatal("没有支付手续费的区块应被拒绝")
+	}
+
+	if err := b.AddPeerBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	checkSupply(t, b, 5050, 0)
+	if err := b.AddPeerTransaction(last); err == nil {
+		t.Fatal("手续费支付交易不能通过交易池提交")
+	}
+}
@@ -0,0 +1,257 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 节点事件的类型
+const (
+	EventNewBlock      = "new_block"      // 区块接入主链
+	EventNewTx         = "new_tx"         // 交易进入交易池
+	EventTxFailed      = "tx_failed"      // 交易打包失败，或被交易池淘汰、替换
+	EventReorg         = "reorg"          // 链重组，之后会为新接入主链的区块发布 new_block
+	EventMiningStarted = "mining_started" // 开始生成区块
+	EventMiningStopped = "mining_stopped" // 停止生成区块
+)
+
+const (
+	DefaultEventBuffer = 64  // 每个订阅默认缓冲的事件数
+	maxEventHistory    = 256 // 保留最近的事件，供断线重连的订阅者补发
+)
+
+// 订阅结束的原因
+var (
+	ErrSlowSubscriber = errors.New("订阅者处理太慢，事件缓冲区已满")
+	ErrEventBusClosed = errors.New("节点已关闭")
+)
+
+// Event 节点上发生的一个事件
+//
+// Seq从1开始逐个递增，订阅者可以用它发现遗漏的事件，并在重连时请求补发。
+type Event struct {
+	Seq         uint64       `json:"seq"`
+	Type        string       `json:"type"`
+	Time        time.Time    `json:"time"`
+	Height      int          `json:"height,omitempty"` // 区块高度；交易失败时是尝试打包的高度
+	Block       *Block       `json:"block,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Reason      string       `json:"reason,omitempty"` // 交易失败的原因
+	Reorg       *ReorgEvent  `json:"reorg,omitempty"`
+}
+
+// EventFilter 订阅的过滤条件，为空的条件不过滤
+type EventFilter struct {
+	Types   []string // 只接收这些类型的事件
+	Address string   // 只接收与该地址有关的交易和区块；链重组和挖矿状态事件不按地址过滤
+}
+
+// Validate 检查事件类型和地址是否有效
+func (f *EventFilter) Validate() error {
+	for _, t := range f.Types {
+		switch t {
+		case EventNewBlock, EventNewTx, EventTxFailed, EventReorg, EventMiningStarted, EventMiningStopped:
+		default:
+			return fmt.Errorf("未知的事件类型: %s", t)
+		}
+	}
+	if f.Address != "" {
+		return ValidateAddress(f.Address)
+	}
+	return nil
+}
+
+// Match 判断事件是否满足过滤条件
+func (f *EventFilter) Match(event *Event) bool {
+	if len(f.Types) > 0 {
+		matched := false
+		for _, t := range f.Types {
+			if t == event.Type {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.Address == "" {
+		return true
+	}
+
+	switch {
+	case event.Transaction != nil:
+		return f.involves(event.Transaction)
+	case event.Block != nil:
+		for _, tx := range event.Block.Transactions {
+			if f.involves(tx) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// involves 判断交易的发送方或接收方是否是过滤的地址
+func (f *EventFilter) involves(tx *Transaction) bool {
+	return tx.From == f.Address || tx.To == f.Address
+}
+
+// Subscription 一个事件订阅
+//
+// 事件按发布顺序放入有界的缓冲区。订阅者跟不上时节点不会等待它，而是结束这个订阅：
+// Events 通道被关闭，Err 返回 ErrSlowSubscriber，订阅者可以带上最后收到的Seq重新订阅。
+type Subscription struct {
+	bus    *EventBus
+	filter EventFilter
+	events chan *Event
+	err    error
+}
+
+// Events 返回接收事件的通道，订阅结束时通道被关闭
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Err 返回订阅结束的原因，在 Events 通道关闭后调用；主动取消订阅时返回nil
+func (s *Subscription) Err() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.err
+}
+
+// Unsubscribe 取消订阅，可以重复调用
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s, nil)
+}
+
+// EventBus 把节点事件分发给订阅者
+//
+// 节点在持有锁时发布事件，发布从不阻塞：缓冲区已满的订阅会被结束，而不是拖慢节点。
+type EventBus struct {
+	seq         uint64
+	history     []*Event
+	subscribers map[*Subscription]struct{}
+	closed      bool
+	mu          sync.Mutex
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe 订阅满足过滤条件的事件
+//
+// since大于0时，先补发历史记录中Seq大于since且满足条件的事件；历史记录只保留最近的事件，
+// 更早的事件无法补发，订阅者可以从收到的Seq发现缺口。buffer是缓冲的事件数，不大于0时使用默认值。
+func (b *EventBus) Subscribe(filter EventFilter, since uint64, buffer int) (*Subscription, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrEventBusClosed
+	}
+	var missed []*Event
+	if since > 0 {
+		for _, event := range b.history {
+			if event.Seq > since && filter.Match(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	s := &Subscription{
+		bus:    b,
+		filter: filter,
+		events: make(chan *Event, buffer+len(missed)),
+	}
+	for _, event := range missed {
+		s.events <- event
+	}
+	b.subscribers[s] = struct{}{}
+	return s, nil
+}
+
+// Publish 发布事件，填入Seq和时间后分发给所有匹配的订阅者
+func (b *EventBus) Publish(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.Seq = b.seq
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.history = append(b.history, event)
+	if len(b.history) > maxEventHistory {
+		b.history = b.history[len(b.history)-maxEventHistory:]
+	}
+
+	for s := range b.subscribers {
+		if !s.filter.Match(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			b.remove(s, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Close 结束所有订阅，之后不能再订阅
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s, ErrEventBusClosed)
+	}
+}
+
+// remove 结束订阅并记录原因，调用者需持有锁
+func (b *EventBus) remove(s *Subscription, err error) {
+	if _, exists := b.subscribers[s]; !exists {
+		return
+	}
+	delete(b.subscribers, s)
+	s.err = err
+	close(s.events)
+}
+
+// SubscribeEvents 订阅节点事件，参数见 EventBus.Subscribe
+func (n *Node) SubscribeEvents(filter EventFilter, since uint64, buffer int) (*Subscription, error) {
+	return n.events.Subscribe(filter, since, buffer)
+}
+
+// publish 发布节点事件
+//
+// 启动回放记录时还没有创建事件总线，回放的历史状态不产生事件。
+func (n *Node) publish(event *Event) {
+	if n.events != nil {
+		n.events.Publish(event)
+	}
+}
+
+// publishFailed 为打包失败或被移出交易池的交易发布事件
+func (n *Node) publishFailed(failed []*FailedTransaction) {
+	for _, f := range failed {
+		n.publish(&Event{Type: EventTxFailed, Height: f.Height, Transaction: f.Transaction, Reason: f.Reason})
+	}
+}
@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// nextEvent 从订阅中取出下一个事件，超时时测试失败
+func nextEvent(t *testing.T, sub *Subscription) *Event {
+	t.Helper()
+	select {
+	case event, ok := <-sub.Events():
+		if !ok {
+			t.Fatalf("订阅意外结束: %v", sub.Err())
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待事件超时")
+	}
+	return nil
+}
+
+func TestNodeEvents(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	all, err := node.SubscribeEvents(EventFilter{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer all.Unsubscribe()
+	bob := testAddress("bob")
+	forBob, err := node.SubscribeEvents(EventFilter{Address: bob}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer forBob.Unsubscribe()
+
+	tx, err := node.Transfer(alice.Address, bob, 100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, all); event.Type != EventNewTx || event.Transaction.ID != tx.ID || event.Seq == 0 {
+		t.Fatalf("交易进入交易池的事件 = %+v", event)
+	}
+	if event := nextEvent(t, forBob); event.Type != EventNewTx || event.Transaction.ID != tx.ID {
+		t.Fatalf("按地址过滤的交易事件 = %+v", event)
+	}
+
+	replaced, err := node.ReplaceTransaction(tx.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, all); event.Type != EventNewTx || event.Transaction.ID != replaced.ID {
+		t.Fatalf("替换交易的事件 = %+v", event)
+	}
+	if event := nextEvent(t, all); event.Type != EventTxFailed || event.Transaction.ID != tx.ID || event.Reason == "" {
+		t.Fatalf("被替换的交易的事件 = %+v", event)
+	}
+
+	node.generateNewBlock()
+	if event := nextEvent(t, all); event.Type != EventNewBlock || event.Height != 2 || event.Block.Height != 2 {
+		t.Fatalf("新区块的事件 = %+v", event)
+	}
+
+	// 与bob无关的区块不会发给按地址过滤的订阅
+	node.generateNewBlock()
+	for _, want := range []string{EventNewTx, EventTxFailed, EventNewBlock} {
+		if event := nextEvent(t, forBob); event.Type != want || event.Height > 2 {
+			t.Fatalf("按地址过滤的订阅收到 %+v，期望 %s", event, want)
+		}
+	}
+	select {
+	case event := <-forBob.Events():
+		t.Fatalf("与地址无关的事件不应送达: %+v", event)
+	default:
+	}
+
+	mining, err := node.SubscribeEvents(EventFilter{Types: []string{EventMiningStarted, EventMiningStopped}}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.StartMining()
+	node.StopMining()
+	if event := nextEvent(t, mining); event.Type != EventMiningStarted {
+		t.Fatalf("开始挖矿的事件 = %+v", event)
+	}
+	if event := nextEvent(t, mining); event.Type != EventMiningStopped {
+		t.Fatalf("停止挖矿的事件 = %+v", event)
+	}
+
+	if _, err := node.SubscribeEvents(EventFilter{Types: []string{"unknown"}}, 0, 0); err == nil {
+		t.Fatal("未知的事件类型应被拒绝")
+	}
+	if _, err := node.SubscribeEvents(EventFilter{Address: "cosmos-bob"}, 0, 0); err == nil {
+		t.Fatal("无效的地址应被拒绝")
+	}
+
+	// 节点关闭后缓冲的事件仍可读出，之后通道关闭
+	node.Close()
+	for range all.Events() {
+	}
+	if all.Err() != ErrEventBusClosed {
+		t.Fatalf("订阅结束的原因 = %v", all.Err())
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	bus := NewEventBus()
+	slow, err := bus.Subscribe(EventFilter{}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := bus.Subscribe(EventFilter{}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓冲区满时发布不会阻塞，跟不上的订阅被结束，不影响其他订阅者
+	for i := 1; i <= 3; i++ {
+		bus.Publish(&Event{Type: EventNewBlock, Height: i})
+		if i < 3 {
+			if event := nextEvent(t, fast); event.Height != i {
+				t.Fatalf("第%d个事件的高度 = %d", i, event.Height)
+			}
+		}
+	}
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != 2 || slow.Err() != ErrSlowSubscriber {
+		t.Fatalf("慢订阅者收到 %d 个事件后结束，原因 %v", received, slow.Err())
+	}
+	if event := nextEvent(t, fast); event.Height != 3 {
+		t.Fatalf("第3个事件的高度 = %d", event.Height)
+	}
+
+	// 带上最后收到的序号重新订阅，补发遗漏的事件
+	resumed, err := bus.Subscribe(EventFilter{}, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, resumed); event.Seq != 3 || event.Height != 3 {
+		t.Fatalf("补发的事件 = %+v", event)
+	}
+
+	resumed.Unsubscribe()
+	resumed.Unsubscribe()
+	if _, ok := <-resumed.Events(); ok || resumed.Err() != nil {
+		t.Fatalf("取消订阅后的原因 = %v", resumed.Err())
+	}
+}
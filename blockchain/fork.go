@@ -0,0 +1,319 @@
+package blockchain
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// maxReorgEvents 最多保留的链重组事件数
+const maxReorgEvents = 100
+
+// 分支区块的限制：分叉点离主链末尾太远的分支不再接受，也不再保留在索引中；
+// 不比主链更重的分支区块总数有上限，避免对方不断发送分支区块占满内存和存储
+const (
+	maxForkDepth  = 100  // 分叉点最多落后主链末尾的区块数
+	maxSideBlocks = 1000 // 区块索引中最多保留的分支区块数
+)
+
+// ReorgEvent 描述一次链重组：主链从分叉点之后切换到了另一条更重的分支
+type ReorgEvent struct {
+	OldTip       string    `json:"old_tip"`
+	NewTip       string    `json:"new_tip"`
+	OldHeight    int       `json:"old_height"`
+	NewHeight    int       `json:"new_height"`
+	ForkHeight   int       `json:"fork_height"`  // 两条链最后一个共同区块的高度
+	Disconnected []string  `json:"disconnected"` // 从主链上回滚的区块哈希
+	Connected    []string  `json:"connected"`    // 新接入主链的区块哈希
+	Orphaned     []string  `json:"orphaned"`     // 放回交易池的交易ID
+	Time         time.Time `json:"time"`
+}
+
+// tip 返回主链最后一个区块，还没有区块时返回nil
+func (n *Node) tip() *Block {
+	if len(n.chain) == 0 {
+		return nil
+	}
+	return n.chain[len(n.chain)-1]
+}
+
+// onMainChain 判断区块是否在主链上
+func (n *Node) onMainChain(block *Block) bool {
+	return block.Height >= 1 && block.Height <= len(n.chain) && n.chain[block.Height-1].Hash == block.Hash
+}
+
+// resetBlockIndex 丢弃所有分支，只用主链重建区块索引
+func (n *Node) resetBlockIndex() {
+	n.blocks = make(map[string]*Block, len(n.chain))
+	n.weights = make(map[string]uint64, len(n.chain))
+	var weight uint64
+	for _, block := range n.chain {
+		weight += n.engine.BlockWeight(block)
+		n.blocks[block.Hash] = block
+		n.weights[block.Hash] = weight
+	}
+}
+
+// checkBlock 在写入存储前检查区块能否被接受，调用者需持有锁
+//
+// 区块的父区块可以是主链或分支上的任意已知区块。接在主链末尾的区块会在临时状态上
+// 试执行；分支上的区块要求分叉点不超过 maxForkDepth，并先试着回放以它结尾的整条链，
+// 失败时拒绝该区块。
+func (n *Node) checkBlock(block *Block) error {
+	if _, known := n.blocks[block.Hash]; known {
+		return errKnownBlock
+	}
+
+	var parent *Block
+	if block.Height != 1 {
+		var exists bool
+		if parent, exists = n.blocks[block.PrevHash]; !exists {
+			return errMissingParent
+		}
+	} else if len(n.chain) > 0 {
+		return fmt.Errorf("创世区块与本地区块链不同")
+	}
+
+	if err := validateBlock(block, parent); err != nil {
+		return &ChainValidationError{Height: block.Height, Reason: err.Error()}
+	}
+	if err := checkBlockTime(block, time.Now()); err != nil {
+		return &ChainValidationError{Height: block.Height, Reason: err.Error()}
+	}
+	if err := n.engine.VerifyBlock(block, parent, n.lookupBlock); err != nil {
+		return &ChainValidationError{Height: block.Height, Reason: err.Error()}
+	}
+
+	tip := n.tip()
+	switch {
+	case tip == nil || parent == tip:
+		if _, err := verifyBlockTransactions(n.walletManager, n.includedTxs, block, n.genesis); err != nil {
+			return &ChainValidationError{Height: block.Height, Reason: err.Error()}
+		}
+	default:
+		chain := n.branchChain(parent, block)
+		if forkHeight := n.forkHeight(chain); tip.Height-forkHeight > maxForkDepth {
+			return &ChainValidationError{Height: block.Height, Reason: fmt.Sprintf("分叉点高度 %d 落后主链末尾超过 %d 个区块", forkHeight, maxForkDepth)}
+		}
+		if n.weights[parent.Hash]+n.engine.BlockWeight(block) <= n.weights[tip.Hash] && len(n.blocks)-len(n.chain) >= maxSideBlocks {
+			return fmt.Errorf("分支区块数已达上限 %d", maxSideBlocks)
+		}
+		// 不更重的分支也要回放检查交易，无效的区块不能写入存储
+		if _, _, _, err := n.replayChain(chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// connectBlock 把已检查过的区块加入区块索引，并按分叉选择规则更新主链
+func (n *Node) connectBlock(block *Block, failed []*FailedTransaction) error {
+	var weight uint64
+	if parent, exists := n.blocks[block.PrevHash]; exists {
+		weight = n.weights[parent.Hash]
+	}
+	weight += n.engine.BlockWeight(block)
+
+	tip := n.tip()
+	switch {
+	case tip == nil && block.Height == 1, tip != nil && block.PrevHash == tip.Hash:
+		// 区块中的交易作为一个整体执行，任何一笔失败都不提交该区块
+		if err := executeBlock(n.walletManager, n.includedTxs, block, n.genesis); err != nil {
+			return err
+		}
+		n.chain = append(n.chain, block)
+		n.transactions.append(block)
+
+		// 记录打包失败的交易
+		n.failedTransactions = append(n.failedTransactions, failed...)
+		n.prunePendingTransactions(failed)
+		n.publish(&Event{Type: EventNewBlock, Height: block.Height, Block: block})
+		n.publishFailed(failed)
+	case tip != nil && weight > n.weights[tip.Hash]:
+		if err := n.reorganize(block); err != nil {
+			return err
+		}
+	}
+
+	// 不更重的分支只保存在索引中，将来有更多区块接上时可能切换为主链
+	n.blocks[block.Hash] = block
+	n.weights[block.Hash] = weight
+	n.pruneSideBlocks()
+	return nil
+}
+
+// pruneSideBlocks 从区块索引中删除落后主链末尾超过 maxForkDepth 的分支区块
+//
+// 这些区块的分叉点更早，接在它们之后的区块都会被拒绝，不可能再成为主链。
+func (n *Node) pruneSideBlocks() {
+	minHeight := len(n.chain) - maxForkDepth
+	if minHeight <= 1 || len(n.blocks) == len(n.chain) {
+		return
+	}
+	for hash, block := range n.blocks {
+		if block.Height < minHeight && !n.onMainChain(block) {
+			delete(n.blocks, hash)
+			delete(n.weights, hash)
+		}
+	}
+}
+
+// forkHeight 返回chain与主链最后一个共同区块的高度
+func (n *Node) forkHeight(chain []*Block) int {
+	height := 0
+	for height < len(chain) && height < len(n.chain) && chain[height].Hash == n.chain[height].Hash {
+		height++
+	}
+	return height
+}
+
+// branchChain 返回以newTip结尾的新主链：主链上的共同前缀加上分支上的区块
+func (n *Node) branchChain(parent, newTip *Block) []*Block {
+	branch := []*Block{newTip}
+	for b := parent; b != nil && !n.onMainChain(b); b = n.blocks[b.PrevHash] {
+		branch = append([]*Block{b}, branch...)
+	}
+
+	forkHeight := branch[0].Height - 1
+	chain := make([]*Block, 0, forkHeight+len(branch))
+	chain = append(chain, n.chain[:forkHeight]...)
+	return append(chain, branch...)
+}
+
+// reorganize 把主链切换到以newTip结尾的分支
+//
+// 钱包状态从创世区块开始沿新主链重新执行得到；被回滚的区块中没有进入新主链的交易
+// （挖矿奖励除外）放回交易池，等待重新打包。
+func (n *Node) reorganize(newTip *Block) error {
+	chain := n.branchChain(n.blocks[newTip.PrevHash], newTip)
+	wm, history, included, err := n.replayChain(chain)
+	if err != nil {
+		return err
+	}
+
+	forkHeight := n.forkHeight(chain)
+	oldTip := n.tip()
+	event := &ReorgEvent{
+		OldTip:     oldTip.Hash,
+		NewTip:     newTip.Hash,
+		OldHeight:  oldTip.Height,
+		NewHeight:  newTip.Height,
+		ForkHeight: forkHeight,
+		Time:       time.Now(),
+	}
+	disconnected := n.chain[forkHeight:]
+	for _, block := range disconnected {
+		event.Disconnected = append(event.Disconnected, block.Hash)
+	}
+	for _, block := range chain[forkHeight:] {
+		event.Connected = append(event.Connected, block.Hash)
+	}
+
+	n.chain = chain
+	n.walletManager = wm
+	n.transactions = history
+	n.includedTxs = included
+
+	for _, block := range disconnected {
+		for _, tx := range block.Transactions {
+			if tx.Type == TxTypeMining || tx.Type == TxTypeFee {
+				continue
+			}
+			if _, stillIncluded := included[tx.ID]; stillIncluded {
+				continue
+			}
+			if _, err := n.mempool.Add(tx, time.Now()); err == nil {
+				event.Orphaned = append(event.Orphaned, tx.ID)
+			}
+		}
+	}
+	n.prunePendingTransactions(nil)
+
+	n.reorgEvents = append(n.reorgEvents, event)
+	if len(n.reorgEvents) > maxReorgEvents {
+		n.reorgEvents = n.reorgEvents[len(n.reorgEvents)-maxReorgEvents:]
+	}
+	log.Printf("链重组：高度 %d 之后回滚 %d 个区块、接入 %d 个区块，%d 笔交易放回交易池",
+		forkHeight, len(event.Disconnected), len(event.Connected), len(event.Orphaned))
+
+	n.publish(&Event{Type: EventReorg, Height: newTip.Height, Reorg: event})
+	for _, block := range chain[forkHeight:] {
+		n.publish(&Event{Type: EventNewBlock, Height: block.Height, Block: block})
+	}
+	return nil
+}
+
+// BlockLocator 返回用于同步的主链区块哈希列表
+//
+// 从链尾开始，前10个区块逐个列出，之后间隔成倍增加，最后总是包含创世区块，
+// 对方据此找到两条链最后一个共同区块。
+func (n *Node) BlockLocator() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	var locator []string
+	step := 1
+	for i := len(n.chain) - 1; i >= 0; i -= step {
+		locator = append(locator, n.chain[i].Hash)
+		if len(locator) >= 10 {
+			step *= 2
+		}
+	}
+	if len(n.chain) > 0 && locator[len(locator)-1] != n.chain[0].Hash {
+		locator = append(locator, n.chain[0].Hash)
+	}
+	return locator
+}
+
+// GetBlocksAfter 返回主链上位于locator中第一个已知区块之后的至多limit个区块
+//
+// locator中没有任何区块在本地主链上时，从创世区块开始返回。
+func (n *Node) GetBlocksAfter(locator []string, limit int) []*Block {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	start := 0
+	for _, hash := range locator {
+		if block, exists := n.blocks[hash]; exists && n.onMainChain(block) {
+			start = block.Height
+			break
+		}
+	}
+
+	end := start + limit
+	if end > len(n.chain) {
+		end = len(n.chain)
+	}
+	if start >= end {
+		return nil
+	}
+	result := make([]*Block, end-start)
+	copy(result, n.chain[start:end])
+	return result
+}
+
+// GetReorgEvents 获取最近的链重组事件
+func (n *Node) GetReorgEvents() []*ReorgEvent {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	result := make([]*ReorgEvent, len(n.reorgEvents))
+	copy(result, n.reorgEvents)
+	return result
+}
+
+// GetSideBlocks 获取不在主链上的分支区块
+func (n *Node) GetSideBlocks() []*Block {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	var result []*Block
+	for _, block := range n.blocks {
+		if !n.onMainChain(block) {
+			result = append(result, block)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Height < result[j].Height })
+	return result
+}
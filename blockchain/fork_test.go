@@ -0,0 +1,133 @@
+package blockchain
+
+import "testing"
+
+func TestReorgToHeavierBranch(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := a.CreateWallet()
+	a.generateNewBlock()
+
+	// b 与 a 共享前两个区块，之后各自出块
+	b, _ := NewNode(NodeConfig{BlockTime: 1})
+	for _, block := range a.GetAllBlocks() {
+		if err := b.AddPeerBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx, err := a.Transfer(a.GetMinerAddress(), alice.Address, 50, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.generateNewBlock()
+	b.generateNewBlock()
+	b.generateNewBlock()
+	branch := b.GetAllBlocks()
+
+	// 同样重的分支只保存下来，不切换主链
+	if err := a.AddPeerBlock(branch[2]); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.GetSideBlocks()) != 1 || a.GetBlockByHeight(3).Hash == branch[2].Hash {
+		t.Fatal("同样重的分支不应替换主链")
+	}
+
+	if err := a.AddPeerBlock(branch[3]); err != nil {
+		t.Fatal(err)
+	}
+	if a.GetHeight() != 4 || a.GetBlockByHeight(4).Hash != branch[3].Hash {
+		t.Fatal("更重的分支应成为主链")
+	}
+	if a.GetStateHash() != b.GetStateHash() || a.GetBalance(alice.Address) != 0 {
+		t.Fatal("重组后的余额应由新主链重新执行得到")
+	}
+	if !a.mempool.Contains(tx.ID) {
+		t.Fatal("被回滚的转账应放回交易池")
+	}
+	events := a.GetReorgEvents()
+	if len(events) != 1 || events[0].ForkHeight != 2 || len(events[0].Disconnected) != 1 ||
+		len(events[0].Connected) != 2 || len(events[0].Orphaned) != 1 || events[0].Orphaned[0] != tx.ID {
+		t.Fatalf("重组事件不正确: %+v", events)
+	}
+
+	a.generateNewBlock()
+	if got := a.GetBalance(alice.Address); got != 50 {
+		t.Fatalf("重新打包后余额应为50，实际为%d", got)
+	}
+	tip := a.GetBlockByHeight(5).Hash
+	a.Close()
+
+	recovered, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	if recovered.GetHeight() != 5 || recovered.GetBlockByHeight(5).Hash != tip {
+		t.Fatal("重启后应恢复重组之后的主链")
+	}
+	if len(recovered.GetSideBlocks()) != 1 {
+		t.Fatal("重启后应保留分支区块")
+	}
+}
+
+func TestSideBranchesAreCheckedAndPruned(t *testing.T) {
+	a, err := NewNode(NodeConfig{BlockTime: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	a.generateNewBlock()
+	b, _ := NewNode(NodeConfig{BlockTime: 1})
+	for _, block := range a.GetAllBlocks() {
+		if err := b.AddPeerBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.generateNewBlock()
+	b.generateNewBlock()
+	side := b.GetBlockByHeight(3)
+
+	// 分支区块中的交易同样要检查，没有签名的转账使区块被拒绝
+	forged := *side
+	unsigned := &Transaction{Type: TxTypeTransfer, From: a.GetMinerAddress(), To: testAddress("bob"), Amount: 10, Fee: 1, Timestamp: side.Timestamp.Unix()}
+	unsigned.ID = unsigned.Hash()
+	forged.Transactions = append([]*Transaction{side.Transactions[0], unsigned}, side.Transactions[1:]...)
+	forged.TxRoot = CalculateTxRoot(forged.Transactions)
+	forged.Hash = calculateBlockHash(&forged)
+	if forged.Signature, err = b.signBlock(&forged); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddPeerBlock(&forged); err == nil {
+		t.Fatal("包含无效交易的分支区块应被拒绝")
+	}
+	if len(a.GetSideBlocks()) != 0 {
+		t.Fatal("被拒绝的分支区块不应进入区块索引")
+	}
+
+	if err := a.AddPeerBlock(side); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.GetSideBlocks()) != 1 {
+		t.Fatal("有效的分支区块应保存在区块索引中")
+	}
+
+	// 主链前进后，分叉点太早的分支被删除，接在它后面的区块也被拒绝
+	for i := 0; i <= maxForkDepth; i++ {
+		a.generateNewBlock()
+	}
+	if len(a.GetSideBlocks()) != 0 {
+		t.Fatal("分叉点太早的分支应从区块索引中删除")
+	}
+	b.generateNewBlock()
+	if err := a.AddPeerBlock(b.GetBlockByHeight(4)); err == nil {
+		t.Fatal("分叉点落后主链末尾太多的区块应被拒绝")
+	}
+}
@@ -0,0 +1,223 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// GenesisValidator 创世文件中的验证者
+type GenesisValidator struct {
+	Name   string `json:"name,omitempty"`
+	PubKey string `json:"pub_key"` // 压缩格式的secp256k1公钥（十六进制）
+}
+
+// GenesisAccount 创世时分配了初始余额的账户
+type GenesisAccount struct {
+	Address string `json:"address"`
+	Balance int64  `json:"balance"`
+}
+
+// FeePolicy 转账手续费规则
+type FeePolicy struct {
+	MinFee      int64  `json:"min_fee"`            // 区块和交易池接受的最低基础代币手续费
+	MinFees     []Coin `json:"min_fees,omitempty"` // 允许用其他代币支付手续费时各代币的最低手续费，未列出的代币不能支付手续费
+	DefaultFee  int64  `json:"default_fee"`        // 转账未指定手续费时使用的手续费
+	Destination string `json:"destination"`        // 手续费去向：burn（销毁，默认）或producer（支付给出块者）
+}
+
+// minFeeOf 返回用指定代币支付时的最低手续费，不接受该代币时返回false
+func (p FeePolicy) minFeeOf(denom string) (int64, bool) {
+	if denom = denomOf(denom); denom == BaseDenom {
+		return p.MinFee, true
+	}
+	for _, coin := range p.MinFees {
+		if coin.Denom == denom {
+			return coin.Amount, true
+		}
+	}
+	return 0, false
+}
+
+// checkFee 检查交易的手续费代币是否被接受，且手续费不低于该代币的最低手续费
+func (p FeePolicy) checkFee(tx *Transaction) error {
+	denom := denomOf(tx.FeeDenom)
+	minFee, accepted := p.minFeeOf(denom)
+	if !accepted {
+		return fmt.Errorf("不接受用 %s 支付手续费", denom)
+	}
+	if tx.Fee < minFee {
+		return fmt.Errorf("手续费 %d %s 低于最低手续费 %d", tx.Fee, denom, minFee)
+	}
+	return nil
+}
+
+// GenesisDoc 描述链的初始配置
+//
+// 创世文件决定了创世区块的内容：区块时间为 GenesisTime，初始分配作为系统交易放在区块中，
+// 区块数据记录链ID和创世文件的哈希。使用同一份创世文件的节点会得到完全相同的创世区块。
+type GenesisDoc struct {
+	ChainID        string             `json:"chain_id"`
+	GenesisTime    time.Time          `json:"genesis_time"`
+	Accounts       []GenesisAccount   `json:"accounts"`
+	Validators     []GenesisValidator `json:"validators"`
+	BlockTime      int                `json:"block_time"`    // 出块间隔（秒）
+	MiningReward   int64              `json:"mining_reward"` // 初始的出块奖励，之后按货币政策变化
+	FeePolicy      FeePolicy          `json:"fee_policy"`
+	MonetaryPolicy MonetaryPolicy     `json:"monetary_policy"`
+}
+
+// defaultGenesisDoc 没有创世文件时使用的链参数，创世区块仍通过 CreateGenesisBlock 创建
+func defaultGenesisDoc(blockTime int) *GenesisDoc {
+	return &GenesisDoc{
+		ChainID:      "cosmos-demo",
+		BlockTime:    blockTime,
+		MiningReward: 100,
+		FeePolicy:    FeePolicy{MinFee: 0, DefaultFee: 1, Destination: FeesBurn},
+	}
+}
+
+// LoadGenesis 读取并校验创世文件
+func LoadGenesis(filename string) (*GenesisDoc, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("读取创世文件失败: %v", err)
+	}
+
+	var doc GenesisDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析创世文件失败: %v", err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, fmt.Errorf("创世文件无效: %v", err)
+	}
+	doc.GenesisTime = doc.GenesisTime.UTC()
+	return &doc, nil
+}
+
+// Validate 检查创世文件的内容
+func (g *GenesisDoc) Validate() error {
+	if g.ChainID == "" {
+		return fmt.Errorf("缺少chain_id")
+	}
+	if g.GenesisTime.IsZero() {
+		return fmt.Errorf("缺少genesis_time")
+	}
+	if g.BlockTime <= 0 {
+		return fmt.Errorf("block_time必须大于0")
+	}
+	if g.MiningReward < 0 {
+		return fmt.Errorf("mining_reward不能为负")
+	}
+	if g.FeePolicy.MinFee < 0 || g.FeePolicy.DefaultFee < g.FeePolicy.MinFee {
+		return fmt.Errorf("手续费规则无效：min_fee不能为负，default_fee不能低于min_fee")
+	}
+	if d := g.FeePolicy.Destination; d != "" && d != FeesBurn && d != FeesProducer {
+		return fmt.Errorf("不支持的手续费去向: %s", d)
+	}
+	feeDenoms := make(map[string]bool, len(g.FeePolicy.MinFees))
+	for _, coin := range g.FeePolicy.MinFees {
+		if err := validateDenom(coin.Denom); err != nil {
+			return fmt.Errorf("min_fees: %v", err)
+		}
+		if coin.Denom == BaseDenom || feeDenoms[coin.Denom] {
+			return fmt.Errorf("min_fees中的代币 %s 重复", coin.Denom)
+		}
+		if coin.Amount < 0 {
+			return fmt.Errorf("min_fees中代币 %s 的最低手续费不能为负", coin.Denom)
+		}
+		feeDenoms[coin.Denom] = true
+	}
+	if err := g.MonetaryPolicy.Validate(); err != nil {
+		return err
+	}
+
+	var allocated int64
+	accounts := make(map[string]bool, len(g.Accounts))
+	for i, account := range g.Accounts {
+		if err := ValidateAddress(account.Address); err != nil {
+			return fmt.Errorf("第%d个账户: %v", i+1, err)
+		}
+		if account.Balance <= 0 {
+			return fmt.Errorf("账户 %s 的初始余额必须大于0", account.Address)
+		}
+		if accounts[account.Address] {
+			return fmt.Errorf("账户 %s 重复", account.Address)
+		}
+		accounts[account.Address] = true
+		allocated += account.Balance
+	}
+	if limit := g.MonetaryPolicy.SupplyCap; limit > 0 && allocated > limit {
+		return fmt.Errorf("创世分配总额 %d 超过供应上限 %d", allocated, limit)
+	}
+
+	seen := make(map[string]bool, len(g.Validators))
+	for i, v := range g.Validators {
+		if _, err := parsePublicKey(v.PubKey); err != nil {
+			return fmt.Errorf("第%d个验证者的公钥无效: %v", i+1, err)
+		}
+		if seen[v.PubKey] {
+			return fmt.Errorf("验证者 %s 重复", v.PubKey)
+		}
+		seen[v.PubKey] = true
+	}
+	return nil
+}
+
+// ValidatorKeys 按创世文件中的顺序返回验证者公钥
+func (g *GenesisDoc) ValidatorKeys() []string {
+	keys := make([]string, len(g.Validators))
+	for i, v := range g.Validators {
+		keys[i] = v.PubKey
+	}
+	return keys
+}
+
+// Hash 返回创世文件规范JSON编码的SHA256哈希
+func (g *GenesisDoc) Hash() string {
+	data, _ := json.Marshal(g)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Block 由创世文件生成创世区块
+//
+// 创世区块没有出块者和签名，它的正确性来自与本地创世文件生成的区块完全一致。
+func (g *GenesisDoc) Block() *Block {
+	txs := make([]*Transaction, 0, len(g.Accounts))
+	for _, account := range g.Accounts {
+		txs = append(txs, &Transaction{
+			ID:        "genesis-" + account.Address,
+			Type:      TxTypeGenesis,
+			From:      "system",
+			To:        account.Address,
+			Amount:    account.Balance,
+			Timestamp: g.GenesisTime.Unix(),
+			Signature: "system-genesis",
+		})
+	}
+
+	block := &Block{
+		Height:       1,
+		Timestamp:    g.GenesisTime,
+		Data:         fmt.Sprintf("%s:%s", g.ChainID, g.Hash()),
+		Transactions: txs,
+		TxRoot:       CalculateTxRoot(txs),
+		PrevHash:     genesisPrevHash,
+	}
+	block.Hash = calculateBlockHash(block)
+	return block
+}
+
+// isDocumentGenesis 判断区块是否是由创世文件生成的创世区块
+func isDocumentGenesis(block *Block) bool {
+	return block.Height == 1 && block.Validator == ""
+}
+
+// GetGenesisDoc 获取节点使用的链参数
+func (n *Node) GetGenesisDoc() *GenesisDoc {
+	return n.genesis
+}
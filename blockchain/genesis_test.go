@@ -0,0 +1,172 @@
+package blockchain
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testGenesisDoc 返回给address分配了初始余额的创世文件
+func testGenesisDoc(address string) *GenesisDoc {
+	return &GenesisDoc{
+		ChainID:      "test-chain",
+		GenesisTime:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Accounts:     []GenesisAccount{{Address: address, Balance: 5000}},
+		BlockTime:    1,
+		MiningReward: 50,
+		FeePolicy:    FeePolicy{MinFee: 2, DefaultFee: 3},
+	}
+}
+
+func TestLoadGenesisValidates(t *testing.T) {
+	key, _ := GeneratePrivateKey()
+	pubKey, _ := PublicKeyFromPrivate(key)
+	base := `"chain_id":"demo","genesis_time":"2024-01-01T00:00:00Z","block_time":5,"mining_reward":10`
+
+	cases := []struct {
+		name    string
+		content string
+		ok      bool
+	}{
+		{"有效", `{` + base + `,"accounts":[{"address":"` + testAddress("a") + `","balance":100}],"validators":[{"name":"v1","pub_key":"` + pubKey + `"}]}`, true},
+		{"缺少chain_id", `{"genesis_time":"2024-01-01T00:00:00Z","block_time":5}`, false},
+		{"缺少genesis_time", `{"chain_id":"demo","block_time":5}`, false},
+		{"出块间隔无效", `{"chain_id":"demo","genesis_time":"2024-01-01T00:00:00Z"}`, false},
+		{"余额无效", `{` + base + `,"accounts":[{"address":"` + testAddress("a") + `","balance":0}]}`, false},
+		{"账户重复", `{` + base + `,"accounts":[{"address":"` + testAddress("a") + `","balance":1},{"address":"` + testAddress("a") + `","balance":2}]}`, false},
+		{"手续费规则无效", `{` + base + `,"fee_policy":{"min_fee":5,"default_fee":1}}`, false},
+		{"公钥无效", `{` + base + `,"validators":[{"pub_key":"abcd"}]}`, false},
+		{"公钥重复", `{` + base + `,"validators":[{"pub_key":"` + pubKey + `"},{"pub_key":"` + pubKey + `"}]}`, false},
+	}
+	for _, c := range cases {
+		file := filepath.Join(t.TempDir(), "genesis.json")
+		if err := os.WriteFile(file, []byte(c.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		doc, err := LoadGenesis(file)
+		if (err == nil) != c.ok {
+			t.Fatalf("%s: err = %v", c.name, err)
+		}
+		if c.ok && doc.ValidatorKeys()[0] != pubKey {
+			t.Fatalf("%s: 验证者公钥不一致", c.name)
+		}
+	}
+}
+
+func TestGenesisDocDeterminesChain(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := testGenesisDoc(alice.Address)
+
+	a, err := NewNode(NodeConfig{Genesis: doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewNode(NodeConfig{Genesis: testGenesisDoc(alice.Address)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一份创世文件得到相同的创世区块，区块数据中记录了创世文件的哈希
+	genesis := a.GetBlockByHeight(1)
+	if genesis.Hash != b.GetGenesisHash() {
+		t.Fatal("同一份创世文件应生成相同的创世区块")
+	}
+	if !strings.HasSuffix(genesis.Data, doc.Hash()) {
+		t.Fatalf("创世区块没有记录创世文件哈希: %s", genesis.Data)
+	}
+	if a.GetBalance(alice.Address) != 5000 {
+		t.Fatalf("初始分配余额 = %d，期望 5000", a.GetBalance(alice.Address))
+	}
+	if err := a.CreateGenesisBlock("again"); err == nil {
+		t.Fatal("使用创世文件时不应再创建创世区块")
+	}
+
+	// 手续费低于min_fee的转账被拒绝（把alice的私钥放入节点，余额沿用链上状态）
+	alice.Balance = a.GetBalance(alice.Address)
+	a.walletManager.RestoreWallet(alice)
+	if _, err := a.Transfer(alice.Address, testAddress("bob"), 100, 1); err == nil {
+		t.Fatal("手续费低于最低手续费的转账应被拒绝")
+	}
+	if _, err := a.Transfer(alice.Address, testAddress("bob"), 100, a.DefaultFee()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 出块奖励来自创世文件，另一个使用同一份创世文件的节点接受该区块
+	a.generateNewBlock()
+	block := a.GetBlockByHeight(2)
+	if block.Transactions[0].Amount != 50 {
+		t.Fatalf("出块奖励 = %d，期望 50", block.Transactions[0].Amount)
+	}
+	if err := b.AddPeerBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if b.GetBalance(testAddress("bob")) != 100 || b.GetBalance(alice.Address) != 5000-100-3 {
+		t.Fatal("同步区块后的余额不正确")
+	}
+	if err := b.ValidateChain(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 使用不同创世文件的节点不接受这条链上的区块
+	other := testGenesisDoc(alice.Address)
+	other.MiningReward = 60
+	c, err := NewNode(NodeConfig{Genesis: other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddPeerBlock(block); err == nil {
+		t.Fatal("不同创世文件的节点应拒绝该区块")
+	}
+}
+
+func TestGenesisDocMustMatchDataDir(t *testing.T) {
+	dir := t.TempDir()
+	doc := testGenesisDoc(testAddress("alice"))
+
+	node, err := NewNode(NodeConfig{DataDir: dir, Genesis: doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.Close()
+
+	// 使用同一份创世文件重启
+	node, err = NewNode(NodeConfig{DataDir: dir, Genesis: testGenesisDoc(testAddress("alice"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.GetHeight() != 1 {
+		t.Fatalf("重启后高度 = %d，期望 1", node.GetHeight())
+	}
+	node.Close()
+
+	// 换一份创世文件启动同一个数据目录
+	other := testGenesisDoc(testAddress("alice"))
+	other.ChainID = "another-chain"
+	if _, err := NewNode(NodeConfig{DataDir: dir, Genesis: other}); err == nil {
+		t.Fatal("创世文件与数据目录不一致时应拒绝启动")
+	}
+}
+
+func TestImportRejectsChainFromOtherGenesis(t *testing.T) {
+	node, err := NewNode(NodeConfig{Genesis: testGenesisDoc(testAddress("alice"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := node.GetGenesisHash()
+
+	file := filepath.Join(t.TempDir(), "chain.json")
+	if err := newTestChain(t, 3).ExportBlockchain(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.ImportBlockchain(file); err == nil {
+		t.Fatal("使用创世文件的节点不应导入创世区块不同的区块链")
+	}
+	if node.GetGenesisHash() != genesis || node.GetHeight() != 1 {
+		t.Fatal("导入失败后本地区块链不应改变")
+	}
+}
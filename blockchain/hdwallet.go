@@ -0,0 +1,195 @@
+package blockchain
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cosmos/go-bip39"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// 分层确定性钱包：BIP-39助记词生成种子，BIP-32从种子派生密钥，路径遵循BIP-44。
+// 与Cosmos SDK一致，币种编号为118，第i个账户的路径是 m/44'/118'/0'/0/i。
+
+const (
+	CoinType          = 118                // Cosmos在SLIP-44中的币种编号
+	MnemonicEntropy   = 256                // 助记词的熵（比特），对应24个单词
+	hardenedOffset    = uint32(0x80000000) // 硬化派生的索引偏移
+	masterKeyHMACSalt = "Bitcoin seed"     // BIP-32主密钥的HMAC密钥
+	maxHDAccounts     = 100                // 一次恢复的最大账户数量
+)
+
+// HDPath 返回第index个账户的BIP-44派生路径
+func HDPath(index uint32) string {
+	return fmt.Sprintf("m/44'/%d'/0'/0/%d", CoinType, index)
+}
+
+// NewMnemonic 生成24个单词的BIP-39助记词
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(MnemonicEntropy)
+	if err != nil {
+		return "", fmt.Errorf("生成助记词失败: %v", err)
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// DeriveWallet 由助记词和派生路径得到钱包，bip39Passphrase是助记词的附加口令（可以为空）
+func DeriveWallet(mnemonic, bip39Passphrase, path string) (*Wallet, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, bip39Passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("助记词无效: %v", err)
+	}
+	key, err := deriveKey(seed, path)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := hex.EncodeToString(key)
+	publicKey, err := PublicKeyFromPrivate(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{Address: addressOf(publicKey), PublicKey: publicKey, PrivateKey: privateKey}, nil
+}
+
+// deriveKey 按BIP-32从种子派生路径上的私钥
+func deriveKey(seed []byte, path string) ([]byte, error) {
+	indexes, err := parseHDPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte(masterKeyHMACSalt))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+	if !validScalar(key) {
+		return nil, fmt.Errorf("种子派生的主密钥无效")
+	}
+
+	for _, index := range indexes {
+		if key, chainCode, err = deriveChild(key, chainCode, index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// deriveChild 派生子私钥：硬化索引使用父私钥，普通索引使用父公钥
+func deriveChild(key, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	data := make([]byte, 0, 37)
+	if index >= hardenedOffset {
+		data = append(append(data, 0), key...)
+	} else {
+		var scalar secp256k1.ModNScalar
+		scalar.SetByteSlice(key)
+		data = append(data, secp256k1.NewPrivateKey(&scalar).PubKey().SerializeCompressed()...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	// 子私钥 = IL + 父私钥 (mod n)；IL超出曲线阶或结果为零的概率可以忽略，按BIP-32的规定报错
+	var tweak, parent secp256k1.ModNScalar
+	if overflow := tweak.SetByteSlice(sum[:32]); overflow {
+		return nil, nil, fmt.Errorf("索引 %d 派生的子密钥无效", index)
+	}
+	parent.SetByteSlice(key)
+	tweak.Add(&parent)
+	if tweak.IsZero() {
+		return nil, nil, fmt.Errorf("索引 %d 派生的子密钥无效", index)
+	}
+	child := tweak.Bytes()
+	return child[:], sum[32:], nil
+}
+
+// validScalar 判断32字节是否是有效的secp256k1私钥
+func validScalar(key []byte) bool {
+	var scalar secp256k1.ModNScalar
+	overflow := scalar.SetByteSlice(key)
+	return !overflow && !scalar.IsZero()
+}
+
+// parseHDPath 解析形如 m/44'/118'/0'/0/0 的派生路径，硬化索引以'或h结尾
+func parseHDPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("派生路径 %s 必须以m开头", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= uint64(hardenedOffset) {
+			return nil, fmt.Errorf("派生路径 %s 中的索引 %q 无效", path, part)
+		}
+		if hardened {
+			index += uint64(hardenedOffset)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// CreateHDWallet 生成新的助记词，并创建它派生的第一个账户（m/44'/118'/0'/0/0）
+//
+// 私钥用passphrase加密保存到密钥库，钱包创建后处于解锁状态。助记词只在这里返回一次，节点不保存，
+// 之后可以通过 RecoverHDWallets 恢复同一组账户。
+func (n *Node) CreateHDWallet(passphrase string) (string, *WalletInfo, error) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		return "", nil, err
+	}
+	path := HDPath(0)
+	wallet, err := DeriveWallet(mnemonic, "", path)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := n.addEncryptedWallet(wallet, passphrase, path, true)
+	if err != nil {
+		return "", nil, err
+	}
+	return mnemonic, info, nil
+}
+
+// RecoverHDWallets 从助记词恢复索引在[start, start+count)之间的账户
+//
+// 私钥用passphrase加密保存到密钥库，恢复的钱包保持锁定。已经存在的账户只补充密钥，余额不变。
+func (n *Node) RecoverHDWallets(mnemonic, passphrase string, start, count uint32) ([]*WalletInfo, error) {
+	if count == 0 || count > maxHDAccounts {
+		return nil, fmt.Errorf("一次恢复的账户数量必须在1到%d之间", maxHDAccounts)
+	}
+	if start+count < start || start+count > hardenedOffset {
+		return nil, fmt.Errorf("账户索引超出范围")
+	}
+	if !bip39.IsMnemonicValid(strings.Join(strings.Fields(mnemonic), " ")) {
+		return nil, fmt.Errorf("助记词无效")
+	}
+
+	infos := make([]*WalletInfo, 0, count)
+	for index := start; index < start+count; index++ {
+		path := HDPath(index)
+		wallet, err := DeriveWallet(mnemonic, "", path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := n.addEncryptedWallet(wallet, passphrase, path, false)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDeriveKeyBIP32Vectors(t *testing.T) {
+	// BIP-32 测试向量1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	vectors := []struct {
+		path string
+		key  string
+	}{
+		{"m", "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+		{"m/0'", "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0h/1/2h", "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+	}
+	for _, v := range vectors {
+		key, err := deriveKey(seed, v.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(key) != v.key {
+			t.Fatalf("路径 %s 派生的私钥 = %x，期望 %s", v.path, key, v.key)
+		}
+	}
+
+	for _, path := range []string{"", "44'/118'", "m/x", "m/2147483648", "m//0"} {
+		if _, err := deriveKey(seed, path); err == nil {
+			t.Fatalf("派生路径 %q 应被拒绝", path)
+		}
+	}
+}
+
+func TestDeriveWalletFromMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := strings.Fields(mnemonic); len(words) != 24 {
+		t.Fatalf("助记词有 %d 个单词，期望 24 个", len(words))
+	}
+
+	first, err := DeriveWallet(mnemonic, "", HDPath(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := DeriveWallet("  "+strings.ReplaceAll(mnemonic, " ", "\n")+" ", "", HDPath(0))
+	second, _ := DeriveWallet(mnemonic, "", HDPath(1))
+	protected, _ := DeriveWallet(mnemonic, "extra", HDPath(0))
+	if again == nil || again.PrivateKey != first.PrivateKey {
+		t.Fatal("同一助记词和路径应派生出相同的私钥")
+	}
+	if second.Address == first.Address || protected.Address == first.Address {
+		t.Fatal("不同路径或附加口令应派生出不同的账户")
+	}
+	if HDPath(1) != "m/44'/118'/0'/0/1" {
+		t.Fatalf("派生路径 = %s", HDPath(1))
+	}
+
+	// 12个abandon的校验和不正确，正确的最后一个单词是about
+	if _, err := DeriveWallet(strings.Repeat("abandon ", 11)+"about", "", HDPath(0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeriveWallet(strings.Repeat("abandon ", 12), "", HDPath(0)); err == nil {
+		t.Fatal("校验和错误的助记词应被拒绝")
+	}
+}
+
+func TestRecoverHDWallets(t *testing.T) {
+	a, err := NewNode(NodeConfig{LightKDF: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, created, err := a.CreateHDWallet("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.HDPath != HDPath(0) || !created.Unlocked {
+		t.Fatalf("创建的助记词钱包 = %+v", created)
+	}
+
+	// 另一个节点用同一助记词恢复出相同的账户，恢复的钱包保持锁定
+	b, err := NewNode(NodeConfig{LightKDF: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := b.RecoverHDWallets(mnemonic, "other", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 3 || recovered[0].Address != created.Address || recovered[0].Unlocked {
+		t.Fatalf("恢复的钱包 = %+v", recovered)
+	}
+	if recovered[2].HDPath != HDPath(2) {
+		t.Fatalf("第3个账户的派生路径 = %s", recovered[2].HDPath)
+	}
+	if err := b.UnlockWallet(recovered[1].Address, "other", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.RecoverHDWallets("not a valid mnemonic", "other", 0, 1); err == nil {
+		t.Fatal("无效的助记词应被拒绝")
+	}
+	if _, err := b.RecoverHDWallets(mnemonic, "other", 0, 0); err == nil {
+		t.Fatal("账户数量为0时应被拒绝")
+	}
+}
@@ -0,0 +1,433 @@
+package blockchain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 密钥库文件使用的scrypt参数
+const (
+	StandardScryptN = 1 << 18 // 默认参数，派生一次密钥约需要数百毫秒
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12 // 轻量参数，只用于测试和开发
+	LightScryptP    = 6
+
+	scryptR      = 8
+	scryptKeyLen = 32 // AES-256
+	keystoreKDF  = "scrypt"
+	keystoreAEAD = "aes-256-gcm"
+)
+
+// 导入的密钥库文件中scrypt参数的上限，防止构造的文件耗尽内存和CPU
+const (
+	maxScryptN      = 1 << 20
+	maxScryptR      = 32
+	maxScryptP      = 16
+	maxScryptMemory = 256 << 20 // scrypt需要约 128*N*R 字节内存，与默认参数相同
+)
+
+// KeystoreFile 加密保存的钱包私钥
+//
+// 私钥用scrypt从口令派生的密钥以AES-GCM加密，地址作为附加数据参与认证，
+// 文件被篡改或口令错误时都无法解密。
+type KeystoreFile struct {
+	Version   int            `json:"version"`
+	Address   string         `json:"address"`
+	PublicKey string         `json:"public_key"`
+	HDPath    string         `json:"hd_path,omitempty"` // 由助记词派生的钱包的派生路径
+	Crypto    KeystoreCrypto `json:"crypto"`
+}
+
+// KeystoreCrypto 密钥库文件的加密参数和密文
+type KeystoreCrypto struct {
+	KDF        string       `json:"kdf"`
+	KDFParams  ScryptParams `json:"kdf_params"`
+	Cipher     string       `json:"cipher"`
+	Nonce      string       `json:"nonce"`
+	Ciphertext string       `json:"ciphertext"`
+}
+
+// ScryptParams scrypt密钥派生参数
+type ScryptParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+// EncryptKey 用口令加密钱包的私钥
+func EncryptKey(wallet *Wallet, passphrase string, scryptN, scryptP int) (*KeystoreFile, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("口令不能为空")
+	}
+	if wallet.PrivateKey == "" {
+		return nil, fmt.Errorf("钱包 %s 没有可用的私钥", wallet.Address)
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params := ScryptParams{N: scryptN, R: scryptR, P: scryptP, Salt: hex.EncodeToString(salt)}
+	aead, err := params.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(wallet.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("私钥格式无效: %v", err)
+	}
+
+	return &KeystoreFile{
+		Version:   1,
+		Address:   wallet.Address,
+		PublicKey: wallet.PublicKey,
+		Crypto: KeystoreCrypto{
+			KDF:        keystoreKDF,
+			KDFParams:  params,
+			Cipher:     keystoreAEAD,
+			Nonce:      hex.EncodeToString(nonce),
+			Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, key, []byte(wallet.Address))),
+		},
+	}, nil
+}
+
+// DecryptKey 用口令解密密钥库文件，返回带私钥的钱包
+func DecryptKey(file *KeystoreFile, passphrase string) (*Wallet, error) {
+	if file.Crypto.KDF != keystoreKDF || file.Crypto.Cipher != keystoreAEAD {
+		return nil, fmt.Errorf("不支持的加密方式: %s/%s", file.Crypto.KDF, file.Crypto.Cipher)
+	}
+	aead, err := file.Crypto.KDFParams.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(file.Crypto.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("密钥库文件的nonce无效")
+	}
+	ciphertext, err := hex.DecodeString(file.Crypto.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("密钥库文件的密文无效")
+	}
+	key, err := aead.Open(nil, nonce, ciphertext, []byte(file.Address))
+	if err != nil {
+		return nil, fmt.Errorf("口令错误或密钥库文件已损坏")
+	}
+
+	privateKey := hex.EncodeToString(key)
+	publicKey, err := PublicKeyFromPrivate(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if publicKey != file.PublicKey || addressOf(publicKey) != file.Address {
+		return nil, fmt.Errorf("密钥库文件中的私钥与地址不匹配")
+	}
+	return &Wallet{Address: file.Address, PublicKey: publicKey, PrivateKey: privateKey}, nil
+}
+
+// aead 由口令和scrypt参数派生AES-GCM密钥
+func (p ScryptParams) aead(passphrase string) (cipher.AEAD, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	salt, err := hex.DecodeString(p.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("scrypt的salt无效")
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, p.N, p.R, p.P, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("scrypt参数无效: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// validate 检查scrypt参数在允许的范围内
+func (p ScryptParams) validate() error {
+	if p.N < 2 || p.N > maxScryptN || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("scrypt参数N必须是不超过 %d 的2的幂", maxScryptN)
+	}
+	if p.R < 1 || p.R > maxScryptR {
+		return fmt.Errorf("scrypt参数r必须在1到%d之间", maxScryptR)
+	}
+	if p.P < 1 || p.P > maxScryptP {
+		return fmt.Errorf("scrypt参数p必须在1到%d之间", maxScryptP)
+	}
+	if 128*p.N*p.R > maxScryptMemory {
+		return fmt.Errorf("scrypt参数需要的内存超过 %dMB", maxScryptMemory>>20)
+	}
+	return nil
+}
+
+// Keystore 保存加密钱包的密钥库，每个钱包一个文件
+//
+// 目录为空时只在内存中保存，用于不持久化的节点。
+type Keystore struct {
+	dir     string
+	scryptN int
+	scryptP int
+	files   map[string]*KeystoreFile
+	mu      sync.RWMutex
+}
+
+// NewKeystore 打开目录中的密钥库，加载已有的密钥库文件
+func NewKeystore(dir string, scryptN, scryptP int) (*Keystore, error) {
+	ks := &Keystore{
+		dir:     dir,
+		scryptN: scryptN,
+		scryptP: scryptP,
+		files:   make(map[string]*KeystoreFile),
+	}
+	if dir == "" {
+		return ks, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建密钥库目录失败: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥库目录失败: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥库文件失败: %v", err)
+		}
+		var file KeystoreFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("解析密钥库文件 %s 失败: %v", entry.Name(), err)
+		}
+		ks.files[file.Address] = &file
+	}
+	return ks, nil
+}
+
+// Encrypt 用口令加密钱包并保存到密钥库，hdPath是助记词钱包的派生路径（可以为空）
+func (ks *Keystore) Encrypt(wallet *Wallet, passphrase, hdPath string) (*KeystoreFile, error) {
+	file, err := EncryptKey(wallet, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	file.HDPath = hdPath
+	if err := ks.Store(file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Store 保存密钥库文件，先写临时文件再改名，避免留下不完整的文件
+func (ks *Keystore) Store(file *KeystoreFile) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.dir != "" {
+		data, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(ks.dir, file.Address+".json")
+		if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+			return fmt.Errorf("写入密钥库文件失败: %v", err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return fmt.Errorf("写入密钥库文件失败: %v", err)
+		}
+	}
+	ks.files[file.Address] = file
+	return nil
+}
+
+// Get 获取地址对应的密钥库文件
+func (ks *Keystore) Get(address string) *KeystoreFile {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.files[address]
+}
+
+// Addresses 按地址排序返回密钥库中的所有钱包
+func (ks *Keystore) Addresses() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	addresses := make([]string, 0, len(ks.files))
+	for address := range ks.files {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// WalletInfo 对外展示的钱包信息，不包含私钥
+type WalletInfo struct {
+	Address   string        `json:"address"`
+	PublicKey string        `json:"public_key"`
+	Balance   int64         `json:"balance"`
+	Tokens    Coins         `json:"tokens,omitempty"`
+	Locked    []LockedFunds `json:"locked,omitempty"`
+	Nonce     uint64        `json:"nonce"`
+	Encrypted bool          `json:"encrypted"`         // 私钥是否加密保存在密钥库中
+	Unlocked  bool          `json:"unlocked"`          // 私钥当前是否可以用于签名
+	HDPath    string        `json:"hd_path,omitempty"` // 由助记词派生的钱包的派生路径
+}
+
+// walletInfo 返回不包含私钥的钱包信息，调用者需持有节点的锁
+func (n *Node) walletInfo(wallet *Wallet) *WalletInfo {
+	n.walletManager.mu.RLock()
+	defer n.walletManager.mu.RUnlock()
+
+	info := &WalletInfo{
+		Address:   wallet.Address,
+		PublicKey: wallet.PublicKey,
+		Balance:   wallet.Balance,
+		Tokens:    wallet.Tokens,
+		Locked:    wallet.Locked,
+		Nonce:     wallet.Nonce,
+		Unlocked:  wallet.PrivateKey != "",
+	}
+	if file := n.keystore.Get(wallet.Address); file != nil {
+		info.Encrypted, info.HDPath = true, file.HDPath
+	}
+	return info
+}
+
+// ListWallets 获取所有钱包的信息，不包含私钥
+func (n *Node) ListWallets() []*WalletInfo {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	wallets := n.walletManager.GetAllWallets()
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].Address < wallets[j].Address })
+	infos := make([]*WalletInfo, 0, len(wallets))
+	for _, wallet := range wallets {
+		infos = append(infos, n.walletInfo(wallet))
+	}
+	return infos
+}
+
+// CreateEncryptedWallet 创建私钥用口令加密保存的钱包
+//
+// 存储记录中只保存地址和公钥。新钱包创建后处于解锁状态，可以直接签名，调用 LockWallet 后需要口令才能再次使用。
+func (n *Node) CreateEncryptedWallet(passphrase string) (*WalletInfo, error) {
+	wallet, err := n.walletManager.newWallet()
+	if err != nil {
+		return nil, err
+	}
+	return n.addEncryptedWallet(wallet, passphrase, "", true)
+}
+
+// addEncryptedWallet 加密保存钱包私钥并把钱包加入节点，unlock为true时钱包保持解锁
+func (n *Node) addEncryptedWallet(wallet *Wallet, passphrase, hdPath string, unlock bool) (*WalletInfo, error) {
+	if _, err := n.keystore.Encrypt(wallet, passphrase, hdPath); err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.addPublicWallet(wallet.Address, wallet.PublicKey); err != nil {
+		return nil, err
+	}
+	if unlock {
+		n.walletManager.setPrivateKey(wallet.Address, wallet.PrivateKey)
+	}
+	return n.walletInfo(n.walletManager.GetWallet(wallet.Address)), nil
+}
+
+// ImportKeystore 导入密钥库文件，口令正确时钱包加入节点并保持锁定
+func (n *Node) ImportKeystore(file *KeystoreFile, passphrase string) (*WalletInfo, error) {
+	if _, err := DecryptKey(file, passphrase); err != nil {
+		return nil, err
+	}
+	if err := n.keystore.Store(file); err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.addPublicWallet(file.Address, file.PublicKey); err != nil {
+		return nil, err
+	}
+	return n.walletInfo(n.walletManager.GetWallet(file.Address)), nil
+}
+
+// ExportKeystore 导出钱包的密钥库文件（私钥仍是加密的）
+func (n *Node) ExportKeystore(address string) (*KeystoreFile, error) {
+	file := n.keystore.Get(address)
+	if file == nil {
+		return nil, fmt.Errorf("钱包 %s 不在密钥库中", address)
+	}
+	return file, nil
+}
+
+// UnlockWallet 用口令解锁钱包，duration大于0时到期后自动锁定
+func (n *Node) UnlockWallet(address, passphrase string, duration time.Duration) error {
+	file := n.keystore.Get(address)
+	if file == nil {
+		return fmt.Errorf("钱包 %s 不在密钥库中", address)
+	}
+	wallet, err := DecryptKey(file, passphrase)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.walletManager.setPrivateKey(address, wallet.PrivateKey)
+	if timer := n.unlockTimers[address]; timer != nil {
+		timer.Stop()
+		delete(n.unlockTimers, address)
+	}
+	if duration > 0 {
+		n.unlockTimers[address] = time.AfterFunc(duration, func() { n.LockWallet(address) })
+	}
+	return nil
+}
+
+// LockWallet 从内存中清除钱包的私钥，之后签名需要重新解锁
+func (n *Node) LockWallet(address string) error {
+	if n.keystore.Get(address) == nil {
+		return fmt.Errorf("钱包 %s 不在密钥库中，无法锁定", address)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.walletManager.setPrivateKey(address, "")
+	if timer := n.unlockTimers[address]; timer != nil {
+		timer.Stop()
+		delete(n.unlockTimers, address)
+	}
+	return nil
+}
+
+// addPublicWallet 持久化只有地址和公钥的钱包记录，调用者需持有写锁
+func (n *Node) addPublicWallet(address, publicKey string) error {
+	record := &StoreRecord{Type: RecordWallet, Wallet: &Wallet{Address: address, PublicKey: publicKey}}
+	if err := n.persist(record); err != nil {
+		return err
+	}
+	return n.applyRecord(record)
+}
@@ -0,0 +1,185 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unlocked 判断钱包当前是否可以签名
+func unlocked(node *Node, address string) bool {
+	_, privateKey, _ := node.walletManager.signingKey(address)
+	return privateKey != ""
+}
+
+func TestKeystoreEncryptDecrypt(t *testing.T) {
+	wallet, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := EncryptKey(wallet, "correct horse", LightScryptN, LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), wallet.PrivateKey) {
+		t.Fatal("密钥库文件中不应出现明文私钥")
+	}
+
+	decrypted, err := DecryptKey(file, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.PrivateKey != wallet.PrivateKey || decrypted.Address != wallet.Address {
+		t.Fatal("解密后的钱包与原钱包不一致")
+	}
+	if _, err := DecryptKey(file, "wrong"); err == nil {
+		t.Fatal("错误的口令应无法解密")
+	}
+
+	// 地址作为附加数据参与认证，改动地址后无法解密
+	tampered := *file
+	tampered.Address = testAddress("mallory")
+	if _, err := DecryptKey(&tampered, "correct horse"); err == nil {
+		t.Fatal("篡改地址后的密钥库文件应无法解密")
+	}
+	if _, err := EncryptKey(wallet, "", LightScryptN, LightScryptP); err == nil {
+		t.Fatal("空口令应被拒绝")
+	}
+}
+
+func TestSigningRequiresUnlockedWallet(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := testGenesisDoc(alice.Address)
+	node := newEconomicsNode(t, doc, alice)
+	node.keystore.scryptN, node.keystore.scryptP = LightScryptN, LightScryptP
+
+	bob, err := node.CreateEncryptedWallet("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bob.Encrypted || !bob.Unlocked {
+		t.Fatalf("新建的加密钱包 = %+v", bob)
+	}
+	if _, err := node.Transfer(alice.Address, bob.Address, 1000, 3); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+
+	if err := node.LockWallet(bob.Address); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Transfer(bob.Address, alice.Address, 100, 3); err == nil {
+		t.Fatal("锁定的钱包不能签名")
+	}
+	if err := node.UnlockWallet(bob.Address, "wrong", 0); err == nil {
+		t.Fatal("错误的口令不能解锁钱包")
+	}
+	if err := node.UnlockWallet(bob.Address, "secret", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Transfer(bob.Address, alice.Address, 100, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 限时解锁到期后自动锁定
+	if err := node.UnlockWallet(bob.Address, "secret", 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for unlocked(node, bob.Address) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if unlocked(node, bob.Address) {
+		t.Fatal("限时解锁到期后钱包应自动锁定")
+	}
+	if _, err := node.Transfer(bob.Address, alice.Address, 100, 3); err == nil {
+		t.Fatal("自动锁定后钱包不能签名")
+	}
+	if err := node.LockWallet(alice.Address); err == nil {
+		t.Fatal("不在密钥库中的钱包不能锁定")
+	}
+}
+
+func TestKeystoreImportExport(t *testing.T) {
+	a, err := NewNode(NodeConfig{LightKDF: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewNode(NodeConfig{LightKDF: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := a.CreateEncryptedWallet("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := a.ExportKeystore(created.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ExportKeystore(testAddress("unknown")); err == nil {
+		t.Fatal("不在密钥库中的钱包不能导出")
+	}
+
+	// 构造的文件不能用过大的scrypt参数耗尽导入节点的资源
+	for _, params := range []ScryptParams{{N: 1 << 30, R: 8, P: 1}, {N: 3000, R: 8, P: 1}, {N: 1 << 12, R: 1 << 20, P: 1}, {N: 1 << 12, R: 8, P: 1 << 20}} {
+		huge := *file
+		params.Salt = file.Crypto.KDFParams.Salt
+		huge.Crypto.KDFParams = params
+		if _, err := b.ImportKeystore(&huge, "secret"); err == nil {
+			t.Fatalf("scrypt参数 %+v 应被拒绝", params)
+		}
+	}
+
+	if _, err := b.ImportKeystore(file, "wrong"); err == nil {
+		t.Fatal("口令错误时不能导入")
+	}
+	imported, err := b.ImportKeystore(file, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Address != created.Address || !imported.Encrypted || imported.Unlocked {
+		t.Fatalf("导入的钱包 = %+v", imported)
+	}
+	if err := b.UnlockWallet(created.Address, "secret", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptedWalletSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	node, err := NewNode(NodeConfig{DataDir: dir, LightKDF: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := node.CreateEncryptedWallet("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.Close()
+
+	node, err = NewNode(NodeConfig{DataDir: dir, LightKDF: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	// 重启后钱包仍然存在，但私钥只在密钥库中加密保存，需要重新解锁
+	wallet := node.walletManager.GetWallet(created.Address)
+	if wallet == nil || unlocked(node, created.Address) || node.keystore.Get(created.Address) == nil {
+		t.Fatal("重启后钱包应存在于密钥库中并处于锁定状态")
+	}
+	if err := node.UnlockWallet(created.Address, "secret", 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LockCondition 转账的解锁条件：到达指定区块高度或区块时间之前，接收方不能使用这笔资金
+//
+// 两个条件都设置时需要同时满足。未设置任何条件的转账立即到账。
+//
+// 区块时间由出块者决定，节点只接受不早于前一区块、且不超前本地时间 maxFutureBlockTime 的区块，
+// 所以按时间锁定的资金最多可能提前 maxFutureBlockTime 解锁；需要精确解锁时应使用解锁高度。
+type LockCondition struct {
+	UnlockHeight int   `json:"unlock_height,omitempty"` // 在这个高度的区块中解锁
+	UnlockTime   int64 `json:"unlock_time,omitempty"`   // 在区块时间不早于这个Unix时间（秒）时解锁
+}
+
+// isSet 判断是否设置了解锁条件
+func (c LockCondition) isSet() bool {
+	return c.UnlockHeight != 0 || c.UnlockTime != 0
+}
+
+// expired 判断在高度为height、时间为timestamp的区块中条件是否已经满足
+func (c LockCondition) expired(height int, timestamp int64) bool {
+	return height >= c.UnlockHeight && timestamp >= c.UnlockTime
+}
+
+// validate 检查解锁条件
+func (c LockCondition) validate() error {
+	if c.UnlockHeight < 0 || c.UnlockTime < 0 {
+		return fmt.Errorf("解锁高度和解锁时间不能为负")
+	}
+	return nil
+}
+
+// LockedFunds 账户中尚未解锁的一笔资金
+type LockedFunds struct {
+	TxID   string `json:"tx_id"` // 锁定这笔资金的转账
+	Denom  string `json:"denom"`
+	Amount int64  `json:"amount"`
+	LockCondition
+}
+
+// lock 给账户增加一笔锁定资金，锁定列表先复制再修改
+func (a *accountState) lock(funds LockedFunds) {
+	a.Locked = append(append([]LockedFunds{}, a.Locked...), funds)
+}
+
+// lockedCoins 按代币汇总账户中的锁定资金
+func lockedCoins(locked []LockedFunds) Coins {
+	coins := make(Coins)
+	for _, funds := range locked {
+		coins.add(funds.Denom, funds.Amount)
+	}
+	return coins
+}
+
+// release 把满足解锁条件的资金转为可用余额，并记录区块的高度和时间供之后的交易使用
+//
+// 解锁发生在执行区块中的交易之前，所以在某个高度解锁的资金可以在同一个区块中使用。
+func (o *stateOverlay) release(height int, timestamp int64) {
+	o.height, o.timestamp = height, timestamp
+
+	for _, address := range o.wm.lockedAddresses() {
+		account, _ := o.accountOf(address)
+		remaining := make([]LockedFunds, 0, len(account.Locked))
+		for _, funds := range account.Locked {
+			if funds.expired(height, timestamp) {
+				account.add(funds.Denom, funds.Amount)
+			} else {
+				remaining = append(remaining, funds)
+			}
+		}
+		if len(remaining) != len(account.Locked) {
+			if len(remaining) == 0 {
+				remaining = nil
+			}
+			account.Locked = remaining
+			o.accounts[address] = account
+		}
+	}
+}
+
+// lockedAddresses 按地址排序返回有锁定资金的账户
+func (wm *WalletManager) lockedAddresses() []string {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	var addresses []string
+	for address, wallet := range wm.wallets {
+		if len(wallet.Locked) > 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// GetLocked 获取账户中尚未解锁的资金
+func (wm *WalletManager) GetLocked(address string) []LockedFunds {
+	account, _ := wm.account(address)
+	return append([]LockedFunds{}, account.Locked...)
+}
+
+// TransferLocked 创建一笔带解锁条件的转账并放入交易池
+func (n *Node) TransferLocked(from, to string, amount, fee Coin, lock LockCondition) (*Transaction, error) {
+	return n.submitTransaction(TxTypeTransfer, from, to, amount, fee, lock)
+}
+
+// GetLocked 获取地址上尚未解锁的资金
+func (n *Node) GetLocked(address string) []LockedFunds {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.walletManager.GetLocked(address)
+}
@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeightLockedTransfer(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+	b, err := NewNode(NodeConfig{Genesis: testGenesisDoc(alice.Address)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := a.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stake := func(amount int64) Coin { return Coin{Denom: BaseDenom, Amount: amount} }
+	if _, err := a.TransferLocked(alice.Address, bob.Address, stake(500), stake(3), LockCondition{UnlockHeight: -1}); err == nil {
+		t.Fatal("负的解锁高度应被拒绝")
+	}
+	if _, err := a.TransferLocked(alice.Address, bob.Address, stake(500), stake(3), LockCondition{UnlockHeight: 4}); err != nil {
+		t.Fatal(err)
+	}
+	a.generateNewBlock()
+
+	// 解锁之前资金计入锁定余额，不能转出
+	locked := a.GetLocked(bob.Address)
+	if a.GetBalance(bob.Address) != 0 || len(locked) != 1 || locked[0].Amount != 500 || locked[0].UnlockHeight != 4 {
+		t.Fatalf("高度2时bob的可用余额 %d，锁定资金 %+v", a.GetBalance(bob.Address), locked)
+	}
+	if _, err := a.Transfer(bob.Address, alice.Address, 100, 3); err == nil {
+		t.Fatal("锁定的资金不能转出")
+	}
+	if !a.GetSupply().Reconciled {
+		t.Fatal("锁定资金应计入流通量")
+	}
+
+	a.generateNewBlock()
+	if a.GetBalance(bob.Address) != 0 {
+		t.Fatal("高度3时资金还不应解锁")
+	}
+
+	// 到达解锁高度的区块自动解锁
+	a.generateNewBlock()
+	if a.GetBalance(bob.Address) != 500 || len(a.GetLocked(bob.Address)) != 0 {
+		t.Fatalf("高度4时bob的可用余额 = %d，期望 500", a.GetBalance(bob.Address))
+	}
+	if _, err := a.Transfer(bob.Address, alice.Address, 100, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他节点执行同样的区块得到相同的解锁结果
+	for height := 2; height <= 4; height++ {
+		if err := b.AddPeerBlock(a.GetBlockByHeight(height)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.GetStateHash() != a.GetStateHash() {
+		t.Fatal("同步区块后两个节点的状态不一致")
+	}
+}
+
+func TestTimeLockedTransfer(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	unlock := time.Now().Add(time.Hour).Unix()
+	stake := func(amount int64) Coin { return Coin{Denom: BaseDenom, Amount: amount} }
+	tx, err := node.TransferLocked(alice.Address, testAddress("bob"), stake(200), stake(3), LockCondition{UnlockTime: unlock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+	node.generateNewBlock()
+	if node.GetBalance(testAddress("bob")) != 0 || len(node.GetLocked(testAddress("bob"))) != 1 {
+		t.Fatal("解锁时间之前资金应保持锁定")
+	}
+
+	// 区块时间到达解锁时间时资金转为可用余额
+	wm := node.walletManager
+	overlay := wm.newStateOverlay()
+	overlay.release(node.GetHeight()+1, unlock-1)
+	overlay.commit()
+	if wm.GetBalance(testAddress("bob")) != 0 {
+		t.Fatal("解锁时间之前的区块不应解锁")
+	}
+	overlay.release(node.GetHeight()+1, unlock)
+	overlay.commit()
+	if wm.GetBalance(testAddress("bob")) != 200 || len(wm.GetLocked(testAddress("bob"))) != 0 {
+		t.Fatal("到达解锁时间的区块应解锁资金")
+	}
+
+	// 替换交易保留原有的解锁条件
+	if _, err := node.TransferLocked(alice.Address, testAddress("bob"), stake(10), stake(3), LockCondition{UnlockHeight: 100}); err != nil {
+		t.Fatal(err)
+	}
+	pending := node.GetMempoolEntries()[0].Transaction
+	replaced, err := node.ReplaceTransaction(pending.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.UnlockHeight != 100 || replaced.ID == tx.ID {
+		t.Fatalf("替换后的交易 = %+v", replaced)
+	}
+}
+
+func TestFutureBlockCannotReleaseTimeLock(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	unlock := time.Now().Add(time.Hour).Unix()
+	stake := func(amount int64) Coin { return Coin{Denom: BaseDenom, Amount: amount} }
+	if _, err := node.TransferLocked(alice.Address, testAddress("bob"), stake(200), stake(3), LockCondition{UnlockTime: unlock}); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+
+	// 出块者把区块时间设到解锁时间之后，这样的区块不会被接受
+	node.mu.Lock()
+	block, _ := node.buildBlock()
+	block.Timestamp = time.Unix(unlock, 0)
+	node.engine.Seal(block, func() bool { return false })
+	block.Signature, _ = node.signBlock(block)
+	node.mu.Unlock()
+	if err := node.AddPeerBlock(block); err == nil {
+		t.Fatal("时间超前的区块应被拒绝")
+	}
+	if node.GetBalance(testAddress("bob")) != 0 {
+		t.Fatal("时间锁定的资金不应被提前解锁")
+	}
+}
@@ -0,0 +1,369 @@
+package blockchain
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 交易池默认参数
+const (
+	defaultMempoolSize       = 5000             // 交易池最多容纳的交易数
+	defaultMempoolPerAccount = 64               // 每个账户最多排队的交易数
+	defaultMempoolTTL        = 10 * time.Minute // 交易在池中的最长停留时间
+	replaceFeeBumpPercent    = 10               // 替换交易的手续费率至少提高的百分比
+)
+
+// MempoolConfig 交易池配置
+type MempoolConfig struct {
+	MaxSize       int           // 交易池最多容纳的交易数
+	MaxPerAccount int           // 每个账户最多排队的交易数
+	TTL           time.Duration // 交易在池中的最长停留时间
+}
+
+// MempoolEntry 表示交易池中的一笔交易
+type MempoolEntry struct {
+	Transaction *Transaction `json:"transaction"`
+	Size        int          `json:"size"`     // 交易编码后的字节数
+	AddedAt     time.Time    `json:"added_at"` // 进入交易池的时间
+	seq         uint64       // 进入交易池的顺序，用于同费率时先来先得
+}
+
+// MempoolStats 交易池统计信息
+type MempoolStats struct {
+	Size          int   `json:"size"`
+	Bytes         int   `json:"bytes"`
+	Accounts      int   `json:"accounts"`
+	MaxSize       int   `json:"max_size"`
+	MaxPerAccount int   `json:"max_per_account"`
+	TTLSeconds    int64 `json:"ttl_seconds"`
+}
+
+// Mempool 是按手续费率排序的交易池
+//
+// 交易按基础代币的手续费率（手续费/字节）从高到低排序，同一发送方的转账按序号先后排列。
+// 系统交易（挖矿奖励、手续费支付、创世分配）由出块节点直接放入区块，不能进入交易池。
+type Mempool struct {
+	cfg      MempoolConfig
+	entries  map[string]*MempoolEntry            // 交易ID到交易
+	bySender map[string]map[uint64]*MempoolEntry // 发送方到序号到交易（仅转账）
+	seq      uint64
+	mu       sync.RWMutex
+}
+
+// NewMempool 创建交易池
+func NewMempool(cfg MempoolConfig) *Mempool {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultMempoolSize
+	}
+	if cfg.MaxPerAccount <= 0 {
+		cfg.MaxPerAccount = defaultMempoolPerAccount
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultMempoolTTL
+	}
+
+	return &Mempool{
+		cfg:      cfg,
+		entries:  make(map[string]*MempoolEntry),
+		bySender: make(map[string]map[uint64]*MempoolEntry),
+	}
+}
+
+// isSystemTx 判断是否为系统交易
+func isSystemTx(tx *Transaction) bool {
+	return tx.From == "system"
+}
+
+// isSequenced 判断交易是否参与按发送方序号排序
+func isSequenced(tx *Transaction) bool {
+	return (tx.Type == TxTypeTransfer || tx.Type == TxTypeIssue) && !isSystemTx(tx)
+}
+
+// priorityFee 返回参与排序的手续费
+//
+// 其他代币支付的手续费无法换算成基础代币，排序时按0计算，不能用不值钱的代币换取优先级。
+func priorityFee(tx *Transaction) int64 {
+	if denomOf(tx.FeeDenom) != BaseDenom || tx.Fee < 0 {
+		return 0
+	}
+	return tx.Fee
+}
+
+// scaledFee 返回 fee*size*percent，用大整数计算避免溢出
+func scaledFee(fee int64, size int, percent int64) *big.Int {
+	product := big.NewInt(fee)
+	product.Mul(product, big.NewInt(int64(size)))
+	return product.Mul(product, big.NewInt(percent))
+}
+
+// higherFeeRate 判断a的手续费率是否高于b
+func higherFeeRate(a, b *MempoolEntry) bool {
+	rateA := scaledFee(priorityFee(a.Transaction), b.Size, 1)
+	rateB := scaledFee(priorityFee(b.Transaction), a.Size, 1)
+	return rateA.Cmp(rateB) > 0
+}
+
+// Add 将交易加入交易池
+//
+// 同一发送方、同一序号的交易只有在手续费率提高至少10%时才能替换原交易，
+// 返回被替换或因容量不足被淘汰的交易。
+func (mp *Mempool) Add(tx *Transaction, now time.Time) ([]*Transaction, error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if _, exists := mp.entries[tx.ID]; exists {
+		return nil, fmt.Errorf("交易 %s 已在交易池中", tx.ID)
+	}
+	if isSystemTx(tx) {
+		// 系统交易只能由出块节点直接放入区块
+		return nil, fmt.Errorf("系统交易 %s 不能进入交易池", tx.ID)
+	}
+
+	entry := &MempoolEntry{
+		Transaction: tx,
+		Size:        len(tx.Bytes()),
+		AddedAt:     now,
+	}
+
+	var removed []*Transaction
+	if isSequenced(tx) {
+		queued := mp.bySender[tx.From]
+		if old, exists := queued[tx.Nonce]; exists {
+			// 替换：新交易必须用同一种代币支付手续费，且手续费率比原交易高出一定比例
+			if denomOf(tx.FeeDenom) != denomOf(old.Transaction.FeeDenom) {
+				return nil, fmt.Errorf("替换交易必须使用与原交易相同的手续费代币 %s", denomOf(old.Transaction.FeeDenom))
+			}
+			oldRate := scaledFee(old.Transaction.Fee, entry.Size, 100+replaceFeeBumpPercent)
+			newRate := scaledFee(tx.Fee, old.Size, 100)
+			if tx.Fee <= old.Transaction.Fee || newRate.Cmp(oldRate) < 0 {
+				return nil, fmt.Errorf("替换交易的手续费率至少需要提高%d%%", replaceFeeBumpPercent)
+			}
+			mp.remove(old.Transaction.ID)
+			removed = append(removed, old.Transaction)
+		} else if len(queued) >= mp.cfg.MaxPerAccount {
+			return nil, fmt.Errorf("账户 %s 在交易池中的交易数已达上限 %d", tx.From, mp.cfg.MaxPerAccount)
+		}
+	}
+
+	if len(mp.entries) >= mp.cfg.MaxSize {
+		victim := mp.lowestEvictable()
+		if victim == nil || !higherFeeRate(entry, victim) {
+			return nil, fmt.Errorf("交易池已满，手续费率过低")
+		}
+		mp.remove(victim.Transaction.ID)
+		removed = append(removed, victim.Transaction)
+	}
+
+	mp.seq++
+	entry.seq = mp.seq
+	mp.entries[tx.ID] = entry
+	if isSequenced(tx) {
+		if mp.bySender[tx.From] == nil {
+			mp.bySender[tx.From] = make(map[uint64]*MempoolEntry)
+		}
+		mp.bySender[tx.From][tx.Nonce] = entry
+	}
+	return removed, nil
+}
+
+// lowestEvictable 返回手续费率最低的可淘汰交易
+//
+// 只考虑每个发送方序号最大的交易，避免淘汰后在序号中间留下空缺。
+func (mp *Mempool) lowestEvictable() *MempoolEntry {
+	var victim *MempoolEntry
+	for _, entry := range mp.entries {
+		tx := entry.Transaction
+		if isSequenced(tx) {
+			if _, hasNext := mp.bySender[tx.From][tx.Nonce+1]; hasNext {
+				continue
+			}
+		}
+		if victim == nil || higherFeeRate(victim, entry) ||
+			(!higherFeeRate(entry, victim) && entry.seq > victim.seq) {
+			victim = entry
+		}
+	}
+	return victim
+}
+
+// remove 删除交易，调用者需持有锁
+func (mp *Mempool) remove(id string) {
+	entry, exists := mp.entries[id]
+	if !exists {
+		return
+	}
+	delete(mp.entries, id)
+
+	tx := entry.Transaction
+	if isSequenced(tx) {
+		queued := mp.bySender[tx.From]
+		if queued[tx.Nonce] == entry {
+			delete(queued, tx.Nonce)
+		}
+		if len(queued) == 0 {
+			delete(mp.bySender, tx.From)
+		}
+	}
+}
+
+// Remove 从交易池中删除交易
+func (mp *Mempool) Remove(ids ...string) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, id := range ids {
+		mp.remove(id)
+	}
+}
+
+// Expired 返回在交易池中停留超过TTL的交易（不删除）
+func (mp *Mempool) Expired(now time.Time) []*Transaction {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	var expired []*MempoolEntry
+	for _, entry := range mp.entries {
+		if now.Sub(entry.AddedAt) > mp.cfg.TTL {
+			expired = append(expired, entry)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].seq < expired[j].seq })
+
+	txs := make([]*Transaction, len(expired))
+	for i, entry := range expired {
+		txs[i] = entry.Transaction
+	}
+	return txs
+}
+
+// Get 按ID获取交易池中的交易
+func (mp *Mempool) Get(id string) *MempoolEntry {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.entries[id]
+}
+
+// Contains 判断交易是否在交易池中
+func (mp *Mempool) Contains(id string) bool {
+	return mp.Get(id) != nil
+}
+
+// Size 返回交易池中的交易数
+func (mp *Mempool) Size() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return len(mp.entries)
+}
+
+// NextNonce 返回发送方在交易池中连续排队之后的下一个序号
+func (mp *Mempool) NextNonce(sender string, accountNonce uint64) uint64 {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	nonce := accountNonce
+	for {
+		if _, exists := mp.bySender[sender][nonce]; !exists {
+			return nonce
+		}
+		nonce++
+	}
+}
+
+// Stats 返回交易池统计信息
+func (mp *Mempool) Stats() MempoolStats {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	stats := MempoolStats{
+		Size:          len(mp.entries),
+		Accounts:      len(mp.bySender),
+		MaxSize:       mp.cfg.MaxSize,
+		MaxPerAccount: mp.cfg.MaxPerAccount,
+		TTLSeconds:    int64(mp.cfg.TTL / time.Second),
+	}
+	for _, entry := range mp.entries {
+		stats.Bytes += entry.Size
+	}
+	return stats
+}
+
+// Entries 按打包优先级返回交易池中的全部交易
+func (mp *Mempool) Entries() []*MempoolEntry {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	queues := make(map[string][]*MempoolEntry)
+	var pq entryQueue
+
+	for _, entry := range mp.entries {
+		tx := entry.Transaction
+		if isSequenced(tx) {
+			queues[tx.From] = append(queues[tx.From], entry)
+		} else {
+			// 不参与序号排序的交易各自成为一个队列
+			pq = append(pq, []*MempoolEntry{entry})
+		}
+	}
+
+	for _, queue := range queues {
+		sort.Slice(queue, func(i, j int) bool {
+			return queue[i].Transaction.Nonce < queue[j].Transaction.Nonce
+		})
+		pq = append(pq, queue)
+	}
+
+	// 每次取出队首手续费率最高的队列，保证同一发送方内部按序号顺序
+	heap.Init(&pq)
+	var result []*MempoolEntry
+	for pq.Len() > 0 {
+		queue := heap.Pop(&pq).([]*MempoolEntry)
+		result = append(result, queue[0])
+		if len(queue) > 1 {
+			heap.Push(&pq, queue[1:])
+		}
+	}
+	return result
+}
+
+// Transactions 按打包优先级返回交易池中的全部交易
+func (mp *Mempool) Transactions() []*Transaction {
+	entries := mp.Entries()
+	txs := make([]*Transaction, len(entries))
+	for i, entry := range entries {
+		txs[i] = entry.Transaction
+	}
+	return txs
+}
+
+// entryQueue 是以队首交易手续费率排序的大顶堆
+type entryQueue [][]*MempoolEntry
+
+func (q entryQueue) Len() int { return len(q) }
+
+func (q entryQueue) Less(i, j int) bool {
+	a, b := q[i][0], q[j][0]
+	if higherFeeRate(a, b) {
+		return true
+	}
+	if higherFeeRate(b, a) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (q entryQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *entryQueue) Push(x interface{}) { *q = append(*q, x.([]*MempoolEntry)) }
+
+func (q *entryQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,185 @@
+package blockchain
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// newSystemPoolTx 创建用于交易池测试的系统交易
+func newSystemPoolTx(to string) *Transaction {
+	return &Transaction{ID: "system-" + to, Type: TxTypeMining, From: "system", To: to, Amount: 1000}
+}
+
+// newPoolTx 创建用于交易池测试的转账，交易池本身不检查签名
+func newPoolTx(from string, nonce uint64, fee int64) *Transaction {
+	tx := &Transaction{
+		Type:      TxTypeTransfer,
+		From:      from,
+		To:        testAddress("recipient"),
+		Amount:    10,
+		Fee:       fee,
+		Nonce:     nonce,
+		Timestamp: 1700000000,
+	}
+	tx.ID = tx.Hash()
+	return tx
+}
+
+func TestMempoolOrdersByFeeWithinNonceOrder(t *testing.T) {
+	mp := NewMempool(MempoolConfig{})
+	now := time.Now()
+
+	txs := []*Transaction{
+		newPoolTx("alice", 0, 1),
+		newPoolTx("alice", 1, 50),
+		newPoolTx("bob", 0, 10),
+	}
+	for _, tx := range txs {
+		if _, err := mp.Add(tx, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := mp.Add(txs[0], now); err == nil {
+		t.Fatal("重复的交易应被拒绝")
+	}
+	if _, err := mp.Add(newSystemPoolTx("carol"), now); err == nil {
+		t.Fatal("系统交易不应进入交易池")
+	}
+
+	// alice的高手续费交易必须排在她的第一笔之后
+	got := mp.Transactions()
+	want := []*Transaction{txs[2], txs[0], txs[1]}
+	for i := range want {
+		if got[i].ID != want[i].ID {
+			t.Fatalf("第%d笔应为 %s/%d，实际为 %s/%d", i, want[i].From, want[i].Nonce, got[i].From, got[i].Nonce)
+		}
+	}
+	if next := mp.NextNonce("alice", 0); next != 2 {
+		t.Fatalf("alice的下一个序号应为2，实际为%d", next)
+	}
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	mp := NewMempool(MempoolConfig{})
+	now := time.Now()
+
+	original := newPoolTx("alice", 0, 100)
+	if _, err := mp.Add(original, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Add(newPoolTx("alice", 0, 105), now); err == nil {
+		t.Fatal("手续费提高不足10%的替换应被拒绝")
+	}
+
+	replacement := newPoolTx("alice", 0, 120)
+	removed, err := mp.Add(replacement, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].ID != original.ID {
+		t.Fatalf("应返回被替换的原交易: %v", removed)
+	}
+	if mp.Contains(original.ID) || !mp.Contains(replacement.ID) || mp.Size() != 1 {
+		t.Fatal("原交易应被新交易替换")
+	}
+}
+
+func TestMempoolComparesFeesWithinOneDenom(t *testing.T) {
+	mp := NewMempool(MempoolConfig{})
+	now := time.Now()
+
+	// 其他代币的手续费不能换取优先级
+	gold := newPoolTx("alice", 0, 1000000)
+	gold.FeeDenom = "gold"
+	gold.ID = gold.Hash()
+	base := newPoolTx("bob", 0, 2)
+	for _, tx := range []*Transaction{gold, base} {
+		if _, err := mp.Add(tx, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := mp.Transactions(); got[0].ID != base.ID {
+		t.Fatal("基础代币手续费的交易应排在前面")
+	}
+
+	// 替换交易必须使用相同的手续费代币
+	if _, err := mp.Add(newPoolTx("alice", 0, 2000000), now); err == nil {
+		t.Fatal("更换手续费代币的替换应被拒绝")
+	}
+
+	// 手续费接近int64上限时比较不能溢出
+	huge := newPoolTx("bob", 0, math.MaxInt64/2)
+	if _, err := mp.Add(huge, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Add(newPoolTx("bob", 0, math.MaxInt64/2+1), now); err == nil {
+		t.Fatal("手续费提高不足10%的替换应被拒绝")
+	}
+	if got := mp.Transactions(); got[0].ID != huge.ID {
+		t.Fatal("高手续费的交易应排在前面")
+	}
+}
+
+func TestMempoolLimitsAndEviction(t *testing.T) {
+	mp := NewMempool(MempoolConfig{MaxSize: 3, MaxPerAccount: 2})
+	now := time.Now()
+
+	for _, tx := range []*Transaction{newPoolTx("alice", 0, 5), newPoolTx("alice", 1, 1)} {
+		if _, err := mp.Add(tx, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := mp.Add(newPoolTx("alice", 2, 50), now); err == nil {
+		t.Fatal("超过单账户上限的交易应被拒绝")
+	}
+
+	if _, err := mp.Add(newPoolTx("bob", 0, 3), now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mp.Add(newPoolTx("carol", 0, 1), now); err == nil {
+		t.Fatal("交易池已满时手续费率不高于最低交易的交易应被拒绝")
+	}
+
+	// 淘汰手续费率最低、且位于发送方队尾的交易
+	removed, err := mp.Add(newPoolTx("carol", 0, 20), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].From != "alice" || removed[0].Nonce != 1 {
+		t.Fatalf("应淘汰alice序号为1的交易: %v", removed)
+	}
+	if mp.Size() != 3 {
+		t.Fatalf("交易池应有3笔交易，实际为%d", mp.Size())
+	}
+}
+
+func TestMempoolExpiresStaleTransactions(t *testing.T) {
+	node, err := NewNode(NodeConfig{BlockTime: 1, Mempool: MempoolConfig{TTL: time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := node.CreateWallet()
+	node.generateNewBlock()
+
+	tx, err := node.Transfer(node.GetMinerAddress(), alice.Address, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.mempool.Get(tx.ID).AddedAt = time.Now().Add(-2 * time.Minute)
+
+	node.generateNewBlock()
+	if len(node.GetBlockByHeight(3).Transactions) != 1 {
+		t.Fatal("超时的交易不应被打包")
+	}
+	failed := node.GetFailedTransactions()
+	if node.mempool.Contains(tx.ID) || len(failed) != 1 || failed[0].Transaction.ID != tx.ID {
+		t.Fatalf("超时的交易应移出交易池并记为失败: %+v", failed)
+	}
+	if next := node.GetNonce(node.GetMinerAddress()); next != 0 {
+		t.Fatalf("超时交易的序号应可重新使用，实际下一个序号为%d", next)
+	}
+}
@@ -0,0 +1,170 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Merkle树采用RFC 6962的构造方式：叶子节点和内部节点使用不同前缀，
+// 节点数不是2的幂时按最大的2的幂拆分左右子树，而不是复制最后一个节点。
+const (
+	merkleLeafPrefix  = 0x00
+	merkleInnerPrefix = 0x01
+)
+
+// MerkleProof 表示交易在区块中的Merkle包含证明
+type MerkleProof struct {
+	TxID   string   `json:"tx_id"`
+	Height int      `json:"height"` // 交易所在区块高度
+	Index  int      `json:"index"`  // 交易在区块中的位置
+	Total  int      `json:"total"`  // 区块中的交易总数
+	Leaf   string   `json:"leaf"`   // 交易的叶子哈希
+	Root   string   `json:"root"`   // 区块的交易根
+	Hashes []string `json:"hashes"` // 从叶子到根依次需要的兄弟节点哈希
+}
+
+// merkleLeafHash 计算叶子节点哈希
+func merkleLeafHash(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, data...))
+	return hash[:]
+}
+
+// merkleInnerHash 计算内部节点哈希
+func merkleInnerHash(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, merkleInnerPrefix)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	hash := sha256.Sum256(buf)
+	return hash[:]
+}
+
+// merkleSplit 返回小于n的最大的2的幂
+func merkleSplit(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// merkleRootFromLeaves 由叶子哈希计算根
+func merkleRootFromLeaves(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	case 1:
+		return leaves[0]
+	}
+
+	k := merkleSplit(len(leaves))
+	return merkleInnerHash(merkleRootFromLeaves(leaves[:k]), merkleRootFromLeaves(leaves[k:]))
+}
+
+// merklePath 返回第index个叶子的兄弟节点哈希（从叶子到根）
+func merklePath(leaves [][]byte, index int) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+
+	k := merkleSplit(len(leaves))
+	if index < k {
+		return append(merklePath(leaves[:k], index), merkleRootFromLeaves(leaves[k:]))
+	}
+	return append(merklePath(leaves[k:], index-k), merkleRootFromLeaves(leaves[:k]))
+}
+
+// transactionLeaves 计算交易列表的叶子哈希
+func transactionLeaves(txs []*Transaction) [][]byte {
+	leaves := make([][]byte, len(txs))
+	for i, tx := range txs {
+		leaves[i] = merkleLeafHash(tx.Bytes())
+	}
+	return leaves
+}
+
+// CalculateTxRoot 计算交易列表的Merkle根
+func CalculateTxRoot(txs []*Transaction) string {
+	return hex.EncodeToString(merkleRootFromLeaves(transactionLeaves(txs)))
+}
+
+// buildMerkleProof 为区块中的第index笔交易构造包含证明
+func buildMerkleProof(block *Block, index int) *MerkleProof {
+	leaves := transactionLeaves(block.Transactions)
+
+	path := merklePath(leaves, index)
+	hashes := make([]string, len(path))
+	for i, hash := range path {
+		hashes[i] = hex.EncodeToString(hash)
+	}
+
+	return &MerkleProof{
+		TxID:   block.Transactions[index].ID,
+		Height: block.Height,
+		Index:  index,
+		Total:  len(leaves),
+		Leaf:   hex.EncodeToString(leaves[index]),
+		Root:   block.TxRoot,
+		Hashes: hashes,
+	}
+}
+
+// Verify 验证证明中的叶子确实包含在Merkle根中
+func (p *MerkleProof) Verify() error {
+	if p.Index < 0 || p.Index >= p.Total {
+		return fmt.Errorf("交易位置超出范围")
+	}
+
+	leaf, err := hex.DecodeString(p.Leaf)
+	if err != nil {
+		return fmt.Errorf("叶子哈希格式无效")
+	}
+	path := make([][]byte, len(p.Hashes))
+	for i, h := range p.Hashes {
+		if path[i], err = hex.DecodeString(h); err != nil {
+			return fmt.Errorf("证明哈希格式无效")
+		}
+	}
+
+	root, err := merkleRootFromPath(leaf, p.Index, p.Total, path)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(root) != p.Root {
+		return fmt.Errorf("Merkle根不匹配")
+	}
+	return nil
+}
+
+// merkleRootFromPath 沿证明路径自顶向下确定左右位置，再自底向上计算根
+func merkleRootFromPath(leaf []byte, index, total int, path [][]byte) ([]byte, error) {
+	if total == 1 {
+		if len(path) != 0 {
+			return nil, fmt.Errorf("证明长度无效")
+		}
+		return leaf, nil
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("证明长度无效")
+	}
+
+	sibling := path[len(path)-1]
+	rest := path[:len(path)-1]
+
+	k := merkleSplit(total)
+	if index < k {
+		left, err := merkleRootFromPath(leaf, index, k, rest)
+		if err != nil {
+			return nil, err
+		}
+		return merkleInnerHash(left, sibling), nil
+	}
+
+	right, err := merkleRootFromPath(leaf, index-k, total-k, rest)
+	if err != nil {
+		return nil, err
+	}
+	return merkleInnerHash(sibling, right), nil
+}
@@ -0,0 +1,61 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMerkleProofsForAllPositions(t *testing.T) {
+	for total := 1; total <= 9; total++ {
+		txs := make([]*Transaction, total)
+		for i := range txs {
+			txs[i] = NewDataTransaction(fmt.Sprintf("tx-%d-%d", total, i))
+		}
+		block := &Block{Height: 2, Transactions: txs, TxRoot: CalculateTxRoot(txs)}
+
+		for i := range txs {
+			proof := buildMerkleProof(block, i)
+			if err := proof.Verify(); err != nil {
+				t.Fatalf("total=%d index=%d 证明验证失败: %v", total, i, err)
+			}
+
+			forged := *proof
+			forged.Leaf = proof.Root
+			if total > 1 && forged.Verify() == nil {
+				t.Fatalf("total=%d index=%d 伪造的叶子不应通过验证", total, i)
+			}
+		}
+	}
+}
+
+func TestBlockRespectsTransactionLimit(t *testing.T) {
+	node, err := NewNode(NodeConfig{BlockTime: 1, MaxBlockTxs: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := node.AddTransaction(fmt.Sprintf("data-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	node.generateNewBlock()
+	block := node.GetBlockByHeight(2)
+	if len(block.Transactions) != 3 || block.Transactions[0].Type != TxTypeMining {
+		t.Fatalf("区块应包含挖矿奖励和2笔数据交易，实际为%d笔", len(block.Transactions))
+	}
+	if node.mempool.Size() != 3 {
+		t.Fatalf("交易池应剩余3笔交易，实际为%d笔", node.mempool.Size())
+	}
+
+	proof, err := node.GetTransactionProof(block.Transactions[2].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proof.Verify(); err != nil {
+		t.Fatalf("证明验证失败: %v", err)
+	}
+}
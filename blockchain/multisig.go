@@ -0,0 +1,329 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxMultisigKeys 多签账户最多包含的公钥数量
+const maxMultisigKeys = 16
+
+// MultisigAccount M-of-N多签账户：PubKeys中任意Threshold个私钥签名后才能转出资金
+//
+// 地址由门限和排序后的公钥计算得出，其他节点可以根据交易中携带的账户信息独立验证。
+type MultisigAccount struct {
+	Address   string   `json:"address"`
+	Threshold int      `json:"threshold"`
+	PubKeys   []string `json:"pub_keys"` // 按字典序排列的压缩公钥
+}
+
+// PartialSignature 多签交易中一个成员的签名
+type PartialSignature struct {
+	PubKey    string `json:"pub_key"`
+	Signature string `json:"signature"`
+}
+
+// MultisigProposal 正在收集签名的多签交易
+type MultisigProposal struct {
+	Transaction *Transaction `json:"transaction"`
+	Signed      int          `json:"signed"`    // 已收集的有效签名数量
+	Threshold   int          `json:"threshold"` // 需要的签名数量
+	Submitted   bool         `json:"submitted"` // 是否已达到门限并放入交易池
+}
+
+// NewMultisigAccount 由公钥和门限创建多签账户，公钥的顺序不影响地址
+func NewMultisigAccount(pubKeys []string, threshold int) (*MultisigAccount, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > maxMultisigKeys {
+		return nil, fmt.Errorf("多签账户需要1到%d个公钥", maxMultisigKeys)
+	}
+	if threshold < 1 || threshold > len(pubKeys) {
+		return nil, fmt.Errorf("门限必须在1到%d之间", len(pubKeys))
+	}
+
+	sorted := append([]string{}, pubKeys...)
+	sort.Strings(sorted)
+	for i, key := range sorted {
+		if _, err := parsePublicKey(key); err != nil {
+			return nil, fmt.Errorf("公钥 %s 无效: %v", key, err)
+		}
+		if i > 0 && sorted[i-1] == key {
+			return nil, fmt.Errorf("公钥 %s 重复", key)
+		}
+	}
+
+	identity := sha256.Sum256([]byte(fmt.Sprintf("multisig/%d/%s", threshold, strings.Join(sorted, ","))))
+	return &MultisigAccount{
+		Address:   encodeAddress(identity[:addressLength]),
+		Threshold: threshold,
+		PubKeys:   sorted,
+	}, nil
+}
+
+// contains 判断公钥是否属于多签账户
+func (m *MultisigAccount) contains(pubKey string) bool {
+	i := sort.SearchStrings(m.PubKeys, pubKey)
+	return i < len(m.PubKeys) && m.PubKeys[i] == pubKey
+}
+
+// countSignatures 返回交易中有效且不重复的成员签名数量，出现无效签名时返回错误
+func (m *MultisigAccount) countSignatures(tx *Transaction) (int, error) {
+	seen := make(map[string]bool, len(tx.Signatures))
+	for i, sig := range tx.Signatures {
+		if !m.contains(sig.PubKey) {
+			return 0, fmt.Errorf("第%d个签名的公钥不属于多签账户", i+1)
+		}
+		if seen[sig.PubKey] {
+			return 0, fmt.Errorf("公钥 %s 重复签名", shortKey(sig.PubKey))
+		}
+		if err := VerifySignature(sig.PubKey, tx.SignBytes(), sig.Signature); err != nil {
+			return 0, fmt.Errorf("第%d个签名无效: %v", i+1, err)
+		}
+		seen[sig.PubKey] = true
+	}
+	return len(seen), nil
+}
+
+// verifyMultisig 验证多签交易：账户信息必须与发送方地址一致，有效签名数量达到门限
+func verifyMultisig(tx *Transaction) error {
+	account, err := NewMultisigAccount(tx.Multisig.PubKeys, tx.Multisig.Threshold)
+	if err != nil {
+		return fmt.Errorf("多签账户无效: %v", err)
+	}
+	if account.Address != tx.From || tx.Multisig.Address != tx.From {
+		return fmt.Errorf("多签账户与发送方地址不匹配")
+	}
+	signed, err := account.countSignatures(tx)
+	if err != nil {
+		return err
+	}
+	if signed < account.Threshold {
+		return fmt.Errorf("多签交易签名不足：需要 %d 个，已有 %d 个", account.Threshold, signed)
+	}
+	return nil
+}
+
+// addMultisig 登记多签账户
+func (wm *WalletManager) addMultisig(account *MultisigAccount) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	wm.multisigs[account.Address] = account
+}
+
+// GetMultisig 获取已登记的多签账户
+func (wm *WalletManager) GetMultisig(address string) *MultisigAccount {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.multisigs[address]
+}
+
+// GetAllMultisigs 按地址排序获取所有已登记的多签账户
+func (wm *WalletManager) GetAllMultisigs() []*MultisigAccount {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	accounts := make([]*MultisigAccount, 0, len(wm.multisigs))
+	for _, account := range wm.multisigs {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Address < accounts[j].Address })
+	return accounts
+}
+
+// NewMultisigTransaction 创建从多签账户转出的交易，签名由成员之后逐个添加
+func (wm *WalletManager) NewMultisigTransaction(from, to string, amount, fee Coin, nonce uint64) (*Transaction, error) {
+	account := wm.GetMultisig(from)
+	if account == nil {
+		return nil, fmt.Errorf("多签账户 %s 不存在", from)
+	}
+
+	tx := &Transaction{
+		Type:      TxTypeTransfer,
+		From:      from,
+		To:        to,
+		Amount:    amount.Amount,
+		Denom:     txDenom(amount.Denom),
+		Fee:       fee.Amount,
+		FeeDenom:  txDenom(fee.Denom),
+		Nonce:     nonce,
+		Timestamp: time.Now().Unix(),
+		ChainID:   wm.chainID,
+		Multisig:  account,
+	}
+	tx.ID = tx.Hash()
+	return tx, nil
+}
+
+// putMultisigTx 保存正在收集签名的多签交易
+func (wm *WalletManager) putMultisigTx(tx *Transaction) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	wm.multisigTxs[tx.ID] = tx
+}
+
+// removeMultisigTx 移除已提交的多签交易
+func (wm *WalletManager) removeMultisigTx(id string) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	delete(wm.multisigTxs, id)
+}
+
+// GetMultisigTx 获取正在收集签名的多签交易
+func (wm *WalletManager) GetMultisigTx(id string) *Transaction {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.multisigTxs[id]
+}
+
+// withSignature 返回添加了一个成员签名的交易副本，签名必须来自多签成员且能通过验证
+//
+// signature为空时用signer对应的本地钱包私钥签名。
+func (wm *WalletManager) withSignature(id, signer, pubKey, signature string) (*Transaction, error) {
+	tx := wm.GetMultisigTx(id)
+	if tx == nil {
+		return nil, fmt.Errorf("多签交易 %s 不存在", id)
+	}
+
+	if signature == "" {
+		publicKey, privateKey, _ := wm.signingKey(signer)
+		if privateKey == "" {
+			return nil, fmt.Errorf("签名者 %s 不是带私钥的本地钱包或尚未解锁", signer)
+		}
+		var err error
+		if signature, err = SignBytes(privateKey, tx.SignBytes()); err != nil {
+			return nil, err
+		}
+		pubKey = publicKey
+	}
+
+	if !tx.Multisig.contains(pubKey) {
+		return nil, fmt.Errorf("公钥 %s 不属于多签账户", shortKey(pubKey))
+	}
+	for _, sig := range tx.Signatures {
+		if sig.PubKey == pubKey {
+			return nil, fmt.Errorf("公钥 %s 已经签名", shortKey(pubKey))
+		}
+	}
+	if err := VerifySignature(pubKey, tx.SignBytes(), signature); err != nil {
+		return nil, fmt.Errorf("签名无效: %v", err)
+	}
+
+	signed := *tx
+	signed.Signatures = append(append([]PartialSignature{}, tx.Signatures...), PartialSignature{PubKey: pubKey, Signature: signature})
+	return &signed, nil
+}
+
+// CreateMultisig 创建并登记多签账户
+func (n *Node) CreateMultisig(pubKeys []string, threshold int) (*MultisigAccount, error) {
+	account, err := NewMultisigAccount(pubKeys, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	record := &StoreRecord{Type: RecordMultisig, Multisig: account}
+	if err := n.persist(record); err != nil {
+		return nil, err
+	}
+	if err := n.applyRecord(record); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetMultisigs 获取所有已登记的多签账户
+func (n *Node) GetMultisigs() []*MultisigAccount {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.walletManager.GetAllMultisigs()
+}
+
+// ProposeMultisigTransfer 发起一笔从多签账户转出的交易，等待成员签名
+func (n *Node) ProposeMultisigTransfer(from, to string, amount, fee Coin) (*MultisigProposal, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tx, err := n.walletManager.NewMultisigTransaction(from, to, amount, fee, n.nextNonce(from))
+	if err != nil {
+		return nil, err
+	}
+	if err := n.genesis.FeePolicy.checkFee(tx); err != nil {
+		return nil, err
+	}
+	if err := n.saveMultisigTx(tx); err != nil {
+		return nil, err
+	}
+	return n.multisigProposal(tx, false), nil
+}
+
+// SignMultisigTransaction 为多签交易添加一个成员签名
+//
+// signature为空时由signer对应的本地钱包签名，否则使用外部提供的公钥和签名。
+// 有效签名达到门限后交易被放入交易池；如果此时交易无法通过验证（例如余额不足），
+// 已收集的签名会保留，返回错误。
+func (n *Node) SignMultisigTransaction(id, signer, pubKey, signature string) (*MultisigProposal, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tx, err := n.walletManager.withSignature(id, signer, pubKey, signature)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.saveMultisigTx(tx); err != nil {
+		return nil, err
+	}
+	if len(tx.Signatures) < tx.Multisig.Threshold {
+		return n.multisigProposal(tx, false), nil
+	}
+
+	if err := n.validatePending(tx); err != nil {
+		return n.multisigProposal(tx, false), fmt.Errorf("多签交易已达到门限但无法放入交易池: %v", err)
+	}
+	if err := n.addPending(tx); err != nil {
+		return n.multisigProposal(tx, false), err
+	}
+	return n.multisigProposal(tx, true), nil
+}
+
+// GetMultisigProposal 获取正在收集签名或已放入交易池的多签交易
+func (n *Node) GetMultisigProposal(id string) *MultisigProposal {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if tx := n.walletManager.GetMultisigTx(id); tx != nil {
+		return n.multisigProposal(tx, false)
+	}
+	if entry := n.mempool.Get(id); entry != nil && entry.Transaction.Multisig != nil {
+		return n.multisigProposal(entry.Transaction, true)
+	}
+	return nil
+}
+
+// saveMultisigTx 持久化并保存正在收集签名的多签交易，调用者需持有写锁
+func (n *Node) saveMultisigTx(tx *Transaction) error {
+	record := &StoreRecord{Type: RecordMultisigTx, Transaction: tx}
+	if err := n.persist(record); err != nil {
+		return err
+	}
+	return n.applyRecord(record)
+}
+
+// multisigProposal 返回多签交易的签名进度
+func (n *Node) multisigProposal(tx *Transaction, submitted bool) *MultisigProposal {
+	return &MultisigProposal{
+		Transaction: tx,
+		Signed:      len(tx.Signatures),
+		Threshold:   tx.Multisig.Threshold,
+		Submitted:   submitted,
+	}
+}
@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+// newSigners 在节点中创建size个本地钱包作为多签成员
+func newSigners(t *testing.T, node *Node, size int) ([]*Wallet, []string) {
+	t.Helper()
+	wallets := make([]*Wallet, size)
+	pubKeys := make([]string, size)
+	for i := range wallets {
+		wallet, err := node.CreateWallet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallets[i], pubKeys[i] = wallet, wallet.PublicKey
+	}
+	return wallets, pubKeys
+}
+
+func TestMultisigAccountAddress(t *testing.T) {
+	node, err := NewNode(NodeConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pubKeys := newSigners(t, node, 3)
+
+	a, err := NewMultisigAccount(pubKeys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewMultisigAccount([]string{pubKeys[2], pubKeys[0], pubKeys[1]}, 2)
+	c, _ := NewMultisigAccount(pubKeys, 3)
+	if a.Address != b.Address {
+		t.Fatal("公钥顺序不应影响多签地址")
+	}
+	if a.Address == c.Address {
+		t.Fatal("不同门限应得到不同的多签地址")
+	}
+
+	invalid := []struct {
+		keys      []string
+		threshold int
+	}{
+		{pubKeys, 0},
+		{pubKeys, 4},
+		{[]string{pubKeys[0], pubKeys[0]}, 1},
+		{[]string{"abcd"}, 1},
+		{nil, 1},
+	}
+	for _, c := range invalid {
+		if _, err := NewMultisigAccount(c.keys, c.threshold); err == nil {
+			t.Fatalf("公钥 %v 和门限 %d 应被拒绝", c.keys, c.threshold)
+		}
+	}
+}
+
+func TestMultisigTransferRequiresThreshold(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+	signers, pubKeys := newSigners(t, node, 3)
+	outsider, err := node.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := node.CreateMultisig(pubKeys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Transfer(alice.Address, account.Address, 1000, 3); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+
+	proposal, err := node.ProposeMultisigTransfer(account.Address, testAddress("bob"), Coin{Denom: BaseDenom, Amount: 300}, Coin{Denom: BaseDenom, Amount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := proposal.Transaction.ID
+
+	// 一个签名达不到门限，交易不能通过验证
+	if proposal, err = node.SignMultisigTransaction(id, signers[0].Address, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Submitted || proposal.Signed != 1 {
+		t.Fatalf("一个签名后的进度 = %+v", proposal)
+	}
+	if err := node.walletManager.ValidateTransaction(proposal.Transaction); err == nil {
+		t.Fatal("签名未达到门限的交易应被拒绝")
+	}
+	if _, err := node.SignMultisigTransaction(id, signers[0].Address, "", ""); err == nil {
+		t.Fatal("同一成员不能重复签名")
+	}
+	if _, err := node.SignMultisigTransaction(id, outsider.Address, "", ""); err == nil {
+		t.Fatal("多签账户之外的钱包不能签名")
+	}
+
+	// 第二个成员在外部签名后提交，达到门限的交易放入交易池
+	signature, err := SignBytes(signers[2].PrivateKey, proposal.Transaction.SignBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal, err = node.SignMultisigTransaction(id, "", signers[2].PublicKey, signature); err != nil {
+		t.Fatal(err)
+	}
+	if !proposal.Submitted || node.GetMempoolEntry(id) == nil {
+		t.Fatal("达到门限的多签交易应放入交易池")
+	}
+	if node.walletManager.GetMultisigTx(id) != nil {
+		t.Fatal("放入交易池的多签交易不应再等待签名")
+	}
+
+	node.generateNewBlock()
+	if node.GetBalance(testAddress("bob")) != 300 || node.GetBalance(account.Address) != 1000-300-3 {
+		t.Fatal("多签转账后的余额不正确")
+	}
+
+	// 篡改金额后原有签名失效
+	forged := *proposal.Transaction
+	forged.Amount = 600
+	forged.Nonce = 1
+	forged.ID = forged.Hash()
+	if err := node.walletManager.ValidateTransaction(&forged); err == nil {
+		t.Fatal("篡改后的多签交易应被拒绝")
+	}
+}
+
+func TestMultisigProposalSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	node, err := NewNode(NodeConfig{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, pubKeys := newSigners(t, node, 2)
+	account, err := node.CreateMultisig(pubKeys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposal, err := node.ProposeMultisigTransfer(account.Address, testAddress("bob"), Coin{Denom: BaseDenom, Amount: 1}, Coin{Denom: BaseDenom, Amount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.SignMultisigTransaction(proposal.Transaction.ID, signers[1].Address, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	node.Close()
+
+	node, err = NewNode(NodeConfig{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+	if multisigs := node.GetMultisigs(); len(multisigs) != 1 || multisigs[0].Address != account.Address {
+		t.Fatalf("重启后的多签账户 = %+v", multisigs)
+	}
+	restored := node.GetMultisigProposal(proposal.Transaction.ID)
+	if restored == nil || restored.Signed != 1 {
+		t.Fatalf("重启后的多签交易 = %+v", restored)
+	}
+}
@@ -4,21 +4,29 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
 // Block 表示区块链中的一个区块
 type Block struct {
-	Height        int       `json:"height"`
-	Timestamp     time.Time `json:"timestamp"`
-	Data          string    `json:"data"`
-	PrevHash      string    `json:"prev_hash"`
-	Hash          string    `json:"hash"`
-	Validator     string    `json:"validator"`
-	Signature     Signature `json:"signature"`
+	Height        int            `json:"height"`
+	Timestamp     time.Time      `json:"timestamp"`
+	Data          string         `json:"data"`
+	Transactions  []*Transaction `json:"transactions"`
+	TxRoot        string         `json:"tx_root"`
+	PrevHash      string         `json:"prev_hash"`
+	Hash          string         `json:"hash"`
+	Validator     string         `json:"validator"`
+	Difficulty    uint32         `json:"difficulty,omitempty"` // 工作量证明难度（哈希前导零比特数）
+	Nonce         uint64         `json:"nonce,omitempty"`      // 工作量证明的随机数
+	Signature     Signature      `json:"signature"`
+	Commit        *CommitCertificate `json:"commit,omitempty"` // BFT共识的提交证明，不参与区块哈希计算
 }
 
 // Signature 表示区块的数字签名
@@ -29,47 +37,347 @@ type Signature struct {
 
 // Node 表示区块链节点
 type Node struct {
-	chain               []*Block         // 区块链
-	pendingTransactions []string         // 待处理的交易
+	chain               []*Block         // 主链
+	blocks              map[string]*Block // 主链和分支上所有已知区块，按哈希索引
+	weights             map[string]uint64 // 从创世区块到该区块的累计权重
+	reorgEvents         []*ReorgEvent    // 最近的链重组事件
+	mempool             *Mempool         // 待打包的交易池
 	walletManager       *WalletManager   // 钱包管理器
-	transactions        []*Transaction   // 交易历史
+	transactions        *txHistory       // 交易历史及其索引
+	failedTransactions  []*FailedTransaction // 打包时验证失败的交易
+	includedTxs         map[string]int   // 已上链交易ID到区块高度的索引
 	minerAddress        string           // 矿工地址
-	miningReward        int64            // 挖矿奖励
-	validator           string           // 验证者身份
+	genesis             *GenesisDoc      // 链参数：出块奖励、初始余额、手续费规则等
+	genesisHash         string           // 创世文件生成的创世区块哈希，未使用创世文件时为空
+	validator           string           // 验证者身份（公钥）
+	validatorKey        string           // 验证者私钥，用于签名区块
 	mining              bool             // 是否正在生成区块
 	stopMining          chan struct{}    // 停止挖矿的信号通道
-	blockTime           int              // 区块生成间隔（秒）
+	engineDone          chan struct{}    // 共识引擎的Run返回后关闭
+	engine              ConsensusEngine  // 共识引擎，决定何时以及如何生成区块
+	maxBlockTxs         int              // 每个区块最多包含的交易数
+	maxBlockBytes       int              // 每个区块交易的最大总字节数
+	store               *Store           // 持久化存储，为nil时只保存在内存中
+	broadcaster         Broadcaster      // 向其他节点广播交易和区块，为nil时不广播
+	keystore            *Keystore        // 加密保存钱包私钥的密钥库
+	unlockTimers        map[string]*time.Timer // 限时解锁的钱包到期后自动锁定
+	txUpdates           chan struct{}    // 交易状态可能变化时关闭并替换，用于等待交易上链
+	events              *EventBus        // 向订阅者发布区块、交易和挖矿状态事件
 	mu                  sync.RWMutex     // 读写锁，保护并发访问
 }
 
+// Broadcaster 把节点新接受的交易和区块广播给其他节点
+//
+// 节点在持有锁时调用这些方法，实现不能阻塞，也不能回调节点。
+type Broadcaster interface {
+	BroadcastTransaction(tx *Transaction)
+	BroadcastBlock(block *Block)
+}
+
+// 接受其他节点的区块和交易时的特殊结果
+var (
+	errKnownBlock       = errors.New("区块已存在")
+	errMissingParent    = errors.New("缺少前序区块，需要先同步")
+	errKnownTransaction = errors.New("交易已存在")
+)
+
+// NodeConfig 节点配置
+type NodeConfig struct {
+	BlockTime     int           // 区块生成间隔（秒）
+	DataDir       string        // 数据目录，为空时不持久化
+	MaxBlockTxs   int           // 每个区块最多包含的交易数（含挖矿奖励）
+	MaxBlockBytes int           // 每个区块交易的最大总字节数
+	Mempool       MempoolConfig // 交易池配置
+	Engine        ConsensusEngine // 共识引擎，为nil时使用按BlockTime定时出块的单节点引擎
+	ValidatorKey  string          // 验证者私钥，为空时全新节点随机生成
+	Genesis       *GenesisDoc     // 创世文件，为nil时使用默认链参数并通过 CreateGenesisBlock 创建创世区块
+	LightKDF      bool            // 密钥库使用轻量的scrypt参数，只用于测试和开发
+}
+
 // NewNode 创建一个新的区块链节点
-func NewNode(blockTime int) *Node {
-	if blockTime <= 0 {
-		blockTime = 10 // 默认每10秒生成一个区块
+//
+// 如果配置了数据目录，会先回放存储中的记录，恢复区块链、钱包和交易历史。
+func NewNode(cfg NodeConfig) (*Node, error) {
+	if cfg.BlockTime <= 0 {
+		cfg.BlockTime = 10 // 默认每10秒生成一个区块
+	}
+	if cfg.MaxBlockTxs <= 0 {
+		cfg.MaxBlockTxs = 100
+	}
+	if cfg.MaxBlockBytes <= 0 {
+		cfg.MaxBlockBytes = 1 << 20 // 1MB
+	}
+	if cfg.Engine == nil {
+		cfg.Engine = NewSoloEngine(time.Duration(cfg.BlockTime) * time.Second)
+	}
+	genesis := cfg.Genesis
+	if genesis == nil {
+		genesis = defaultGenesisDoc(cfg.BlockTime)
 	}
 	
-	walletManager := NewWalletManager()
-	// 创建矿工钱包
-	minerWallet := walletManager.CreateWallet()
-	
-	return &Node{
+	n := &Node{
 		chain:               make([]*Block, 0),
-		pendingTransactions: make([]string, 0),
-		walletManager:       walletManager,
-		transactions:        make([]*Transaction, 0),
-		minerAddress:        minerWallet.Address,
-		miningReward:        100, // 挖矿奖励100币
-		validator:           generateValidatorID(),
-		blockTime:           blockTime,
+		blocks:              make(map[string]*Block),
+		weights:             make(map[string]uint64),
+		mempool:             NewMempool(cfg.Mempool),
+		walletManager:       NewWalletManager(),
+		transactions:        newTxHistory(),
+		includedTxs:         make(map[string]int),
+		genesis:             genesis,
+		engine:              cfg.Engine,
+		maxBlockTxs:         cfg.MaxBlockTxs,
+		maxBlockBytes:       cfg.MaxBlockBytes,
 		stopMining:          make(chan struct{}),
+		unlockTimers:        make(map[string]*time.Timer),
+		txUpdates:           make(chan struct{}),
+	}
+	n.walletManager.chainID = genesis.ChainID
+	
+	scryptN, scryptP := StandardScryptN, StandardScryptP
+	if cfg.LightKDF {
+		scryptN, scryptP = LightScryptN, LightScryptP
+	}
+	keystoreDir := ""
+	if cfg.DataDir != "" {
+		keystoreDir = filepath.Join(cfg.DataDir, "keystore")
+	}
+	keystore, err := NewKeystore(keystoreDir, scryptN, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	n.keystore = keystore
+	
+	if cfg.DataDir != "" {
+		store, err := OpenStore(cfg.DataDir)
+		if err != nil {
+			return nil, err
+		}
+		n.store = store
+		
+		if err := store.Replay(n.replayRecord); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("恢复节点数据失败: %v", err)
+		}
 	}
+	
+	// 回放完成后才开始发布事件
+	n.events = NewEventBus()
+	
+	// 恢复的区块链必须能通过完整校验，并符合当前共识引擎的规则
+	if err := n.validateChain(n.chain); err != nil {
+		n.Close()
+		return nil, fmt.Errorf("恢复的区块链无效: %w", err)
+	}
+	
+	// 已有节点的验证者密钥保存在数据目录中，不能通过配置更换
+	if n.validator != "" && cfg.ValidatorKey != "" && cfg.ValidatorKey != n.validatorKey {
+		n.Close()
+		return nil, fmt.Errorf("数据目录中的验证者密钥与配置的不一致")
+	}
+	
+	// 全新节点：使用配置的（或随机生成的）验证者密钥并创建矿工钱包
+	if n.validator == "" {
+		minerWallet, err := n.walletManager.newWallet()
+		if err != nil {
+			n.Close()
+			return nil, err
+		}
+		validatorKey := cfg.ValidatorKey
+		if validatorKey == "" {
+			if validatorKey, err = GeneratePrivateKey(); err != nil {
+				n.Close()
+				return nil, err
+			}
+		}
+		record := &StoreRecord{
+			Type:         RecordInit,
+			ValidatorKey: validatorKey,
+			Wallet:       minerWallet,
+		}
+		if err := n.persist(record); err != nil {
+			n.Close()
+			return nil, err
+		}
+		if err := n.applyRecord(record); err != nil {
+			n.Close()
+			return nil, err
+		}
+	}
+	
+	// 使用创世文件时，创世区块由创世文件决定
+	if cfg.Genesis != nil {
+		if err := n.initGenesis(cfg.Genesis); err != nil {
+			n.Close()
+			return nil, err
+		}
+	}
+	
+	return n, nil
 }
 
-// generateValidatorID 生成一个随机的验证者ID
-func generateValidatorID() string {
-	timestamp := time.Now().UnixNano()
-	hash := sha256.Sum256([]byte(fmt.Sprintf("%d", timestamp)))
-	return hex.EncodeToString(hash[:])[:8]
+// initGenesis 由创世文件创建创世区块，已有区块链时检查它的创世区块与创世文件一致
+func (n *Node) initGenesis(doc *GenesisDoc) error {
+	block := doc.Block()
+	n.genesisHash = block.Hash
+	if len(n.chain) > 0 {
+		if n.chain[0].Hash != block.Hash {
+			return fmt.Errorf("数据目录中的创世区块与创世文件 %s 不一致", doc.ChainID)
+		}
+		return nil
+	}
+	if _, err := verifyBlockTransactions(n.walletManager, n.includedTxs, block, doc); err != nil {
+		return fmt.Errorf("创世区块无效: %v", err)
+	}
+	
+	record := &StoreRecord{Type: RecordBlock, Block: block}
+	if err := n.persist(record); err != nil {
+		return err
+	}
+	return n.applyRecord(record)
+}
+
+// Close 关闭节点的持久化存储
+func (n *Node) Close() error {
+	n.mu.Lock()
+	for address, timer := range n.unlockTimers {
+		timer.Stop()
+		delete(n.unlockTimers, address)
+	}
+	n.mu.Unlock()
+	
+	if n.events != nil {
+		n.events.Close()
+	}
+	
+	if n.store == nil {
+		return nil
+	}
+	return n.store.Close()
+}
+
+// persist 将记录写入持久化存储
+func (n *Node) persist(record *StoreRecord) error {
+	if n.store == nil {
+		return nil
+	}
+	return n.store.Append(record)
+}
+
+// replayRecord 在启动时回放一条记录
+//
+// 交易池只是临时状态，回放时无法重新放入交易池的交易直接跳过，不影响节点启动。
+func (n *Node) replayRecord(record *StoreRecord) error {
+	err := n.applyRecord(record)
+	if err != nil && record.Type == RecordPending {
+		log.Printf("跳过无法放入交易池的交易 %s: %v", record.Transaction.ID, err)
+		return nil
+	}
+	return err
+}
+
+// applyRecord 将一条记录应用到节点状态
+//
+// 节点运行时和启动回放时都通过这个函数修改状态，保证两者结果一致。
+// 调用者需要持有写锁（启动回放时节点尚未对外可见，无需加锁）。
+func (n *Node) applyRecord(record *StoreRecord) error {
+	// 交易池、区块和失败记录的变化都可能改变交易状态
+	defer n.notifyTxUpdates()
+	
+	switch record.Type {
+	case RecordInit:
+		validator, err := PublicKeyFromPrivate(record.ValidatorKey)
+		if err != nil {
+			return fmt.Errorf("验证者密钥无效: %v", err)
+		}
+		n.validator = validator
+		n.validatorKey = record.ValidatorKey
+		n.minerAddress = record.Wallet.Address
+		n.walletManager.RestoreWallet(record.Wallet)
+	case RecordWallet:
+		n.walletManager.RestoreWallet(record.Wallet)
+	case RecordPending:
+		// 进入交易池的时间以应用记录的时间为准，节点重启后超时重新计算
+		if record.Transaction.Multisig != nil {
+			n.walletManager.removeMultisigTx(record.Transaction.ID)
+		}
+		removed, err := n.mempool.Add(record.Transaction, time.Now())
+		if err != nil {
+			return err
+		}
+		n.publish(&Event{Type: EventNewTx, Transaction: record.Transaction})
+		n.recordDropped(record.Transaction, removed)
+	case RecordEvict:
+		for _, f := range record.Failed {
+			n.mempool.Remove(f.Transaction.ID)
+		}
+		n.failedTransactions = append(n.failedTransactions, record.Failed...)
+		n.publishFailed(record.Failed)
+	case RecordBlock:
+		return n.connectBlock(record.Block, record.Failed)
+	case RecordMultisig:
+		n.walletManager.addMultisig(record.Multisig)
+	case RecordMultisigTx:
+		n.walletManager.putMultisigTx(record.Transaction)
+	case RecordChain:
+		// 导入的区块链从创世区块开始重新执行，余额完全由区块推导
+		wm, history, included, err := n.replayChain(record.Blocks)
+		if err != nil {
+			return err
+		}
+		n.chain = record.Blocks
+		n.walletManager = wm
+		n.transactions = history
+		n.includedTxs = included
+		n.resetBlockIndex()
+		n.prunePendingTransactions(nil)
+	default:
+		return fmt.Errorf("未知的记录类型: %s", record.Type)
+	}
+	return nil
+}
+
+// recordDropped 把加入新交易时被替换或淘汰出交易池的交易记为失败
+func (n *Node) recordDropped(added *Transaction, removed []*Transaction) {
+	height := 1
+	if len(n.chain) > 0 {
+		height = n.chain[len(n.chain)-1].Height + 1
+	}
+	
+	for _, tx := range removed {
+		reason := "交易池已满，被手续费率更高的交易淘汰"
+		if tx.From == added.From && tx.Nonce == added.Nonce {
+			reason = fmt.Sprintf("被手续费更高的交易 %s 替换", added.ID)
+		}
+		f := &FailedTransaction{
+			Transaction: tx,
+			Height:      height,
+			Reason:      reason,
+		}
+		n.failedTransactions = append(n.failedTransactions, f)
+		n.publishFailed([]*FailedTransaction{f})
+	}
+}
+
+// prunePendingTransactions 从交易池中移除已上链、本次打包失败以及序号已被使用的交易
+//
+// 其他节点打包的区块中可能包含同一序号的另一笔交易，本地这笔交易再也无法上链。
+func (n *Node) prunePendingTransactions(failed []*FailedTransaction) {
+	ids := make([]string, 0, len(failed))
+	for _, f := range failed {
+		ids = append(ids, f.Transaction.ID)
+	}
+	for _, tx := range n.mempool.Transactions() {
+		if _, included := n.includedTxs[tx.ID]; included {
+			ids = append(ids, tx.ID)
+			continue
+		}
+		if isSequenced(tx) {
+			if account, _ := n.walletManager.account(tx.From); tx.Nonce < account.Nonce {
+				ids = append(ids, tx.ID)
+			}
+		}
+	}
+	n.mempool.Remove(ids...)
 }
 
 // CreateGenesisBlock 创建创世区块
@@ -87,26 +395,46 @@ func (n *Node) CreateGenesisBlock(data string) error {
 		Height:    1,
 		Timestamp: time.Now(),
 		Data:      data,
-		PrevHash:  "0000000000000000000000000000000000000000000000000000000000000000",
+		TxRoot:    CalculateTxRoot(nil),
+		PrevHash:  genesisPrevHash,
 		Validator: n.validator,
 	}
+	if err := n.engine.Prepare(block, nil, n.lookupBlock); err != nil {
+		return err
+	}
 	
 	// 计算区块哈希并签名
-	block.Hash = n.calculateBlockHash(block)
-	block.Signature = n.signBlock(block)
+	if !n.engine.Seal(block, func() bool { return false }) {
+		return fmt.Errorf("封装创世区块失败")
+	}
+	signature, err := n.signBlock(block)
+	if err != nil {
+		return err
+	}
+	block.Signature = signature
+	if err := n.checkBlock(block); err != nil {
+		return fmt.Errorf("创世区块无效: %v", err)
+	}
 	
-	// 添加到链
-	n.chain = append(n.chain, block)
+	// 持久化后添加到链
+	record := &StoreRecord{Type: RecordBlock, Block: block}
+	if err := n.persist(record); err != nil {
+		return err
+	}
 	
+	if err := n.applyRecord(record); err != nil {
+		return err
+	}
+	n.announceBlock(block)
 	return nil
 }
 
-// AddTransaction 添加一个交易到等待队列
-func (n *Node) AddTransaction(data string) {
+// AddTransaction 添加一个数据交易到交易池
+func (n *Node) AddTransaction(data string) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	
-	n.pendingTransactions = append(n.pendingTransactions, data)
+	return n.addPending(NewDataTransaction(data))
 }
 
 // StartMining 开始生成区块
@@ -127,17 +455,22 @@ func (n *Node) StartMining() {
 	}
 	
 	n.mining = true
+	stop, done := n.stopMining, make(chan struct{})
+	n.engineDone = done
+	n.publish(&Event{Type: EventMiningStarted})
 	n.mu.Unlock()
 	
-	go n.mineBlocks()
+	go func() {
+		defer close(done)
+		n.engine.Run(n, stop)
+	}()
 }
 
-// StopMining 停止生成区块
+// StopMining 停止生成区块，等待共识引擎退出后返回，之后不会再有区块由引擎提交
 func (n *Node) StopMining() {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-	
 	if !n.mining {
+		n.mu.Unlock()
 		return
 	}
 	
@@ -152,107 +485,262 @@ func (n *Node) StopMining() {
 	}
 	
 	n.mining = false
+	n.publish(&Event{Type: EventMiningStopped})
+	done := n.engineDone
+	n.mu.Unlock()
+	
+	// 引擎可能正在等待节点的锁，释放锁之后再等待它退出
+	<-done
 }
 
-// mineBlocks 区块生成的主循环
-func (n *Node) mineBlocks() {
-	ticker := time.NewTicker(time.Duration(n.blockTime) * time.Second)
-	defer ticker.Stop()
+// generateNewBlock 立即生成一个新区块
+func (n *Node) generateNewBlock() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	
-	for {
-		select {
-		case <-n.stopMining:
-			return
-		case <-ticker.C:
-			n.generateNewBlock()
-		}
+	block, failed := n.buildBlock()
+	if block == nil {
+		return
+	}
+	if !n.engine.Seal(block, func() bool { return false }) {
+		return
+	}
+	if err := n.commitBlock(block, failed); err != nil {
+		log.Printf("提交区块 %d 失败: %v", block.Height, err)
 	}
 }
 
-// generateNewBlock 生成一个新区块
-func (n *Node) generateNewBlock() {
+// proposeBlock 加锁构造候选区块，供在锁外封装区块的共识引擎使用
+func (n *Node) proposeBlock() (*Block, []*FailedTransaction) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	
+	return n.buildBlock()
+}
+
+// submitBlock 加锁提交已封装的候选区块
+func (n *Node) submitBlock(block *Block, failed []*FailedTransaction) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	
+	return n.commitBlock(block, failed)
+}
+
+// buildBlock 构造接在链尾之后的候选区块，还没有创世区块时返回nil，调用者需持有写锁
+func (n *Node) buildBlock() (*Block, []*FailedTransaction) {
 	// 检查是否有创世区块
 	if len(n.chain) == 0 {
-		return
-	}
-	
-	// 获取待处理交易
-	var blockData string
-	if len(n.pendingTransactions) > 0 {
-		// 取第一个交易作为区块数据
-		blockData = n.pendingTransactions[0]
-		// 移除已处理的交易
-		n.pendingTransactions = n.pendingTransactions[1:]
-	} else {
-		blockData = "空块"
+		return nil, nil
 	}
 	
 	// 获取最后一个区块
 	prevBlock := n.chain[len(n.chain)-1]
+	height := prevBlock.Height + 1
 	
-	// 创建新区块
-	newBlock := &Block{
-		Height:    prevBlock.Height + 1,
-		Timestamp: time.Now(),
-		Data:      blockData,
-		PrevHash:  prevBlock.Hash,
-		Validator: n.validator,
-	}
-	
-	// 计算哈希和签名
-	newBlock.Hash = n.calculateBlockHash(newBlock)
-	newBlock.Signature = n.signBlock(newBlock)
+	// 先移出在交易池中停留过久的交易
+	n.evictExpired(time.Now(), height)
 	
-	// 添加到链
-	n.chain = append(n.chain, newBlock)
-	
-	// 给矿工发放挖矿奖励
-	if n.walletManager != nil && n.minerAddress != "" {
+	// 第一笔交易是给矿工的挖矿奖励，金额由货币政策决定
+	// 先解锁到期的资金，解锁时间以新区块的时间为准；
+	// 前一区块的时间可能略晚于本地时间（来自时钟稍快的节点），新区块的时间不能早于它
+	var txs []*Transaction
+	now := time.Now()
+	if now.Before(prevBlock.Timestamp) {
+		now = prevBlock.Timestamp
+	}
+	overlay := n.walletManager.newStateOverlay()
+	overlay.supplyCap = n.genesis.MonetaryPolicy.SupplyCap
+	overlay.release(height, now.Unix())
+	if reward := n.genesis.BlockReward(height, n.walletManager.totalMinted()); n.minerAddress != "" && reward > 0 {
 		rewardTx := &Transaction{
-			ID:        "mining-reward-" + fmt.Sprintf("%d", newBlock.Height),
+			ID:        "mining-reward-" + fmt.Sprintf("%d", height),
+			Type:      TxTypeMining,
 			From:      "system",
 			To:        n.minerAddress,
-			Amount:    n.miningReward,
+			Amount:    reward,
 			Fee:       0,
 			Timestamp: time.Now().Unix(),
 			Signature: "system-reward",
 		}
-		
-		// 处理挖矿奖励交易
-		n.walletManager.ProcessTransaction(rewardTx)
-		n.transactions = append(n.transactions, rewardTx)
+		overlay.apply(rewardTx)
+		txs = append(txs, rewardTx)
+	}
+	
+	// 按手续费率从高到低打包交易池中的交易，直到达到区块的数量或大小上限；
+	// 手续费支付给出块者时，最后还要留出一笔手续费支付交易的位置
+	reserved := txs
+	if !n.genesis.burnsFees() {
+		reserved = append(append([]*Transaction{}, txs...), newFeeTransaction(height, n.minerAddress, BaseDenom, 0, time.Now().Unix()))
+	}
+	selected, failed := n.selectPendingTransactions(overlay, reserved, height)
+	txs = append(txs, selected...)
+	if !n.genesis.burnsFees() {
+		txs = append(txs, feeTransactions(height, n.minerAddress, overlay.fees, time.Now().Unix())...)
+	}
+	
+	// 创建新区块，由共识引擎填写难度等字段
+	newBlock := &Block{
+		Height:       height,
+		Timestamp:    now,
+		Transactions: txs,
+		TxRoot:       CalculateTxRoot(txs),
+		PrevHash:     prevBlock.Hash,
+		Validator:    n.validator,
+	}
+	if err := n.engine.Prepare(newBlock, prevBlock, n.lookupBlock); err != nil {
+		log.Printf("准备区块 %d 失败: %v", height, err)
+		return nil, nil
+	}
+	return newBlock, failed
+}
+
+// commitBlock 签名并提交已封装的区块，调用者需持有写锁
+//
+// 封装期间链尾可能已经变化（例如收到了其他节点的区块），这时丢弃该区块。
+func (n *Node) commitBlock(newBlock *Block, failed []*FailedTransaction) error {
+	if tip := n.tip(); tip == nil || tip.Hash != newBlock.PrevHash {
+		return fmt.Errorf("链尾已变化，丢弃区块")
+	}
+	
+	signature, err := n.signBlock(newBlock)
+	if err != nil {
+		return fmt.Errorf("签名失败: %v", err)
+	}
+	newBlock.Signature = signature
+	
+	// 写入存储前按与外部区块相同的规则完整检查一遍（区块头、共识规则和交易），
+	// 无效的区块不能进入存储，否则重启回放时会失败
+	if err := n.checkBlock(newBlock); err != nil {
+		return fmt.Errorf("区块 %d 无效: %v", newBlock.Height, err)
+	}
+	
+	// 先写入存储再更新内存状态，写入失败时丢弃该区块
+	record := &StoreRecord{Type: RecordBlock, Block: newBlock, Failed: failed}
+	if err := n.persist(record); err != nil {
+		return fmt.Errorf("保存失败: %v", err)
+	}
+	
+	if err := n.applyRecord(record); err != nil {
+		return err
+	}
+	n.announceBlock(newBlock)
+	return nil
+}
+
+// lookupBlock 按哈希查找主链或分支上的区块，调用者需持有锁
+func (n *Node) lookupBlock(hash string) *Block {
+	return n.blocks[hash]
+}
+
+// tipHash 加锁读取链尾区块的哈希
+func (n *Node) tipHash() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
+	if tip := n.tip(); tip != nil {
+		return tip.Hash
 	}
+	return ""
+}
+
+// evictExpired 把超过TTL仍未打包的交易移出交易池并记为失败
+func (n *Node) evictExpired(now time.Time, height int) {
+	expired := n.mempool.Expired(now)
+	if len(expired) == 0 {
+		return
+	}
+	
+	failed := make([]*FailedTransaction, len(expired))
+	for i, tx := range expired {
+		failed[i] = &FailedTransaction{
+			Transaction: tx,
+			Height:      height,
+			Reason:      fmt.Sprintf("在交易池中超过 %v 未被打包", n.mempool.cfg.TTL),
+		}
+	}
+	
+	record := &StoreRecord{Type: RecordEvict, Failed: failed}
+	if err := n.persist(record); err != nil {
+		log.Printf("保存过期交易失败: %v", err)
+		return
+	}
+	if err := n.applyRecord(record); err != nil {
+		log.Printf("移除过期交易失败: %v", err)
+	}
+}
+
+// selectPendingTransactions 按打包优先级从交易池中选出能放入区块的交易
+//
+// 每笔交易都在临时状态上按顺序验证执行，此时已不再有效的交易（例如余额不足、
+// 已经上链、手续费低于下限）会被标记为失败并记录原因。
+func (n *Node) selectPendingTransactions(overlay *stateOverlay, included []*Transaction, height int) ([]*Transaction, []*FailedTransaction) {
+	count := len(included)
+	size := 0
+	for _, tx := range included {
+		size += len(tx.Bytes())
+	}
+	
+	var selected []*Transaction
+	var failed []*FailedTransaction
+	for _, tx := range n.mempool.Transactions() {
+		txSize := len(tx.Bytes())
+		if count+1 > n.maxBlockTxs || size+txSize > n.maxBlockBytes {
+			break
+		}
+		err := checkBlockUserTransaction(tx, n.genesis)
+		if _, exists := n.includedTxs[tx.ID]; exists {
+			err = fmt.Errorf("交易 %s 已经上链", tx.ID)
+		}
+		if err == nil {
+			err = overlay.apply(tx)
+		}
+		if err != nil {
+			failed = append(failed, &FailedTransaction{
+				Transaction: tx,
+				Height:      height,
+				Reason:      err.Error(),
+			})
+			continue
+		}
+		selected = append(selected, tx)
+		count++
+		size += txSize
+	}
+	return selected, failed
 }
 
 // calculateBlockHash 计算区块的哈希值
-func (n *Node) calculateBlockHash(block *Block) string {
+//
+// 时间戳使用纳秒整数参与计算，保证区块经过JSON导出导入后哈希不变；
+// 交易通过TxRoot间接提交到区块哈希中。
+func calculateBlockHash(block *Block) string {
 	blockData := fmt.Sprintf(
-		"%d%s%s%s%s",
+		"%d%d%s%s%s%s%d%d",
 		block.Height,
-		block.Timestamp.String(),
+		block.Timestamp.UnixNano(),
 		block.Data,
+		block.TxRoot,
 		block.PrevHash,
 		block.Validator,
+		block.Difficulty,
+		block.Nonce,
 	)
 	
 	hash := sha256.Sum256([]byte(blockData))
 	return hex.EncodeToString(hash[:])
 }
 
-// signBlock 为区块创建签名
-func (n *Node) signBlock(block *Block) Signature {
-	// 简化的模拟签名过程
-	message := block.Hash + block.Validator
-	rHash := sha256.Sum256([]byte(message + "r"))
-	sHash := sha256.Sum256([]byte(message + "s"))
+// signBlock 使用验证者私钥对区块哈希签名
+func (n *Node) signBlock(block *Block) (Signature, error) {
+	signature, err := SignBytes(n.validatorKey, []byte(block.Hash))
+	if err != nil {
+		return Signature{}, err
+	}
 	
 	return Signature{
-		R: hex.EncodeToString(rHash[:])[:16],
-		S: hex.EncodeToString(sHash[:])[:16],
-	}
+		R: signature[:64],
+		S: signature[64:],
+	}, nil
 }
 
 // ExportBlockchain 将区块链状态导出到文件
@@ -283,10 +771,128 @@ func (n *Node) ImportBlockchain(filename string) error {
 		return err
 	}
 	
-	n.chain = chain
+	// 拒绝任何未通过完整校验的区块链，以及交易无法按顺序执行的区块链
+	if err := n.validateChain(chain); err != nil {
+		return fmt.Errorf("导入的区块链无效: %w", err)
+	}
+	now := time.Now()
+	for _, block := range chain {
+		if err := checkBlockTime(block, now); err != nil {
+			return fmt.Errorf("导入的区块链无效: %w", &ChainValidationError{Height: block.Height, Reason: err.Error()})
+		}
+	}
+	
+	// 与本地创世区块相同的区块链按分叉选择规则逐个接入，更重时才切换主链
+	if len(chain) > 0 && len(n.chain) > 0 && chain[0].Hash == n.chain[0].Hash {
+		for _, block := range chain {
+			if err := n.addBlock(block, nil); err != nil && !errors.Is(err, errKnownBlock) {
+				return fmt.Errorf("导入的区块链无效: %w", err)
+			}
+		}
+		return nil
+	}
+	
+	// 创世区块不同时整体替换本地区块链；使用创世文件的节点只接受同一条链
+	if n.genesisHash != "" && (len(chain) == 0 || chain[0].Hash != n.genesisHash) {
+		return fmt.Errorf("导入的区块链与创世文件 %s 的创世区块不一致", n.genesis.ChainID)
+	}
+	if _, _, _, err := n.replayChain(chain); err != nil {
+		return fmt.Errorf("导入的区块链无效: %w", err)
+	}
+	
+	record := &StoreRecord{Type: RecordChain, Blocks: chain}
+	if err := n.persist(record); err != nil {
+		return err
+	}
+	
+	return n.applyRecord(record)
+}
+
+// AddPeerBlock 接受其他节点广播或同步来的区块
+//
+// 区块可以接在主链或任意分支上，通过与本地生成的区块相同的校验后写入存储，
+// 再按分叉选择规则决定是否切换主链。
+func (n *Node) AddPeerBlock(block *Block) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	
+	return n.addBlock(block, nil)
+}
+
+// addBlock 检查、持久化并接入一个外部区块，调用者需持有写锁
+//
+// failed是本节点提议该区块时打包失败的交易，外部区块为nil。
+func (n *Node) addBlock(block *Block, failed []*FailedTransaction) error {
+	if err := n.checkBlock(block); err != nil {
+		return err
+	}
+	
+	record := &StoreRecord{Type: RecordBlock, Block: block, Failed: failed}
+	if err := n.persist(record); err != nil {
+		return err
+	}
+	if err := n.applyRecord(record); err != nil {
+		return err
+	}
+	n.announceBlock(block)
 	return nil
 }
 
+// AddPeerTransaction 接受其他节点广播来的交易，验证通过后放入交易池
+func (n *Node) AddPeerTransaction(tx *Transaction) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	
+	if _, included := n.includedTxs[tx.ID]; included || n.mempool.Contains(tx.ID) {
+		return errKnownTransaction
+	}
+	
+	// 节点之间只转发用户签名的交易和数据交易，系统交易只能由出块节点放入区块
+	if tx.Type != TxTypeData {
+		if err := userTransaction(tx); err != nil {
+			return err
+		}
+	}
+	if err := n.validatePending(tx); err != nil {
+		return err
+	}
+	
+	return n.addPending(tx)
+}
+
+// GetGenesisHash 获取创世区块哈希，还没有创世区块时返回空字符串
+func (n *Node) GetGenesisHash() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
+	if len(n.chain) == 0 {
+		return ""
+	}
+	return n.chain[0].Hash
+}
+
+// SetBroadcaster 设置广播交易和区块的网络层
+func (n *Node) SetBroadcaster(b Broadcaster) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	
+	n.broadcaster = b
+}
+
+// announceBlock 广播新接受的区块，调用者需持有锁
+func (n *Node) announceBlock(block *Block) {
+	if n.broadcaster != nil {
+		n.broadcaster.BroadcastBlock(block)
+	}
+}
+
+// announceTransaction 广播新进入交易池的交易，调用者需持有锁
+func (n *Node) announceTransaction(tx *Transaction) {
+	if n.broadcaster != nil {
+		n.broadcaster.BroadcastTransaction(tx)
+	}
+}
+
 // GetHeight 获取区块链当前高度
 func (n *Node) GetHeight() int {
 	n.mu.RLock()
@@ -304,13 +910,7 @@ func (n *Node) GetBlockByHeight(height int) *Block {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 	
-	for _, block := range n.chain {
-		if block.Height == height {
-			return block
-		}
-	}
-	
-	return nil
+	return n.blockAt(height)
 }
 
 // GetAllBlocks 获取所有区块
@@ -325,45 +925,203 @@ func (n *Node) GetAllBlocks() []*Block {
 	return result
 }
 
+// GetTransactionProof 返回已打包交易的Merkle包含证明
+func (n *Node) GetTransactionProof(txID string) (*MerkleProof, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
+	// 通过已上链交易索引找到所在区块，只在这个区块中查找
+	if height, included := n.includedTxs[txID]; included {
+		if block := n.blockAt(height); block != nil {
+			for i, tx := range block.Transactions {
+				if tx.ID == txID {
+					return buildMerkleProof(block, i), nil
+				}
+			}
+		}
+	}
+	
+	return nil, fmt.Errorf("交易 %s 尚未打包进区块", txID)
+}
+
 // 钱包管理方法
-func (n *Node) CreateWallet() *Wallet {
-	return n.walletManager.CreateWallet()
+func (n *Node) CreateWallet() (*Wallet, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	
+	wallet, err := n.walletManager.newWallet()
+	if err != nil {
+		return nil, err
+	}
+	record := &StoreRecord{Type: RecordWallet, Wallet: wallet}
+	if err := n.persist(record); err != nil {
+		return nil, err
+	}
+	
+	if err := n.applyRecord(record); err != nil {
+		return nil, err
+	}
+	return wallet, nil
 }
 
 func (n *Node) GetWallet(address string) *Wallet {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
 	return n.walletManager.GetWallet(address)
 }
 
 func (n *Node) GetAllWallets() []*Wallet {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
 	return n.walletManager.GetAllWallets()
 }
 
 func (n *Node) GetBalance(address string) int64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
 	return n.walletManager.GetBalance(address)
 }
 
-// 转账方法
-func (n *Node) Transfer(from, to string, amount int64) error {
+// GetStateHash 获取当前余额状态的哈希
+func (n *Node) GetStateHash() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	
+	return n.walletManager.StateHash()
+}
+
+// Transfer 创建一笔转账并放入交易池
+//
+// 这里只做签名和余额的初步检查，转账在打包进区块时才会真正执行。
+// 手续费越高，在交易池中的打包优先级越高。
+func (n *Node) Transfer(from, to string, amount, fee int64) (*Transaction, error) {
+	return n.submitTransaction(TxTypeTransfer, from, to, Coin{Denom: BaseDenom, Amount: amount}, Coin{Denom: BaseDenom, Amount: fee}, LockCondition{})
+}
+
+// ReplaceTransaction 用更高的手续费重新签名交易池中的一笔转账，替换原交易
+//
+// 新交易使用与原交易相同的序号，手续费率至少需要提高10%。
+func (n *Node) ReplaceTransaction(id string, fee int64) (*Transaction, error) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	
+	entry := n.mempool.Get(id)
+	if entry == nil {
+		return nil, fmt.Errorf("交易 %s 不在交易池中", id)
+	}
+	old := entry.Transaction
+	if !isSequenced(old) {
+		return nil, fmt.Errorf("只有转账交易可以替换")
+	}
+	
+	amount := Coin{Denom: denomOf(old.Denom), Amount: old.Amount}
+	newFee := Coin{Denom: denomOf(old.FeeDenom), Amount: fee}
+	tx, err := n.walletManager.CreateCoinTransaction(old.Type, old.From, old.To, amount, newFee, old.Nonce, old.LockCondition)
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := n.validatePending(tx); err != nil {
+		return nil, err
+	}
+	
+	if err := n.addPending(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
 
-	fee := int64(1) // 固定手续费1币
-	tx := n.walletManager.CreateTransaction(from, to, amount, fee)
+// addPending 持久化交易并放入交易池，挖矿时再执行
+func (n *Node) addPending(tx *Transaction) error {
+	record := &StoreRecord{Type: RecordPending, Transaction: tx}
+	if err := n.persist(record); err != nil {
+		return err
+	}
 	
-	// 验证并处理交易
-	if err := n.walletManager.ProcessTransaction(tx); err != nil {
+	if err := n.applyRecord(record); err != nil {
 		return err
 	}
+	n.announceTransaction(tx)
+	return nil
+}
+
+// nextNonce 返回账户下一笔交易应使用的序号，已在交易池中的交易也计算在内
+func (n *Node) nextNonce(address string) uint64 {
+	account, _ := n.walletManager.account(address)
+	return n.mempool.NextNonce(address, account.Nonce)
+}
+
+// validatePending 基于已提交的余额验证准备放入交易池的交易
+//
+// 序号必须是考虑交易池后的下一个序号，或者与交易池中已有的交易相同（替换该交易）。
+func (n *Node) validatePending(tx *Transaction) error {
+	return n.checkPending(tx, true)
+}
+
+// checkPending 与 validatePending 相同，verifySignatures为false时跳过签名检查
+func (n *Node) checkPending(tx *Transaction, verifySignatures bool) error {
+	accountOf := func(address string) (accountState, bool) {
+		account, exists := n.walletManager.account(address)
+		next := n.mempool.NextNonce(address, account.Nonce)
+		if address == tx.From && tx.Nonce >= account.Nonce && tx.Nonce < next {
+			account.Nonce = tx.Nonce
+		} else {
+			account.Nonce = next
+		}
+		return account, exists
+	}
+	if isSequenced(tx) {
+		if err := n.genesis.FeePolicy.checkFee(tx); err != nil {
+			return err
+		}
+	}
+	if tx.Type == TxTypeIssue {
+		if creator := n.walletManager.denomCreator(tx.Denom); creator != "" && creator != tx.From {
+			return fmt.Errorf("代币 %s 只能由创建者 %s 发行", tx.Denom, creator)
+		}
+	}
+	return n.walletManager.checkTransaction(tx, accountOf, verifySignatures)
+}
+
+// DefaultFee 获取转账未指定手续费时使用的手续费
+func (n *Node) DefaultFee() int64 {
+	return n.genesis.FeePolicy.DefaultFee
+}
 
-	// 添加到交易历史
-	n.transactions = append(n.transactions, tx)
+// GetNonce 获取账户下一笔交易应使用的序号
+func (n *Node) GetNonce(address string) uint64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	
-	// 将交易信息添加到待处理交易池，这样挖矿时就能包含在区块中
-	transactionData := fmt.Sprintf("转账: %s -> %s, 金额: %d, 手续费: %d, ID: %s", 
-		from, to, amount, fee, tx.ID)
-	n.pendingTransactions = append(n.pendingTransactions, transactionData)
+	return n.nextNonce(address)
+}
+
+// GetMempoolStats 获取交易池统计信息
+func (n *Node) GetMempoolStats() MempoolStats {
+	return n.mempool.Stats()
+}
+
+// GetMempoolEntries 按打包优先级获取交易池中的全部交易
+func (n *Node) GetMempoolEntries() []*MempoolEntry {
+	return n.mempool.Entries()
+}
+
+// GetMempoolEntry 按ID获取交易池中的交易
+func (n *Node) GetMempoolEntry(id string) *MempoolEntry {
+	return n.mempool.Get(id)
+}
+
+// GetFailedTransactions 获取打包时验证失败或被移出交易池的交易
+func (n *Node) GetFailedTransactions() []*FailedTransaction {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	
-	return nil
+	result := make([]*FailedTransaction, len(n.failedTransactions))
+	copy(result, n.failedTransactions)
+	return result
 }
 
 // 获取交易历史
@@ -371,8 +1129,8 @@ func (n *Node) GetTransactions() []*Transaction {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 	
-	result := make([]*Transaction, len(n.transactions))
-	copy(result, n.transactions)
+	result := make([]*Transaction, len(n.transactions.txs))
+	copy(result, n.transactions.txs)
 	return result
 }
 
@@ -381,27 +1139,48 @@ func (n *Node) GetMinerAddress() string {
 	return n.minerAddress
 }
 
+// 获取验证者公钥
+func (n *Node) GetValidator() string {
+	return n.validator
+}
+
 // 获取挖矿统计
 func (n *Node) GetMiningStats() map[string]interface{} {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 	
-	// 计算挖矿收益
-	totalReward := int64(0)
-	blocksMined := 0
-	
+	// 按验证者公钥统计出块数（排除创世区块），出块奖励随货币政策变化，按区块中的实际金额累计
+	blocksByValidator := make(map[string]int)
+	var totalReward int64
+	totalFees := make(Coins)
 	for _, block := range n.chain {
-		if block.Validator == n.validator && block.Height > 1 { // 排除创世区块
-			totalReward += n.miningReward
-			blocksMined++
+		if block.Height <= 1 {
+			continue
+		}
+		blocksByValidator[block.Validator]++
+		if block.Validator != n.validator {
+			continue
+		}
+		for _, tx := range block.Transactions {
+			switch tx.Type {
+			case TxTypeMining:
+				totalReward += tx.Amount
+			case TxTypeFee:
+				totalFees.add(tx.Denom, tx.Amount)
+			}
 		}
 	}
+	blocksMined := blocksByValidator[n.validator]
 	
 	return map[string]interface{}{
-		"miner_address":  n.minerAddress,
-		"blocks_mined":   blocksMined,
-		"total_reward":   totalReward,
-		"mining_reward":  n.miningReward,
-		"is_mining":      n.mining,
+		"miner_address":       n.minerAddress,
+		"validator":           n.validator,
+		"blocks_mined":        blocksMined,
+		"blocks_by_validator": blocksByValidator,
+		"total_reward":        totalReward,
+		"total_fees":          totalFees,
+		"mining_reward":       n.genesis.BlockReward(len(n.chain)+1, n.walletManager.totalMinted()),
+		"is_mining":           n.mining,
+		"consensus":           n.engine.Name(),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,210 @@
+package blockchain
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTransferExecutesAtBlockInclusion(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+	bob, _ := node.CreateWallet()
+
+	first, err := node.Transfer(alice.Address, bob.Address, 3000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 提交时余额还未变化，所以第二笔转账也能进入交易池
+	second, err := node.Transfer(alice.Address, bob.Address, 3000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.GetBalance(alice.Address) != 5000 || node.GetBalance(bob.Address) != 0 {
+		t.Fatal("转账在打包前不应改变余额")
+	}
+
+	node.generateNewBlock()
+
+	if got := node.GetBalance(alice.Address); got != 1998 {
+		t.Fatalf("发送方余额应为1998，实际为%d", got)
+	}
+	if got := node.GetBalance(bob.Address); got != 3000 {
+		t.Fatalf("接收方余额应为3000，实际为%d", got)
+	}
+
+	block := node.GetBlockByHeight(2)
+	if len(block.Transactions) != 2 || block.Transactions[1].ID != first.ID {
+		t.Fatal("区块应只包含挖矿奖励和第一笔转账")
+	}
+
+	failed := node.GetFailedTransactions()
+	if len(failed) != 1 || failed[0].Transaction.ID != second.ID || failed[0].Reason == "" {
+		t.Fatalf("第二笔转账应被标记为失败并给出原因: %+v", failed)
+	}
+	if node.mempool.Size() != 0 {
+		t.Fatal("失败的交易应从交易池中移除")
+	}
+}
+
+func TestImportRebuildsBalancesFromBlocks(t *testing.T) {
+	// 矿工钱包的余额来自出块奖励
+	node := newTestChain(t, 3)
+	bob, _ := node.CreateWallet()
+	if _, err := node.Transfer(node.GetMinerAddress(), bob.Address, 150, 1); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+	node.generateNewBlock()
+
+	file := filepath.Join(t.TempDir(), "chain.json")
+	if err := node.ExportBlockchain(file); err != nil {
+		t.Fatal(err)
+	}
+
+	peer := newTestChain(t, 0)
+	if err := peer.ImportBlockchain(file); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, address := range []string{bob.Address, node.GetMinerAddress()} {
+		if got, want := peer.GetBalance(address), node.GetBalance(address); got != want {
+			t.Fatalf("%s 的余额应为%d，实际为%d", address, want, got)
+		}
+	}
+	if peer.GetStateHash() != node.GetStateHash() {
+		t.Fatal("导入后的状态哈希应与原节点一致")
+	}
+
+	if err := node.RebuildState(); err != nil {
+		t.Fatal(err)
+	}
+	if peer.GetStateHash() != node.GetStateHash() {
+		t.Fatal("回放重建的状态应与增量执行的结果一致")
+	}
+}
+
+func TestPeerCannotInjectSystemTransactions(t *testing.T) {
+	node := newTestChain(t, 2)
+	alice, _ := node.CreateWallet()
+
+	forged := []*Transaction{
+		{Type: TxTypeTransfer, From: "system", To: alice.Address, Amount: 1000000, Timestamp: 1700000000},
+		{Type: TxTypeMining, From: "system", To: alice.Address, Amount: 1000000, Timestamp: 1700000000},
+		{Type: "faucet", From: "system", To: alice.Address, Amount: 1000000, Timestamp: 1700000000},
+	}
+	for _, tx := range forged {
+		tx.ID = tx.Hash()
+		if err := node.AddPeerTransaction(tx); err == nil {
+			t.Fatalf("节点应拒绝来自对等节点的系统交易: %+v", tx)
+		}
+	}
+	if node.mempool.Size() != 0 {
+		t.Fatal("伪造的系统交易不应进入交易池")
+	}
+
+	node.generateNewBlock()
+	if node.GetHeight() != 3 {
+		t.Fatalf("区块链应继续增长到高度3，实际为%d", node.GetHeight())
+	}
+	if got := node.GetBalance(alice.Address); got != 0 {
+		t.Fatalf("伪造的系统交易不应改变余额，实际为%d", got)
+	}
+}
+
+func TestBlockSkipsTransactionsBelowMinFee(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	// 绕过交易池的检查，直接放入一笔手续费低于下限的交易
+	cheap, err := node.walletManager.CreateCoinTransaction(TxTypeTransfer, alice.Address, testAddress("bob"),
+		Coin{Denom: BaseDenom, Amount: 100}, Coin{Denom: BaseDenom, Amount: 1}, 0, LockCondition{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.mempool.Add(cheap, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	node.generateNewBlock()
+	if node.GetHeight() != 2 {
+		t.Fatalf("区块链应继续增长到高度2，实际为%d", node.GetHeight())
+	}
+	if block := node.GetBlockByHeight(2); len(block.Transactions) != 1 {
+		t.Fatal("手续费低于下限的交易不应被打包")
+	}
+	failed := node.GetFailedTransactions()
+	if len(failed) != 1 || failed[0].Transaction.ID != cheap.ID {
+		t.Fatalf("低手续费交易应被标记为失败: %+v", failed)
+	}
+	if err := node.ValidateChain(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuiltBlockIsNotOlderThanParent(t *testing.T) {
+	dir := t.TempDir()
+	node, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 前一区块来自时钟稍快的节点，时间略晚于本地时间
+	node.mu.Lock()
+	block, failed := node.buildBlock()
+	block.Timestamp = block.Timestamp.Add(5 * time.Second)
+	node.engine.Seal(block, func() bool { return false })
+	err = node.commitBlock(block, failed)
+	node.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node.generateNewBlock()
+	if node.GetHeight() != 3 {
+		t.Fatalf("高度应为3，实际为%d", node.GetHeight())
+	}
+	if tip := node.GetBlockByHeight(3); tip.Timestamp.Before(block.Timestamp) {
+		t.Fatal("新区块的时间不应早于前一区块")
+	}
+	node.Close()
+
+	recovered, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatalf("节点应能重启: %v", err)
+	}
+	recovered.Close()
+}
+
+func TestPeerDataTransactionMustMatchItsID(t *testing.T) {
+	node := newTestChain(t, 2)
+	alice, _ := node.CreateWallet()
+
+	// 数据交易冒用一笔转账的ID时会被拒绝，转账本身仍能进入交易池
+	transfer, err := node.walletManager.CreateCoinTransaction(TxTypeTransfer, node.GetMinerAddress(), alice.Address,
+		Coin{Denom: BaseDenom, Amount: 10}, Coin{Denom: BaseDenom, Amount: 1}, 0, LockCondition{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := NewDataTransaction("spam")
+	forged.ID = transfer.ID
+	if err := node.AddPeerTransaction(forged); err == nil {
+		t.Fatal("ID与内容不匹配的数据交易应被拒绝")
+	}
+	if err := node.AddPeerTransaction(transfer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.AddPeerTransaction(NewDataTransaction("hello")); err != nil {
+		t.Fatal(err)
+	}
+}
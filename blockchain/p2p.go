@@ -0,0 +1,507 @@
+package blockchain
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// P2P消息类型
+const (
+	MsgHello       = "hello"      // 握手：节点ID、监听地址、高度和创世区块哈希
+	MsgTransaction = "tx"         // 广播交易
+	MsgBlock       = "block"      // 广播区块
+	MsgGetBlocks   = "get_blocks" // 请求共同区块之后的主链区块
+	MsgBlocks      = "blocks"     // 返回请求的区块
+)
+
+const (
+	syncBatchSize    = 100             // 每次同步请求最多返回的区块数
+	peerSendQueue    = 256             // 每个连接的发送队列长度
+	handshakeTimeout = 5 * time.Second // 握手超时时间
+	redialInterval   = 5 * time.Second // 重新连接种子节点的间隔
+	dialTimeout      = 3 * time.Second // 连接超时时间
+	maxMessageSize   = 16 << 20        // 单条消息的最大字节数，超过时断开连接
+	maxSyncBytes     = 8 << 20         // 一次同步回复中区块编码的最大总字节数
+	peerDataTxLimit  = 20              // 每个连接在一个时间窗口内最多接受的数据交易数
+	peerDataTxWindow = 10 * time.Second
+)
+
+// Message 是节点之间传输的消息，每条消息编码为一行JSON
+type Message struct {
+	Type        string       `json:"type"`
+	NodeID      string       `json:"node_id,omitempty"`
+	ListenAddr  string       `json:"listen_addr,omitempty"`
+	Height      int          `json:"height,omitempty"`
+	GenesisHash string       `json:"genesis_hash,omitempty"`
+	Locator     []string     `json:"locator,omitempty"` // 请求方主链的区块哈希，用于找到共同区块
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Block       *Block       `json:"block,omitempty"`
+	Blocks      []*Block     `json:"blocks,omitempty"`
+	More        bool         `json:"more,omitempty"` // 同步回复之后还有更多区块
+}
+
+// P2PConfig 网络层配置
+type P2PConfig struct {
+	ListenAddr string   // 监听地址，如 ":26656"
+	Seeds      []string // 启动时连接的种子节点地址
+}
+
+// P2PServer 通过TCP连接其他节点，广播交易和区块，并在启动时同步缺失的区块
+type P2PServer struct {
+	node     *Node
+	cfg      P2PConfig
+	nodeID   string
+	listener net.Listener
+	peers    map[string]*peer // 节点ID到连接
+	quit     chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.RWMutex
+}
+
+// peer 表示与另一个节点的连接
+type peer struct {
+	id        string
+	addr      string // 主动连接时使用的地址，对方连入时为空
+	conn      net.Conn
+	send      chan *Message
+	done      chan struct{} // 连接关闭时关闭
+	closeOnce sync.Once
+
+	// 数据交易没有签名和手续费，按连接限制接收速率；只在读取协程中访问
+	dataWindow time.Time
+	dataCount  int
+}
+
+// NewP2PServer 创建网络层，并注册为节点的广播器
+func NewP2PServer(node *Node, cfg P2PConfig) (*P2PServer, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("生成节点ID失败: %v", err)
+	}
+
+	s := &P2PServer{
+		node:   node,
+		cfg:    cfg,
+		nodeID: hex.EncodeToString(id),
+		peers:  make(map[string]*peer),
+		quit:   make(chan struct{}),
+	}
+	node.SetBroadcaster(s)
+	return s, nil
+}
+
+// Start 开始监听并连接种子节点
+func (s *P2PServer) Start() error {
+	listener, err := net.Listen("tcp", s.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("P2P监听失败: %v", err)
+	}
+	s.listener = listener
+
+	s.wg.Add(2)
+	go s.acceptLoop()
+	go s.dialLoop()
+	return nil
+}
+
+// Stop 关闭监听和所有连接
+func (s *P2PServer) Stop() {
+	select {
+	case <-s.quit:
+		return
+	default:
+		close(s.quit)
+	}
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mu.RLock()
+	for _, p := range s.peers {
+		p.close()
+	}
+	s.mu.RUnlock()
+	s.wg.Wait()
+}
+
+// Addr 返回实际监听的地址
+func (s *P2PServer) Addr() string {
+	if s.listener == nil {
+		return s.cfg.ListenAddr
+	}
+	return s.listener.Addr().String()
+}
+
+// PeerCount 返回当前连接的节点数
+func (s *P2PServer) PeerCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.peers)
+}
+
+// Connect 主动连接一个节点
+func (s *P2PServer) Connect(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+
+	s.wg.Add(1)
+	go s.handleConn(conn, addr)
+	return nil
+}
+
+// BroadcastTransaction 把交易发送给所有连接的节点
+func (s *P2PServer) BroadcastTransaction(tx *Transaction) {
+	s.broadcast(&Message{Type: MsgTransaction, Transaction: tx})
+}
+
+// BroadcastBlock 把区块发送给所有连接的节点
+func (s *P2PServer) BroadcastBlock(block *Block) {
+	s.broadcast(&Message{Type: MsgBlock, Block: block})
+}
+
+// broadcast 把消息放入每个连接的发送队列，不等待发送完成
+func (s *P2PServer) broadcast(msg *Message) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.peers {
+		p.queue(msg)
+	}
+}
+
+// acceptLoop 接受其他节点的连接
+func (s *P2PServer) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			log.Printf("P2P接受连接失败: %v", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.handleConn(conn, "")
+	}
+}
+
+// dialLoop 连接尚未连上的种子节点，断开后定期重试
+func (s *P2PServer) dialLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(redialInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, addr := range s.cfg.Seeds {
+			if s.connectedTo(addr) {
+				continue
+			}
+			if err := s.Connect(addr); err != nil {
+				log.Printf("连接种子节点 %s 失败: %v", addr, err)
+			}
+		}
+
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// connectedTo 判断是否已经主动连接了某个地址
+func (s *P2PServer) connectedTo(addr string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.peers {
+		if p.addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// hello 返回本节点的握手消息
+func (s *P2PServer) hello() *Message {
+	return &Message{
+		Type:        MsgHello,
+		NodeID:      s.nodeID,
+		ListenAddr:  s.Addr(),
+		Height:      s.node.GetHeight(),
+		GenesisHash: s.node.GetGenesisHash(),
+	}
+}
+
+// handleConn 完成握手后读取消息，直到连接断开
+func (s *P2PServer) handleConn(conn net.Conn, addr string) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	// 双方同时发送握手消息，再读取对方的握手消息
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := writeMessage(conn, s.hello()); err != nil {
+		return
+	}
+	remote, err := readMessage(reader)
+	if err != nil || remote.Type != MsgHello {
+		log.Printf("与 %s 握手失败: %v", conn.RemoteAddr(), err)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	if genesis := s.node.GetGenesisHash(); genesis != "" && remote.GenesisHash != "" && genesis != remote.GenesisHash {
+		log.Printf("节点 %s 的创世区块不同，断开连接", conn.RemoteAddr())
+		return
+	}
+
+	p := &peer{
+		id:   remote.NodeID,
+		addr: addr,
+		conn: conn,
+		send: make(chan *Message, peerSendQueue),
+		done: make(chan struct{}),
+	}
+	if !s.addPeer(p) {
+		return
+	}
+	defer s.removePeer(p)
+
+	go p.writeLoop()
+
+	// 对方的区块更多时从对方同步；同时把交易池中的交易发给对方
+	if remote.Height > s.node.GetHeight() {
+		s.requestBlocks(p)
+	}
+	for _, tx := range s.node.mempool.Transactions() {
+		p.queue(&Message{Type: MsgTransaction, Transaction: tx})
+	}
+
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			if errors.Is(err, errMessageTooLarge) {
+				log.Printf("节点 %s 发送的消息过大，断开连接", p.id)
+			}
+			return
+		}
+		s.handleMessage(p, msg)
+	}
+}
+
+// addPeer 登记新连接，拒绝连接自己
+//
+// 两个节点互相连接时会出现重复连接，双方都保留由节点ID较小的一方发起的那条。
+func (s *P2PServer) addPeer(p *peer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.quit:
+		return false
+	default:
+	}
+	if p.id == "" || p.id == s.nodeID {
+		return false
+	}
+	if existing, exists := s.peers[p.id]; exists {
+		if s.initiator(existing) <= s.initiator(p) {
+			return false
+		}
+		existing.close()
+	}
+	s.peers[p.id] = p
+	return true
+}
+
+// initiator 返回发起连接一方的节点ID
+func (s *P2PServer) initiator(p *peer) string {
+	if p.addr != "" {
+		return s.nodeID
+	}
+	return p.id
+}
+
+// removePeer 删除断开的连接
+func (s *P2PServer) removePeer(p *peer) {
+	s.mu.Lock()
+	if s.peers[p.id] == p {
+		delete(s.peers, p.id)
+	}
+	s.mu.Unlock()
+
+	p.close()
+}
+
+// handleMessage 处理对方发来的一条消息
+func (s *P2PServer) handleMessage(p *peer, msg *Message) {
+	switch msg.Type {
+	case MsgTransaction:
+		if msg.Transaction == nil {
+			return
+		}
+		if msg.Transaction.Type == TxTypeData && !p.allowDataTx(time.Now()) {
+			log.Printf("节点 %s 发送数据交易过于频繁，丢弃交易 %s", p.id, msg.Transaction.ID)
+			return
+		}
+		err := s.node.AddPeerTransaction(msg.Transaction)
+		if err != nil && !errors.Is(err, errKnownTransaction) {
+			log.Printf("拒绝来自节点 %s 的交易 %s: %v", p.id, msg.Transaction.ID, err)
+		}
+	case MsgBlock:
+		if msg.Block != nil {
+			s.addBlocks(p, []*Block{msg.Block})
+		}
+	case MsgGetBlocks:
+		p.queue(syncReply(s.node.GetBlocksAfter(msg.Locator, syncBatchSize)))
+	case MsgBlocks:
+		// 对方还有更多区块时，从这批的最后一个区块继续请求
+		// （这批区块可能只是分支，不能用本地主链作为起点）
+		if s.addBlocks(p, msg.Blocks) && msg.More && len(msg.Blocks) > 0 {
+			last := msg.Blocks[len(msg.Blocks)-1]
+			p.queue(&Message{Type: MsgGetBlocks, Locator: []string{last.Hash}})
+		}
+	default:
+		log.Printf("节点 %s 发来未知消息类型: %s", p.id, msg.Type)
+	}
+}
+
+// addBlocks 依次接受区块；缺少前序区块时向对方请求同步，返回是否全部接受
+func (s *P2PServer) addBlocks(p *peer, blocks []*Block) bool {
+	for _, block := range blocks {
+		err := s.node.AddPeerBlock(block)
+		switch {
+		case err == nil, errors.Is(err, errKnownBlock):
+		case errors.Is(err, errMissingParent):
+			s.requestBlocks(p)
+			return false
+		default:
+			log.Printf("拒绝来自节点 %s 的区块 %d: %v", p.id, block.Height, err)
+			return false
+		}
+	}
+	return true
+}
+
+// syncReply 构造同步回复，按编码大小截断区块，保证回复不超过对方的消息上限
+func syncReply(blocks []*Block) *Message {
+	more := len(blocks) == syncBatchSize
+	size := 0
+	for i, block := range blocks {
+		data, err := json.Marshal(block)
+		if err != nil {
+			return &Message{Type: MsgBlocks, Blocks: blocks[:i], More: i > 0}
+		}
+		size += len(data) + 1
+		if i > 0 && size > maxSyncBytes {
+			blocks, more = blocks[:i], true
+			break
+		}
+	}
+	return &Message{Type: MsgBlocks, Blocks: blocks, More: more}
+}
+
+// requestBlocks 向对方请求本地主链与对方主链共同区块之后的区块
+func (s *P2PServer) requestBlocks(p *peer) {
+	p.queue(&Message{Type: MsgGetBlocks, Locator: s.node.BlockLocator()})
+}
+
+// allowDataTx 判断是否还能接受对方在当前时间窗口内发来的数据交易
+func (p *peer) allowDataTx(now time.Time) bool {
+	if now.Sub(p.dataWindow) >= peerDataTxWindow {
+		p.dataWindow, p.dataCount = now, 0
+	}
+	if p.dataCount >= peerDataTxLimit {
+		return false
+	}
+	p.dataCount++
+	return true
+}
+
+// queue 把消息放入发送队列，队列已满时丢弃（丢失的区块可以在之后重新同步）
+func (p *peer) queue(msg *Message) {
+	select {
+	case p.send <- msg:
+	case <-p.done:
+	default:
+		log.Printf("节点 %s 的发送队列已满，丢弃 %s 消息", p.id, msg.Type)
+	}
+}
+
+// writeLoop 依次发送队列中的消息
+func (p *peer) writeLoop() {
+	for {
+		select {
+		case msg := <-p.send:
+			if err := writeMessage(p.conn, msg); err != nil {
+				p.close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// close 关闭连接，并通知发送协程退出
+func (p *peer) close() {
+	p.closeOnce.Do(func() {
+		p.conn.Close()
+		close(p.done)
+	})
+}
+
+// writeMessage 写入一行JSON消息
+func writeMessage(conn net.Conn, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+// errMessageTooLarge 表示对方发送的消息超过 maxMessageSize
+var errMessageTooLarge = errors.New("消息超过大小上限")
+
+// readMessage 读取一行JSON消息，超过 maxMessageSize 时返回 errMessageTooLarge
+func readMessage(reader *bufio.Reader) (*Message, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(line)+len(chunk) > maxMessageSize {
+			return nil, errMessageTooLarge
+		}
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+
+	var msg Message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, fmt.Errorf("消息格式无效: %v", err)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestPeer 为节点启动监听本机随机端口的网络层
+func startTestPeer(t *testing.T, node *Node, seeds ...string) *P2PServer {
+	t.Helper()
+
+	server, err := NewP2PServer(node, P2PConfig{ListenAddr: "127.0.0.1:0", Seeds: seeds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	return server
+}
+
+// waitFor 等待条件成立，超时则测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNodesSyncAndGossipOverTCP(t *testing.T) {
+	// a 已经出了几个区块；b 以 a 为种子，c 以 b 为种子
+	a := newTestChain(t, 4)
+	serverA := startTestPeer(t, a)
+
+	b, _ := NewNode(NodeConfig{BlockTime: 1})
+	serverB := startTestPeer(t, b, serverA.Addr())
+	c, _ := NewNode(NodeConfig{BlockTime: 1})
+	startTestPeer(t, c, serverB.Addr())
+
+	nodes := []*Node{a, b, c}
+	inSync := func() bool {
+		for _, node := range nodes[1:] {
+			if node.GetHeight() != a.GetHeight() || node.GetStateHash() != a.GetStateHash() {
+				return false
+			}
+		}
+		return true
+	}
+	waitFor(t, "启动时同步区块", inSync)
+
+	// a 的矿工给 c 的新钱包转账，区块经 b 转发到 c
+	carol, err := c.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Transfer(a.GetMinerAddress(), carol.Address, 200, 1); err != nil {
+		t.Fatal(err)
+	}
+	a.generateNewBlock()
+	waitFor(t, "区块广播到所有节点", inSync)
+	if got := c.GetBalance(carol.Address); got != 200 {
+		t.Fatalf("carol的余额应为200，实际为%d", got)
+	}
+
+	// c 的转账经 b 转发到出块节点 a
+
+	tx, err := c.Transfer(carol.Address, a.GetMinerAddress(), 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "转账广播到出块节点", func() bool { return a.mempool.Contains(tx.ID) })
+	a.generateNewBlock()
+	waitFor(t, "转账区块广播到所有节点", inSync)
+
+	for _, node := range nodes {
+		if got := node.GetBalance(carol.Address); got != 98 {
+			t.Fatalf("carol的余额应为98，实际为%d", got)
+		}
+		if node.mempool.Contains(tx.ID) {
+			t.Fatal("已上链的交易应从所有节点的交易池中移除")
+		}
+	}
+}
+
+func TestReadMessageRejectsOversizedMessages(t *testing.T) {
+	small := `{"type":"tx"}` + "\n"
+	msg, err := readMessage(bufio.NewReader(strings.NewReader(small)))
+	if err != nil || msg.Type != MsgTransaction {
+		t.Fatalf("应能读取正常大小的消息: %v", err)
+	}
+
+	huge := `{"type":"tx","data":"` + strings.Repeat("x", maxMessageSize) + `"}` + "\n"
+	if _, err := readMessage(bufio.NewReader(strings.NewReader(huge))); !errors.Is(err, errMessageTooLarge) {
+		t.Fatalf("超过大小上限的消息应被拒绝，实际为: %v", err)
+	}
+}
+
+func TestPeerDataTransactionRateLimit(t *testing.T) {
+	p := &peer{}
+	now := time.Now()
+	for i := 0; i < peerDataTxLimit; i++ {
+		if !p.allowDataTx(now) {
+			t.Fatalf("第%d笔数据交易应被接受", i+1)
+		}
+	}
+	if p.allowDataTx(now) {
+		t.Fatal("超过速率限制的数据交易应被丢弃")
+	}
+	if !p.allowDataTx(now.Add(peerDataTxWindow)) {
+		t.Fatal("新的时间窗口内应重新接受数据交易")
+	}
+}
@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidatorSet 是按固定顺序排列的验证者公钥集合
+type ValidatorSet struct {
+	validators []string
+	index      map[string]int
+}
+
+// NewValidatorSet 创建验证者集合，公钥不能为空且不能重复
+func NewValidatorSet(pubKeys []string) (*ValidatorSet, error) {
+	if len(pubKeys) == 0 {
+		return nil, fmt.Errorf("验证者集合不能为空")
+	}
+
+	vs := &ValidatorSet{index: make(map[string]int, len(pubKeys))}
+	for _, key := range pubKeys {
+		if _, err := parsePublicKey(key); err != nil {
+			return nil, err
+		}
+		if _, exists := vs.index[key]; exists {
+			return nil, fmt.Errorf("验证者 %s 重复", key)
+		}
+		vs.index[key] = len(vs.validators)
+		vs.validators = append(vs.validators, key)
+	}
+	return vs, nil
+}
+
+// Size 返回验证者数量
+func (vs *ValidatorSet) Size() int {
+	return len(vs.validators)
+}
+
+// Validators 返回全部验证者公钥
+func (vs *ValidatorSet) Validators() []string {
+	result := make([]string, len(vs.validators))
+	copy(result, vs.validators)
+	return result
+}
+
+// Contains 判断公钥是否属于验证者集合
+func (vs *ValidatorSet) Contains(pubKey string) bool {
+	_, exists := vs.index[pubKey]
+	return exists
+}
+
+// Proposer 返回负责提议指定高度区块的验证者，按高度轮流担任
+func (vs *ValidatorSet) Proposer(height int) string {
+	return vs.validators[(height-1)%len(vs.validators)]
+}
+
+// PoAEngine 是权威证明引擎
+//
+// 只有验证者集合中的节点可以出块，每个高度的提议者按顺序轮流担任，
+// 区块必须由该高度的提议者签名。提议者离线时链会停在该高度，直到它重新上线。
+type PoAEngine struct {
+	validators *ValidatorSet
+	blockTime  time.Duration
+}
+
+// NewPoAEngine 创建权威证明引擎
+func NewPoAEngine(validators *ValidatorSet, blockTime time.Duration) *PoAEngine {
+	if blockTime <= 0 {
+		blockTime = 10 * time.Second
+	}
+	return &PoAEngine{validators: validators, blockTime: blockTime}
+}
+
+// Name 返回引擎名称
+func (e *PoAEngine) Name() string {
+	return "poa"
+}
+
+// Prepare 检查本节点是否是该高度的提议者
+func (e *PoAEngine) Prepare(block, parent *Block, lookup func(hash string) *Block) error {
+	if proposer := e.validators.Proposer(block.Height); block.Validator != proposer {
+		return fmt.Errorf("高度 %d 的提议者是 %s，不是本节点", block.Height, shortKey(proposer))
+	}
+	return nil
+}
+
+// Seal 直接计算区块哈希
+func (e *PoAEngine) Seal(block *Block, abort func() bool) bool {
+	block.Hash = calculateBlockHash(block)
+	return true
+}
+
+// VerifyBlock 区块必须由该高度的提议者签名（签名本身由区块校验检查），由创世文件生成的创世区块除外
+func (e *PoAEngine) VerifyBlock(block, parent *Block, lookup func(hash string) *Block) error {
+	if err := verifyNoProofOfWork(block); err != nil {
+		return err
+	}
+	if parent == nil && isDocumentGenesis(block) {
+		return nil
+	}
+	if proposer := e.validators.Proposer(block.Height); block.Validator != proposer {
+		return fmt.Errorf("区块应由提议者 %s 签名，实际签名者为 %s", shortKey(proposer), shortKey(block.Validator))
+	}
+	return nil
+}
+
+// BlockWeight 每个区块的权重相同
+func (e *PoAEngine) BlockWeight(block *Block) uint64 {
+	return 1
+}
+
+// Run 每隔固定时间检查一次，轮到本节点时出块
+func (e *PoAEngine) Run(n *Node, stop <-chan struct{}) {
+	ticker := time.NewTicker(e.blockTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if e.validators.Proposer(n.GetHeight()+1) == n.validator {
+				n.generateNewBlock()
+			}
+		}
+	}
+}
+
+// shortKey 返回公钥的前缀，用于日志和错误信息
+func shortKey(pubKey string) string {
+	if len(pubKey) > 16 {
+		return pubKey[:16] + "..."
+	}
+	return pubKey
+}
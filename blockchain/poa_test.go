@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// newPoANetwork 创建共享同一验证者集合的多个节点，节点i是验证者集合中的第i个
+func newPoANetwork(t *testing.T, size int) []*Node {
+	t.Helper()
+
+	keys := make([]string, size)
+	pubKeys := make([]string, size)
+	for i := range keys {
+		key, err := GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+		pubKeys[i], _ = PublicKeyFromPrivate(key)
+	}
+	validators, err := NewValidatorSet(pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := make([]*Node, size)
+	for i := range nodes {
+		node, err := NewNode(NodeConfig{
+			Engine:       NewPoAEngine(validators, time.Second),
+			ValidatorKey: keys[i],
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes[i] = node
+	}
+	return nodes
+}
+
+// shareTip 把node的最新区块发送给其他节点
+func shareTip(t *testing.T, nodes []*Node, from *Node) {
+	t.Helper()
+	tip := from.GetBlockByHeight(from.GetHeight())
+	for _, node := range nodes {
+		if node == from {
+			continue
+		}
+		if err := node.AddPeerBlock(tip); err != nil {
+			t.Fatalf("高度 %d 的区块被拒绝: %v", tip.Height, err)
+		}
+	}
+}
+
+func TestPoARoundRobinProposers(t *testing.T) {
+	nodes := newPoANetwork(t, 3)
+	if err := nodes[0].CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	shareTip(t, nodes, nodes[0])
+
+	for height := 2; height <= 7; height++ {
+		proposer := nodes[(height-1)%3]
+
+		// 不是提议者的节点不出块
+		for _, node := range nodes {
+			if node != proposer {
+				node.generateNewBlock()
+				if node.GetHeight() != height-1 {
+					t.Fatalf("高度 %d 的非提议者生成了区块", height)
+				}
+			}
+		}
+
+		proposer.generateNewBlock()
+		if proposer.GetHeight() != height {
+			t.Fatalf("提议者没有生成高度 %d 的区块", height)
+		}
+		shareTip(t, nodes, proposer)
+	}
+
+	stats := nodes[1].GetMiningStats()
+	counts := stats["blocks_by_validator"].(map[string]int)
+	for _, node := range nodes {
+		if counts[node.GetValidator()] != 2 {
+			t.Fatalf("每个验证者应出2个块，实际 %v", counts)
+		}
+	}
+	for _, node := range nodes {
+		if err := node.ValidateChain(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPoARejectsBlockFromWrongSigner(t *testing.T) {
+	nodes := newPoANetwork(t, 3)
+	if err := nodes[0].CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	shareTip(t, nodes, nodes[0])
+
+	// 高度2的提议者是节点1，让节点2以自己的名义重新签名这个区块
+	nodes[1].generateNewBlock()
+	forged := *nodes[1].GetBlockByHeight(2)
+	forged.Validator = nodes[2].validator
+	forged.Hash = calculateBlockHash(&forged)
+	signature, err := nodes[2].signBlock(&forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Signature = signature
+
+	if err := nodes[0].AddPeerBlock(&forged); err == nil {
+		t.Fatal("非提议者签名的区块应被拒绝")
+	}
+
+	// 验证者集合之外的节点同样不能出块
+	outsider, err := NewNode(NodeConfig{Engine: nodes[0].engine})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Validator = outsider.validator
+	forged.Hash = calculateBlockHash(&forged)
+	if forged.Signature, err = outsider.signBlock(&forged); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].AddPeerBlock(&forged); err == nil {
+		t.Fatal("验证者集合之外签名的区块应被拒绝")
+	}
+
+	if err := nodes[0].AddPeerBlock(nodes[1].GetBlockByHeight(2)); err != nil {
+		t.Fatalf("提议者签名的区块应被接受: %v", err)
+	}
+}
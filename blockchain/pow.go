@@ -0,0 +1,188 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/bits"
+	"time"
+)
+
+// 工作量证明参数
+const (
+	defaultPoWDifficulty = 18 // 默认初始难度（哈希前导零比特数）
+	maxPoWDifficulty     = 62 // 最大难度，保证区块权重不溢出
+	retargetInterval     = 10 // 每隔多少个区块调整一次难度
+)
+
+// PoWEngine 是工作量证明引擎
+//
+// 区块哈希的前导零比特数必须不少于区块的难度。每隔 retargetInterval 个区块，
+// 根据这段时间的实际出块时间调整难度：明显快于目标时提高1，明显慢于目标时降低1。
+// 分叉选择时比较累计工作量，每个区块的工作量为 2^难度。
+type PoWEngine struct {
+	initialDifficulty uint32
+	targetBlockTime   time.Duration
+}
+
+// NewPoWEngine 创建工作量证明引擎
+func NewPoWEngine(difficulty uint32, targetBlockTime time.Duration) *PoWEngine {
+	if difficulty == 0 {
+		difficulty = defaultPoWDifficulty
+	}
+	if difficulty > maxPoWDifficulty {
+		difficulty = maxPoWDifficulty
+	}
+	if targetBlockTime <= 0 {
+		targetBlockTime = 10 * time.Second
+	}
+	return &PoWEngine{
+		initialDifficulty: difficulty,
+		targetBlockTime:   targetBlockTime,
+	}
+}
+
+// Name 返回引擎名称
+func (e *PoWEngine) Name() string {
+	return "pow"
+}
+
+// Prepare 填写区块应满足的难度
+func (e *PoWEngine) Prepare(block, parent *Block, lookup func(hash string) *Block) error {
+	difficulty, err := e.expectedDifficulty(parent, lookup)
+	if err != nil {
+		return err
+	}
+	block.Difficulty = difficulty
+	return nil
+}
+
+// expectedDifficulty 计算接在parent之后的区块应满足的难度
+//
+// 创世区块不需要工作量证明；紧接创世区块的区块使用初始难度。
+func (e *PoWEngine) expectedDifficulty(parent *Block, lookup func(hash string) *Block) (uint32, error) {
+	if parent == nil {
+		return 0, nil
+	}
+	if parent.Difficulty == 0 {
+		return e.initialDifficulty, nil
+	}
+	if parent.Height%retargetInterval != 0 || parent.Height <= retargetInterval {
+		return parent.Difficulty, nil
+	}
+
+	// 找到 retargetInterval 个区块之前的祖先，比较实际用时与目标用时
+	first := parent
+	for i := 0; i < retargetInterval; i++ {
+		if first = lookup(first.PrevHash); first == nil {
+			return 0, fmt.Errorf("缺少计算难度所需的祖先区块")
+		}
+	}
+	actual := parent.Timestamp.Sub(first.Timestamp)
+	expected := e.targetBlockTime * retargetInterval
+
+	difficulty := parent.Difficulty
+	switch {
+	case actual < expected/2 && difficulty < maxPoWDifficulty:
+		difficulty++
+	case actual > expected*2 && difficulty > 1:
+		difficulty--
+	}
+	return difficulty, nil
+}
+
+// Seal 不断尝试随机数，直到区块哈希满足难度
+func (e *PoWEngine) Seal(block *Block, abort func() bool) bool {
+	for nonce := uint64(0); ; nonce++ {
+		// 每尝试一批随机数检查一次是否需要放弃
+		if nonce%4096 == 0 && abort() {
+			return false
+		}
+		block.Nonce = nonce
+		block.Hash = calculateBlockHash(block)
+		if meetsDifficulty(block.Hash, block.Difficulty) {
+			return true
+		}
+	}
+}
+
+// VerifyBlock 检查区块的难度是否正确，以及哈希是否满足难度
+func (e *PoWEngine) VerifyBlock(block, parent *Block, lookup func(hash string) *Block) error {
+	expected, err := e.expectedDifficulty(parent, lookup)
+	if err != nil {
+		return err
+	}
+	if block.Difficulty != expected {
+		return fmt.Errorf("难度应为 %d，实际为 %d", expected, block.Difficulty)
+	}
+	if !meetsDifficulty(block.Hash, block.Difficulty) {
+		return fmt.Errorf("区块哈希不满足难度 %d", block.Difficulty)
+	}
+	return nil
+}
+
+// BlockWeight 区块的工作量为 2^难度
+func (e *PoWEngine) BlockWeight(block *Block) uint64 {
+	return 1 << block.Difficulty
+}
+
+// Run 持续挖矿：在锁外寻找随机数，链尾变化时放弃当前区块重新开始
+func (e *PoWEngine) Run(n *Node, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		block, failed := n.proposeBlock()
+		if block == nil {
+			// 还没有创世区块
+			if !idleWait(stop, time.Second) {
+				return
+			}
+			continue
+		}
+
+		abort := func() bool {
+			select {
+			case <-stop:
+				return true
+			default:
+				return n.tipHash() != block.PrevHash
+			}
+		}
+		if !e.Seal(block, abort) {
+			continue
+		}
+		if err := n.submitBlock(block, failed); err != nil {
+			log.Printf("提交区块 %d 失败: %v", block.Height, err)
+		}
+	}
+}
+
+// meetsDifficulty 判断十六进制哈希的前导零比特数是否不少于difficulty
+func meetsDifficulty(hash string, difficulty uint32) bool {
+	data, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+
+	zeros := uint32(0)
+	for _, b := range data {
+		if b != 0 {
+			zeros += uint32(bits.LeadingZeros8(b))
+			break
+		}
+		zeros += 8
+	}
+	return zeros >= difficulty
+}
+
+// verifyNoProofOfWork 不使用工作量证明的引擎要求区块没有难度和随机数
+func verifyNoProofOfWork(block *Block) error {
+	if block.Difficulty != 0 || block.Nonce != 0 {
+		return fmt.Errorf("区块不应包含工作量证明字段")
+	}
+	return nil
+}
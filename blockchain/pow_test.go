@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPoWEngineMinesAndVerifiesBlocks(t *testing.T) {
+	node, err := NewNode(NodeConfig{Engine: NewPoWEngine(8, time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+
+	node.StartMining()
+	waitFor(t, "挖出3个区块", func() bool { return node.GetHeight() >= 4 })
+	node.StopMining()
+
+	for _, block := range node.GetAllBlocks()[1:] {
+		if block.Difficulty < 8 || !meetsDifficulty(block.Hash, block.Difficulty) {
+			t.Fatalf("区块 %d 不满足难度", block.Height)
+		}
+	}
+	if err := node.ValidateChain(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 按单节点规则运行的节点不接受工作量证明区块
+	file := filepath.Join(t.TempDir(), "chain.json")
+	if err := node.ExportBlockchain(file); err != nil {
+		t.Fatal(err)
+	}
+	solo, _ := NewNode(NodeConfig{BlockTime: 1})
+	if err := solo.ImportBlockchain(file); err == nil {
+		t.Fatal("单节点引擎应拒绝工作量证明区块")
+	}
+
+	// 修改随机数后哈希不再满足难度
+	block := *node.GetBlockByHeight(2)
+	for meetsDifficulty(block.Hash, block.Difficulty) {
+		block.Nonce++
+		block.Hash = calculateBlockHash(&block)
+	}
+	noAncestors := func(string) *Block { return nil }
+	if err := node.engine.VerifyBlock(&block, node.GetBlockByHeight(1), noAncestors); err == nil {
+		t.Fatal("哈希不满足难度的区块应被拒绝")
+	}
+}
+
+func TestPoWDifficultyRetargets(t *testing.T) {
+	engine := NewPoWEngine(10, 10*time.Second)
+
+	// 构造 retargetInterval*2 个区块，gap 为相邻区块的时间间隔
+	buildChain := func(gap time.Duration) (*Block, func(string) *Block) {
+		byHash := make(map[string]*Block)
+		start := time.Now()
+		var parent *Block
+		for h := 1; h <= retargetInterval*2; h++ {
+			block := &Block{Height: h, Timestamp: start.Add(time.Duration(h) * gap), Difficulty: 10}
+			if h == 1 {
+				block.Difficulty = 0
+			} else {
+				block.PrevHash = parent.Hash
+			}
+			block.Hash = calculateBlockHash(block)
+			byHash[block.Hash] = block
+			parent = block
+		}
+		return parent, func(hash string) *Block { return byHash[hash] }
+	}
+
+	cases := []struct {
+		gap  time.Duration
+		want uint32
+	}{
+		{time.Second, 11},      // 出块太快，提高难度
+		{10 * time.Second, 10}, // 接近目标，保持不变
+		{time.Minute, 9},       // 出块太慢，降低难度
+	}
+	for _, c := range cases {
+		parent, lookup := buildChain(c.gap)
+		got, err := engine.expectedDifficulty(parent, lookup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Fatalf("出块间隔 %v 时难度应为%d，实际为%d", c.gap, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 分页查询的默认和最大条数
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// 查询结果的排序方向
+const (
+	OrderAsc  = "asc"  // 从旧到新
+	OrderDesc = "desc" // 从新到旧
+)
+
+// txHistory 主链上涉及余额变动的交易，按上链顺序排列，并建立按区块和地址的索引
+//
+// 历史只在链尾追加，索引中的位置在追加新区块后保持不变；链重组时整个历史重新生成。
+type txHistory struct {
+	txs        []*Transaction
+	blockStart []int            // 高度为h的区块的第一笔交易在txs中的位置是 blockStart[h-1]
+	byAddress  map[string][]int // 地址到发送或接收的交易在txs中的位置，升序
+}
+
+func newTxHistory() *txHistory {
+	return &txHistory{
+		txs:       make([]*Transaction, 0),
+		byAddress: make(map[string][]int),
+	}
+}
+
+// append 把主链末尾的区块中的交易加入历史，数据交易不涉及余额，不计入历史
+func (h *txHistory) append(block *Block) {
+	h.blockStart = append(h.blockStart, len(h.txs))
+	for _, tx := range block.Transactions {
+		if tx.Type == TxTypeData {
+			continue
+		}
+		pos := len(h.txs)
+		h.txs = append(h.txs, tx)
+		h.byAddress[tx.From] = append(h.byAddress[tx.From], pos)
+		if tx.To != "" && tx.To != tx.From {
+			h.byAddress[tx.To] = append(h.byAddress[tx.To], pos)
+		}
+	}
+}
+
+// start 返回主链下标为i的区块的第一笔交易在历史中的位置，i等于区块数时返回历史长度
+func (h *txHistory) start(i int) int {
+	if i < len(h.blockStart) {
+		return h.blockStart[i]
+	}
+	return len(h.txs)
+}
+
+// PageQuery 分页查询区块或交易的条件，为零值的条件不过滤
+//
+// 高度和时间范围都是闭区间，时间指区块时间（交易按所在区块的时间过滤）。
+// Offset和Cursor二选一：Cursor是上一页返回的NextCursor，新区块上链后仍然有效。
+type PageQuery struct {
+	FromHeight int
+	ToHeight   int
+	Since      time.Time
+	Until      time.Time
+	Address    string // 只用于交易查询：发送方或接收方是该地址
+	Order      string // asc（默认）或desc
+	Offset     int
+	Cursor     string
+	Limit      int // 默认20，最大100
+}
+
+// BlockPage 一页区块
+type BlockPage struct {
+	Blocks     []*Block `json:"blocks"`
+	Total      int      `json:"total"`                 // 满足过滤条件的区块总数
+	NextCursor string   `json:"next_cursor,omitempty"` // 还有下一页时返回
+}
+
+// TransactionPage 一页交易
+type TransactionPage struct {
+	Transactions []*Transaction `json:"transactions"`
+	Total        int            `json:"total"`                 // 满足过滤条件的交易总数
+	NextCursor   string         `json:"next_cursor,omitempty"` // 还有下一页时返回
+}
+
+// normalize 检查查询条件并填入默认值
+func (q *PageQuery) normalize() error {
+	switch q.Order {
+	case "":
+		q.Order = OrderAsc
+	case OrderAsc, OrderDesc:
+	default:
+		return fmt.Errorf("order必须是%s或%s", OrderAsc, OrderDesc)
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultPageLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxPageLimit {
+		return fmt.Errorf("limit必须在1到%d之间", MaxPageLimit)
+	}
+	if q.Offset < 0 || q.FromHeight < 0 || q.ToHeight < 0 {
+		return fmt.Errorf("offset和高度不能为负数")
+	}
+	if q.Offset > 0 && q.Cursor != "" {
+		return fmt.Errorf("offset和cursor不能同时使用")
+	}
+	if q.Address != "" {
+		return ValidateAddress(q.Address)
+	}
+	return nil
+}
+
+// QueryBlocks 按高度和时间范围分页查询主链上的区块
+func (n *Node) QueryBlocks(q PageQuery) (*BlockPage, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	if q.Address != "" {
+		return nil, fmt.Errorf("区块查询不支持按地址过滤")
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	lo, hi := n.blockRange(&q)
+	selected, next, err := paginate(nil, lo, hi, &q)
+	if err != nil {
+		return nil, err
+	}
+	page := &BlockPage{Blocks: make([]*Block, 0, len(selected)), Total: hi - lo, NextCursor: next}
+	for _, i := range selected {
+		page.Blocks = append(page.Blocks, n.chain[i])
+	}
+	return page, nil
+}
+
+// QueryTransactions 按地址、所在区块的高度和时间范围分页查询交易历史
+func (n *Node) QueryTransactions(q PageQuery) (*TransactionPage, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	first, last := n.blockRange(&q)
+	lo, hi := n.transactions.start(first), n.transactions.start(last)
+	var positions []int
+	if q.Address != "" {
+		positions = n.transactions.byAddress[q.Address]
+		lo, hi = sort.SearchInts(positions, lo), sort.SearchInts(positions, hi)
+	}
+
+	selected, next, err := paginate(positions, lo, hi, &q)
+	if err != nil {
+		return nil, err
+	}
+	page := &TransactionPage{Transactions: make([]*Transaction, 0, len(selected)), Total: hi - lo, NextCursor: next}
+	for _, pos := range selected {
+		page.Transactions = append(page.Transactions, n.transactions.txs[pos])
+	}
+	return page, nil
+}
+
+// blockRange 返回满足高度和时间范围的区块在主链中的下标区间[lo, hi)，调用者需持有读锁
+//
+// 主链第i个区块的高度是i+1，区块时间不早于前一区块，所以都可以直接定位或二分查找。
+func (n *Node) blockRange(q *PageQuery) (int, int) {
+	lo, hi := 0, len(n.chain)
+	if q.FromHeight > 1 {
+		lo = q.FromHeight - 1
+	}
+	if q.ToHeight > 0 && q.ToHeight < hi {
+		hi = q.ToHeight
+	}
+	if !q.Since.IsZero() {
+		if i := sort.Search(len(n.chain), func(i int) bool { return !n.chain[i].Timestamp.Before(q.Since) }); i > lo {
+			lo = i
+		}
+	}
+	if !q.Until.IsZero() {
+		if i := sort.Search(len(n.chain), func(i int) bool { return n.chain[i].Timestamp.After(q.Until) }); i < hi {
+			hi = i
+		}
+	}
+	if lo > hi {
+		lo = hi
+	}
+	return lo, hi
+}
+
+// paginate 从升序的位置列表 positions[lo:hi] 中按排序方向取出一页，返回选中的位置和下一页的游标
+//
+// positions为nil时位置就是下标本身。游标是上一页最后一条的位置，用二分查找定位下一页的起点。
+func paginate(positions []int, lo, hi int, q *PageQuery) ([]int, string, error) {
+	pos := func(i int) int {
+		if positions == nil {
+			return i
+		}
+		return positions[i]
+	}
+
+	total := hi - lo
+	skip := q.Offset
+	if q.Cursor != "" {
+		cursor, err := strconv.Atoi(q.Cursor)
+		if err != nil || cursor < 0 {
+			return nil, "", fmt.Errorf("cursor无效: %s", q.Cursor)
+		}
+		if q.Order == OrderAsc {
+			skip = sort.Search(total, func(j int) bool { return pos(lo+j) > cursor })
+		} else {
+			skip = total - sort.Search(total, func(j int) bool { return pos(lo+j) >= cursor })
+		}
+	}
+
+	selected := make([]int, 0, q.Limit)
+	for k := skip; k < total && len(selected) < q.Limit; k++ {
+		i := lo + k
+		if q.Order == OrderDesc {
+			i = hi - 1 - k
+		}
+		selected = append(selected, pos(i))
+	}
+
+	next := ""
+	if len(selected) > 0 && skip+len(selected) < total {
+		next = strconv.Itoa(selected[len(selected)-1])
+	}
+	return selected, next, nil
+}
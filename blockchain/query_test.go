@@ -0,0 +1,196 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// newQueryNode 创建一个有若干区块的节点：第2到5个区块中alice分别转账给bob、carol、bob、carol
+func newQueryNode(t *testing.T) (*Node, *Wallet) {
+	t.Helper()
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+	for i, to := range []string{"bob", "carol", "bob", "carol"} {
+		if _, err := node.Transfer(alice.Address, testAddress(to), int64(10*(i+1)), 3); err != nil {
+			t.Fatal(err)
+		}
+		node.generateNewBlock()
+	}
+	return node, alice
+}
+
+// collectTransactions 沿着游标取出所有页
+func collectTransactions(t *testing.T, node *Node, q PageQuery) ([]*Transaction, int) {
+	t.Helper()
+	var result []*Transaction
+	total := -1
+	for pages := 0; ; pages++ {
+		page, err := node.QueryTransactions(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total >= 0 && page.Total != total {
+			t.Fatalf("翻页过程中总数变化: %d -> %d", total, page.Total)
+		}
+		total = page.Total
+		result = append(result, page.Transactions...)
+		if page.NextCursor == "" || pages > 100 {
+			return result, total
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func TestQueryTransactions(t *testing.T) {
+	node, alice := newQueryNode(t)
+	bob := testAddress("bob")
+	history := node.GetTransactions()
+
+	// 按地址和高度过滤的结果应与逐笔扫描的结果一致
+	heightOf := func(tx *Transaction) int {
+		receipt, err := node.GetReceipt(tx.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipt.Height
+	}
+	queries := []PageQuery{
+		{Limit: 2},
+		{Address: bob, Limit: 1},
+		{Address: alice.Address, FromHeight: 3, ToHeight: 4, Limit: 1},
+		{Address: bob, Order: OrderDesc, Limit: 1},
+		{FromHeight: 2, ToHeight: 3, Order: OrderDesc, Limit: 3},
+		{FromHeight: 10},
+	}
+	for _, q := range queries {
+		var want []*Transaction
+		for _, tx := range history {
+			h := heightOf(tx)
+			if q.Address != "" && tx.From != q.Address && tx.To != q.Address {
+				continue
+			}
+			if (q.FromHeight > 0 && h < q.FromHeight) || (q.ToHeight > 0 && h > q.ToHeight) {
+				continue
+			}
+			want = append(want, tx)
+		}
+		if q.Order == OrderDesc {
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+
+		got, total := collectTransactions(t, node, q)
+		if total != len(want) || len(got) != len(want) {
+			t.Fatalf("查询 %+v 返回 %d 笔（总数 %d），期望 %d 笔", q, len(got), total, len(want))
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID {
+				t.Fatalf("查询 %+v 的第%d笔交易不正确", q, i)
+			}
+		}
+	}
+
+	if got, _ := collectTransactions(t, node, PageQuery{Address: bob}); len(got) != 2 || got[0].Amount != 10 || got[1].Amount != 30 {
+		t.Fatalf("bob的交易 = %v", got)
+	}
+
+	// 游标在新区块上链后仍然有效
+	first, err := node.QueryTransactions(PageQuery{Address: bob, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Transfer(alice.Address, bob, 50, 3); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+	rest, err := node.QueryTransactions(PageQuery{Address: bob, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest.Total != 3 || len(rest.Transactions) != 2 || rest.Transactions[1].Amount != 50 || rest.NextCursor != "" {
+		t.Fatalf("新区块上链后的下一页 = %+v", rest)
+	}
+
+	// 偏移分页
+	page, err := node.QueryTransactions(PageQuery{Address: bob, Offset: 1, Limit: 1, Order: OrderDesc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Transactions) != 1 || page.Transactions[0].Amount != 30 || page.NextCursor == "" {
+		t.Fatalf("偏移分页 = %+v", page)
+	}
+
+	invalid := []PageQuery{
+		{Order: "random"},
+		{Limit: MaxPageLimit + 1},
+		{Offset: -1},
+		{Offset: 1, Cursor: "3"},
+		{Cursor: "abc"},
+		{Address: "cosmos-bob"},
+	}
+	for _, q := range invalid {
+		if _, err := node.QueryTransactions(q); err == nil {
+			t.Fatalf("查询条件 %+v 应被拒绝", q)
+		}
+	}
+}
+
+func TestQueryBlocks(t *testing.T) {
+	node, _ := newQueryNode(t)
+
+	page, err := node.QueryBlocks(PageQuery{Order: OrderDesc, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 5 || len(page.Blocks) != 2 || page.Blocks[0].Height != 5 || page.Blocks[1].Height != 4 {
+		t.Fatalf("最新的两个区块 = %+v", page)
+	}
+	page, err = node.QueryBlocks(PageQuery{Order: OrderDesc, Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Blocks) != 2 || page.Blocks[0].Height != 3 || page.Blocks[1].Height != 2 || page.NextCursor == "" {
+		t.Fatalf("第二页区块 = %+v", page)
+	}
+
+	page, err = node.QueryBlocks(PageQuery{FromHeight: 2, ToHeight: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Blocks[0].Height != 2 || page.Blocks[2].Height != 4 || page.NextCursor != "" {
+		t.Fatalf("高度2到4的区块 = %+v", page)
+	}
+
+	// 时间范围是闭区间
+	third := node.GetBlockByHeight(3)
+	page, err = node.QueryBlocks(PageQuery{Since: third.Timestamp, Until: third.Timestamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range page.Blocks {
+		if !block.Timestamp.Equal(third.Timestamp) {
+			t.Fatalf("时间范围外的区块 %d", block.Height)
+		}
+	}
+	if page.Total == 0 {
+		t.Fatal("时间范围应包括边界上的区块")
+	}
+	page, err = node.QueryBlocks(PageQuery{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 || len(page.Blocks) != 0 {
+		t.Fatalf("未来时间之后的区块 = %+v", page)
+	}
+
+	if node.GetBlockByHeight(0) != nil || node.GetBlockByHeight(6) != nil || node.GetBlockByHeight(5).Height != 5 {
+		t.Fatal("按高度获取区块不正确")
+	}
+	if _, err := node.QueryBlocks(PageQuery{Address: testAddress("bob")}); err == nil {
+		t.Fatal("区块查询不支持按地址过滤")
+	}
+}
@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+)
+
+// 交易的生命周期状态
+const (
+	TxStatusPending  = "pending"  // 在交易池中等待打包
+	TxStatusIncluded = "included" // 已打包进主链上的区块
+	TxStatusFailed   = "failed"   // 打包时验证失败，或被交易池淘汰、替换
+)
+
+// Receipt 交易的回执：当前状态，以及上链位置或失败原因
+type Receipt struct {
+	TxID          string       `json:"tx_id"`
+	Status        string       `json:"status"`
+	Height        int          `json:"height,omitempty"`     // 所在区块的高度；失败时是尝试打包的高度
+	Index         int          `json:"index"`                // 在区块交易列表中的位置
+	BlockHash     string       `json:"block_hash,omitempty"` // 所在区块的哈希
+	Confirmations int          `json:"confirmations"`        // 所在区块及之后的区块数量
+	FeePaid       Coin         `json:"fee_paid"`             // 实际支付的手续费，只有上链的用户交易才有
+	Reason        string       `json:"reason,omitempty"`     // 失败原因
+	Transaction   *Transaction `json:"transaction"`
+}
+
+// Final 判断回执的状态是否不会再变化（链重组除外）
+func (r *Receipt) Final() bool {
+	return r.Status != TxStatusPending
+}
+
+// GetReceipt 获取交易的回执，未知的交易返回错误
+func (n *Node) GetReceipt(txID string) (*Receipt, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if receipt := n.receipt(txID); receipt != nil {
+		return receipt, nil
+	}
+	return nil, fmt.Errorf("交易 %s 不存在", txID)
+}
+
+// WaitForTransaction 等待交易上链或失败，返回最终的回执
+//
+// ctx结束时返回当时的回执（仍在等待打包）和ctx的错误；未知的交易立即返回错误。
+func (n *Node) WaitForTransaction(ctx context.Context, txID string) (*Receipt, error) {
+	for {
+		n.mu.RLock()
+		receipt := n.receipt(txID)
+		updates := n.txUpdates
+		n.mu.RUnlock()
+
+		if receipt == nil {
+			return nil, fmt.Errorf("交易 %s 不存在", txID)
+		}
+		if receipt.Final() {
+			return receipt, nil
+		}
+		select {
+		case <-updates:
+		case <-ctx.Done():
+			return receipt, ctx.Err()
+		}
+	}
+}
+
+// receipt 查找交易的回执，调用者需持有读锁
+//
+// 已上链的状态优先：失败后重新提交并上链的交易返回上链的回执。
+func (n *Node) receipt(txID string) *Receipt {
+	if height, included := n.includedTxs[txID]; included {
+		block := n.blockAt(height)
+		for i := 0; block != nil && i < len(block.Transactions); i++ {
+			tx := block.Transactions[i]
+			if tx.ID != txID {
+				continue
+			}
+			receipt := &Receipt{
+				TxID:          txID,
+				Status:        TxStatusIncluded,
+				Height:        height,
+				Index:         i,
+				BlockHash:     block.Hash,
+				Confirmations: n.tip().Height - height + 1,
+				Transaction:   tx,
+			}
+			if isSequenced(tx) {
+				receipt.FeePaid = Coin{Denom: denomOf(tx.FeeDenom), Amount: tx.Fee}
+			}
+			return receipt
+		}
+	}
+
+	if entry := n.mempool.Get(txID); entry != nil {
+		return &Receipt{TxID: txID, Status: TxStatusPending, Transaction: entry.Transaction}
+	}
+
+	for i := len(n.failedTransactions) - 1; i >= 0; i-- {
+		if f := n.failedTransactions[i]; f.Transaction.ID == txID {
+			return &Receipt{
+				TxID:        txID,
+				Status:      TxStatusFailed,
+				Height:      f.Height,
+				Reason:      f.Reason,
+				Transaction: f.Transaction,
+			}
+		}
+	}
+	return nil
+}
+
+// blockAt 返回主链上指定高度的区块，调用者需持有读锁
+//
+// 主链的高度从1开始连续，第i个区块的高度是i+1。
+func (n *Node) blockAt(height int) *Block {
+	if height >= 1 && height <= len(n.chain) {
+		return n.chain[height-1]
+	}
+	return nil
+}
+
+// notifyTxUpdates 唤醒等待交易状态变化的调用者，调用者需持有写锁
+func (n *Node) notifyTxUpdates() {
+	close(n.txUpdates)
+	n.txUpdates = make(chan struct{})
+}
@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReceiptLifecycle(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	tx, err := node.Transfer(alice.Address, testAddress("bob"), 100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := node.GetReceipt(tx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != TxStatusPending || receipt.Final() {
+		t.Fatalf("进入交易池后的回执 = %+v", receipt)
+	}
+
+	node.generateNewBlock()
+	node.generateNewBlock()
+	receipt, err = node.GetReceipt(tx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := node.GetBlockByHeight(2)
+	if receipt.Status != TxStatusIncluded || receipt.Height != 2 || receipt.BlockHash != block.Hash || receipt.Confirmations != 2 {
+		t.Fatalf("上链后的回执 = %+v", receipt)
+	}
+	if block.Transactions[receipt.Index].ID != tx.ID || receipt.FeePaid.Amount != 3 || receipt.FeePaid.Denom != BaseDenom {
+		t.Fatalf("回执中的位置或手续费不正确: %+v", receipt)
+	}
+
+	// 挖矿奖励等系统交易不支付手续费
+	reward, err := node.GetReceipt(block.Transactions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reward.Status != TxStatusIncluded || reward.Index != 0 || reward.FeePaid.Amount != 0 {
+		t.Fatalf("挖矿奖励的回执 = %+v", reward)
+	}
+
+	if _, err := node.GetReceipt("unknown"); err == nil {
+		t.Fatal("未知的交易应返回错误")
+	}
+}
+
+func TestReceiptForReplacedTransaction(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	tx, err := node.Transfer(alice.Address, testAddress("bob"), 100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := node.ReplaceTransaction(tx.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := node.GetReceipt(tx.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != TxStatusFailed || receipt.Reason == "" {
+		t.Fatalf("被替换的交易的回执 = %+v", receipt)
+	}
+	if receipt, _ := node.GetReceipt(replaced.ID); receipt.Status != TxStatusPending {
+		t.Fatalf("替换后的交易的回执 = %+v", receipt)
+	}
+}
+
+func TestWaitForTransaction(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+
+	tx, err := node.Transfer(alice.Address, testAddress("bob"), 100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超时后返回仍在等待打包的回执
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	receipt, err := node.WaitForTransaction(ctx, tx.ID)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) || receipt == nil || receipt.Status != TxStatusPending {
+		t.Fatalf("超时后的回执 = %+v, %v", receipt, err)
+	}
+
+	done := make(chan *Receipt, 1)
+	go func() {
+		receipt, err := node.WaitForTransaction(context.Background(), tx.ID)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- receipt
+	}()
+	time.Sleep(10 * time.Millisecond)
+	node.generateNewBlock()
+
+	select {
+	case receipt := <-done:
+		if receipt == nil || receipt.Status != TxStatusIncluded || receipt.Height != 2 {
+			t.Fatalf("等待到的回执 = %+v", receipt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("交易上链后等待没有返回")
+	}
+
+	if _, err := node.WaitForTransaction(context.Background(), "unknown"); err == nil {
+		t.Fatal("等待未知的交易应立即返回错误")
+	}
+}
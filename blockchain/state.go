@@ -0,0 +1,367 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// FailedTransaction 表示打包区块时未通过验证而被丢弃的交易
+type FailedTransaction struct {
+	Transaction *Transaction `json:"transaction"`
+	Height      int          `json:"height"` // 尝试打包时的区块高度
+	Reason      string       `json:"reason"`
+}
+
+// stateOverlay 是叠加在钱包账户之上的临时状态
+//
+// 打包和提交区块时先在临时状态上依次执行交易，全部成功后再一次性写回，
+// 保证区块中的交易要么全部生效，要么全部不生效。
+type stateOverlay struct {
+	wm        *WalletManager
+	accounts  map[string]accountState
+	denoms    map[string]string // 新创建的代币及其创建者
+	minted    Coins             // 新发行的数量
+	fees      Coins             // 转账支付的手续费
+	feesPaid  Coins             // 支付给出块者的手续费
+	supplyCap int64             // 基础代币的总发行量上限，0表示不限
+	height    int               // 正在执行的区块高度，用于判断转账的解锁条件
+	timestamp int64             // 正在执行的区块时间
+	unsigned  bool              // 模拟执行未签名的交易，跳过签名检查
+}
+
+// newStateOverlay 基于钱包管理器当前的账户创建临时状态
+func (wm *WalletManager) newStateOverlay() *stateOverlay {
+	o := &stateOverlay{wm: wm}
+	o.reset()
+	return o
+}
+
+// reset 清空临时状态中的修改
+func (o *stateOverlay) reset() {
+	o.accounts = make(map[string]accountState)
+	o.denoms = make(map[string]string)
+	o.minted, o.fees, o.feesPaid = make(Coins), make(Coins), make(Coins)
+}
+
+// creatorOf 返回临时状态中代币的创建者
+func (o *stateOverlay) creatorOf(denom string) string {
+	if creator, ok := o.denoms[denom]; ok {
+		return creator
+	}
+	return o.wm.denomCreator(denom)
+}
+
+// accountOf 返回临时状态中的账户以及账户是否存在
+func (o *stateOverlay) accountOf(address string) (accountState, bool) {
+	if account, ok := o.accounts[address]; ok {
+		return account, true
+	}
+	return o.wm.account(address)
+}
+
+// apply 验证交易并在临时状态上执行，失败时临时状态保持不变
+//
+// 系统交易中，挖矿奖励和创世分配是新发行的基础代币，不能超过供应上限；
+// 手续费支付交易只是把本区块收取的手续费转给出块者。
+// 发行交易由代币的创建者发起，第一次发行某个代币名称的账户成为它的创建者。
+func (o *stateOverlay) apply(tx *Transaction) error {
+	if tx.From != "system" {
+		if err := o.wm.checkTransaction(tx, o.accountOf, !o.unsigned); err != nil {
+			return err
+		}
+	}
+	if tx.Type == TxTypeData {
+		return nil
+	}
+	recipient, _ := o.accountOf(tx.To)
+	if _, err := addAmounts(recipient.amountOf(tx.Denom), tx.Amount); err != nil {
+		return fmt.Errorf("接收方余额溢出: %v", err)
+	}
+
+	switch {
+	case tx.Type == TxTypeFee:
+		if o.feesPaid.AmountOf(tx.Denom)+tx.Amount > o.fees.AmountOf(tx.Denom) {
+			return fmt.Errorf("支付的手续费超过了收取的手续费")
+		}
+		o.feesPaid.add(tx.Denom, tx.Amount)
+	case tx.From == "system":
+		if denomOf(tx.Denom) != BaseDenom {
+			return fmt.Errorf("系统只能发行基础代币 %s", BaseDenom)
+		}
+		if minted := o.wm.totalMinted() + o.minted[BaseDenom] + tx.Amount; o.supplyCap > 0 && minted > o.supplyCap {
+			return fmt.Errorf("发行 %d 后总量将超过供应上限 %d", tx.Amount, o.supplyCap)
+		}
+		o.minted.add(BaseDenom, tx.Amount)
+	default:
+		sender, _ := o.accountOf(tx.From)
+		if tx.Type != TxTypeIssue {
+			sender.add(tx.Denom, -tx.Amount)
+		}
+		sender.add(tx.FeeDenom, -tx.Fee)
+		if sender.amountOf(tx.Denom) < 0 || sender.amountOf(tx.FeeDenom) < 0 {
+			return fmt.Errorf("执行后发送方余额为负")
+		}
+		if tx.Type == TxTypeIssue {
+			creator := o.creatorOf(tx.Denom)
+			if creator != "" && creator != tx.From {
+				return fmt.Errorf("代币 %s 只能由创建者 %s 发行", tx.Denom, creator)
+			}
+			if _, err := addAmounts(o.wm.mintedOf(tx.Denom)+o.minted.AmountOf(tx.Denom), tx.Amount); err != nil {
+				return fmt.Errorf("代币 %s 的发行总量超出范围", tx.Denom)
+			}
+			o.denoms[tx.Denom] = tx.From
+			o.minted.add(tx.Denom, tx.Amount)
+		}
+		sender.Nonce++
+		o.accounts[tx.From] = sender
+		o.fees.add(tx.FeeDenom, tx.Fee)
+	}
+
+	// 发送方和接收方可能是同一个账户，重新读取
+	recipient, _ = o.accountOf(tx.To)
+	if tx.LockCondition.isSet() && !tx.LockCondition.expired(o.height, o.timestamp) {
+		recipient.lock(LockedFunds{TxID: tx.ID, Denom: denomOf(tx.Denom), Amount: tx.Amount, LockCondition: tx.LockCondition})
+	} else {
+		recipient.add(tx.Denom, tx.Amount)
+	}
+	o.accounts[tx.To] = recipient
+	return nil
+}
+
+// commit 将临时状态写回钱包管理器
+func (o *stateOverlay) commit() {
+	o.wm.mu.Lock()
+	defer o.wm.mu.Unlock()
+
+	for address, account := range o.accounts {
+		wallet, exists := o.wm.wallets[address]
+		if !exists {
+			// 创建新钱包（只有地址）
+			wallet = &Wallet{Address: address}
+			o.wm.wallets[address] = wallet
+		}
+		wallet.Balance = account.Balance
+		wallet.Tokens = account.Tokens
+		wallet.Nonce = account.Nonce
+		wallet.Locked = account.Locked
+	}
+	for denom, creator := range o.denoms {
+		o.wm.denoms[denom] = creator
+	}
+	for denom, amount := range o.minted {
+		o.wm.minted.add(denom, amount)
+	}
+	for denom, amount := range o.fees {
+		o.wm.burned.add(denom, amount-o.feesPaid[denom])
+	}
+	o.reset()
+}
+
+// totalMinted 加锁读取基础代币已发行的总量
+func (wm *WalletManager) totalMinted() int64 {
+	return wm.mintedOf(BaseDenom)
+}
+
+// mintedOf 加锁读取指定代币已发行的总量
+func (wm *WalletManager) mintedOf(denom string) int64 {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.minted[denomOf(denom)]
+}
+
+// ApplyTransactions 原子地执行一组交易：任何一笔失败都不会修改余额
+func (wm *WalletManager) ApplyTransactions(txs []*Transaction) error {
+	overlay := wm.newStateOverlay()
+	for _, tx := range txs {
+		if err := overlay.apply(tx); err != nil {
+			return fmt.Errorf("交易 %s 执行失败: %v", tx.ID, err)
+		}
+	}
+
+	overlay.commit()
+	return nil
+}
+
+// executeBlock 是区块的状态转换函数
+//
+// 先按创世文件中的链参数检查系统交易和手续费、交易是否重复上链，再原子地执行区块中的
+// 全部交易，成功后把交易ID记录到included中。同样的区块序列从同样的初始状态出发，
+// 总会得到同样的余额。
+func executeBlock(wm *WalletManager, included map[string]int, block *Block, genesis *GenesisDoc) error {
+	overlay, err := verifyBlockTransactions(wm, included, block, genesis)
+	if err != nil {
+		return err
+	}
+
+	overlay.commit()
+	for _, tx := range block.Transactions {
+		included[tx.ID] = block.Height
+	}
+	return nil
+}
+
+// checkBlockUserTransaction 检查区块中的普通交易：不能由系统发起，手续费代币被接受且不低于下限
+func checkBlockUserTransaction(tx *Transaction, genesis *GenesisDoc) error {
+	if tx.From == "system" {
+		return fmt.Errorf("只有挖矿奖励、手续费支付和创世分配可以由系统发起")
+	}
+	if isSequenced(tx) {
+		if err := genesis.FeePolicy.checkFee(tx); err != nil {
+			return fmt.Errorf("交易 %s: %v", tx.ID, err)
+		}
+	}
+	return nil
+}
+
+// verifyBlockTransactions 在临时状态上执行区块中的交易但不提交，用于在接受区块前检查它
+func verifyBlockTransactions(wm *WalletManager, included map[string]int, block *Block, genesis *GenesisDoc) (*stateOverlay, error) {
+	fees := make(Coins)
+	for _, tx := range block.Transactions {
+		if tx.From != "system" {
+			fees.add(tx.FeeDenom, tx.Fee)
+		}
+	}
+	feeTxs := 0
+
+	seen := make(map[string]bool, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		if _, exists := included[tx.ID]; exists || seen[tx.ID] {
+			return nil, fmt.Errorf("交易 %s 重复上链", tx.ID)
+		}
+		seen[tx.ID] = true
+
+		switch tx.Type {
+		case TxTypeMining:
+			if i != 0 || tx.From != "system" {
+				return nil, fmt.Errorf("挖矿奖励必须是区块的第一笔系统交易")
+			}
+			if reward := genesis.BlockReward(block.Height, wm.totalMinted()); tx.Amount != reward || reward <= 0 {
+				return nil, fmt.Errorf("挖矿奖励应为 %d，实际为 %d", reward, tx.Amount)
+			}
+		case TxTypeFee:
+			if genesis.burnsFees() {
+				return nil, fmt.Errorf("手续费应被销毁，不能支付给出块者")
+			}
+			if i < len(block.Transactions)-len(fees) || tx.From != "system" || tx.ID != feeTxID(block.Height, tx.Denom) {
+				return nil, fmt.Errorf("手续费支付必须是区块最后的系统交易，每种代币一笔")
+			}
+			if tx.Amount != fees.AmountOf(tx.Denom) {
+				return nil, fmt.Errorf("支付给出块者的 %s 手续费应为 %d，实际为 %d", denomOf(tx.Denom), fees.AmountOf(tx.Denom), tx.Amount)
+			}
+			feeTxs++
+		case TxTypeGenesis:
+			if block.Height != 1 || tx.From != "system" || tx.ID != "genesis-"+tx.To || tx.Amount <= 0 {
+				return nil, fmt.Errorf("创世分配交易 %s 无效", tx.ID)
+			}
+		default:
+			if err := checkBlockUserTransaction(tx, genesis); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !genesis.burnsFees() && feeTxs != len(fees) {
+		return nil, fmt.Errorf("区块收取的手续费必须支付给出块者")
+	}
+
+	overlay := wm.newStateOverlay()
+	overlay.supplyCap = genesis.MonetaryPolicy.SupplyCap
+	overlay.release(block.Height, block.Timestamp.Unix())
+	for _, tx := range block.Transactions {
+		if err := overlay.apply(tx); err != nil {
+			return nil, fmt.Errorf("交易 %s 执行失败: %v", tx.ID, err)
+		}
+	}
+	return overlay, nil
+}
+
+// replayChain 从零余额开始依次执行每个区块，重建钱包状态、交易历史和已上链交易索引
+//
+// 本地钱包的密钥会保留下来，其余只有地址的钱包由回放重新生成。
+func (n *Node) replayChain(chain []*Block) (*WalletManager, *txHistory, map[string]int, error) {
+	wm := n.walletManager.cloneKeys()
+	history := newTxHistory()
+	included := make(map[string]int)
+
+	for _, block := range chain {
+		if err := executeBlock(wm, included, block, n.genesis); err != nil {
+			return nil, nil, nil, &ChainValidationError{Height: block.Height, Reason: err.Error()}
+		}
+		history.append(block)
+	}
+	return wm, history, included, nil
+}
+
+// RebuildState 通过从创世区块回放整条区块链重建钱包状态
+func (n *Node) RebuildState() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wm, history, included, err := n.replayChain(n.chain)
+	if err != nil {
+		return err
+	}
+
+	n.walletManager = wm
+	n.transactions = history
+	n.includedTxs = included
+	return nil
+}
+
+// cloneKeys 复制本地钱包（带公钥的钱包，加密钱包锁定时没有私钥）、多签账户和收集中的多签交易，余额清零
+func (wm *WalletManager) cloneKeys() *WalletManager {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	clone := NewWalletManager()
+	clone.chainID = wm.chainID
+	for address, wallet := range wm.wallets {
+		if wallet.PublicKey == "" {
+			continue
+		}
+		copied := *wallet
+		copied.Balance = 0
+		copied.Tokens = nil
+		copied.Locked = nil
+		copied.Nonce = 0
+		clone.wallets[address] = &copied
+	}
+	for address, account := range wm.multisigs {
+		clone.multisigs[address] = account
+	}
+	for id, tx := range wm.multisigTxs {
+		clone.multisigTxs[id] = tx
+	}
+	return clone
+}
+
+// StateHash 计算所有账户余额和序号的哈希，用于比较不同节点的状态是否一致
+func (wm *WalletManager) StateHash() string {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	addresses := make([]string, 0, len(wm.wallets))
+	for address, wallet := range wm.wallets {
+		if wallet.Balance != 0 || wallet.Nonce != 0 || len(wallet.Tokens) > 0 || len(wallet.Locked) > 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+
+	h := sha256.New()
+	for _, address := range addresses {
+		wallet := wm.wallets[address]
+		fmt.Fprintf(h, "%s:%d:%d", address, wallet.Balance, wallet.Nonce)
+		for _, denom := range wallet.Tokens.Denoms() {
+			fmt.Fprintf(h, ":%d%s", wallet.Tokens[denom], denom)
+		}
+		for _, funds := range wallet.Locked {
+			fmt.Fprintf(h, ":%s/%d%s/%d/%d", funds.TxID, funds.Amount, funds.Denom, funds.UnlockHeight, funds.UnlockTime)
+		}
+		fmt.Fprint(h, ";")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
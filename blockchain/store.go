@@ -0,0 +1,361 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// 存储记录类型
+const (
+	RecordInit    = "init"    // 节点初始化（验证者密钥和矿工钱包）
+	RecordWallet  = "wallet"  // 新建钱包
+	RecordPending = "pending" // 加入交易池的交易
+	RecordEvict   = "evict"   // 因超时被移出交易池的交易
+	RecordBlock   = "block"   // 新区块
+	RecordChain   = "chain"   // 导入的整条区块链
+
+	RecordMultisig   = "multisig"    // 新建多签账户
+	RecordMultisigTx = "multisig_tx" // 多签交易的创建或新增签名
+)
+
+const (
+	segmentPrefix         = "blocks-"
+	segmentSuffix         = ".seg"
+	defaultMaxSegmentSize = 64 << 20 // 单个段文件最大64MB
+	recordHeaderSize      = 8        // 4字节长度 + 4字节CRC32
+	nodeKeyFile           = "node_key.json"
+)
+
+// StoreRecord 表示追加写入存储的一条记录
+type StoreRecord struct {
+	Type         string               `json:"type"`
+	ValidatorKey string               `json:"validator_key,omitempty"`
+	Wallet       *Wallet              `json:"wallet,omitempty"`
+	Transaction  *Transaction         `json:"transaction,omitempty"`
+	Block        *Block               `json:"block,omitempty"`
+	Failed       []*FailedTransaction `json:"failed,omitempty"` // 打包区块时验证失败或被移出交易池的交易
+	Blocks       []*Block             `json:"blocks,omitempty"`
+	Multisig     *MultisigAccount     `json:"multisig,omitempty"`
+}
+
+// nodeKeys 记录中的私钥，单独保存在数据目录的 node_key.json 中（权限0600）
+type nodeKeys struct {
+	ValidatorKey string            `json:"validator_key,omitempty"`
+	Wallets      map[string]string `json:"wallets,omitempty"` // 未加密钱包的地址到私钥
+}
+
+// Store 是基于分段文件的只追加存储
+//
+// 每条记录的格式为 [长度(4字节)][CRC32(4字节)][JSON数据]，写入后立即fsync。
+// 节点崩溃时最后一条记录可能只写了一半，打开存储时会截断这部分不完整的尾部。
+//
+// 验证者私钥和钱包私钥不写入段文件，而是保存在单独的密钥文件中，回放时再填回记录。
+type Store struct {
+	dir            string
+	file           *os.File // 当前写入的段文件
+	segment        int      // 当前段文件编号
+	size           int64    // 当前段文件大小
+	maxSegmentSize int64
+	keys           nodeKeys
+	mu             sync.Mutex
+}
+
+// OpenStore 打开（或创建）指定目录下的存储
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建数据目录失败: %v", err)
+	}
+
+	s := &Store{
+		dir:            dir,
+		maxSegmentSize: defaultMaxSegmentSize,
+		keys:           nodeKeys{Wallets: make(map[string]string)},
+	}
+	if err := s.loadKeys(); err != nil {
+		return nil, err
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	// 旧版本的段文件以0644创建并且可能包含明文私钥，收紧为只有所有者可读写
+	for _, segment := range segments {
+		if err := os.Chmod(s.segmentPath(segment), 0600); err != nil {
+			return nil, fmt.Errorf("修改段文件权限失败: %v", err)
+		}
+	}
+
+	if len(segments) == 0 {
+		if err := s.openSegment(1); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	// 检查最后一个段文件，截断崩溃时留下的不完整记录
+	last := segments[len(segments)-1]
+	validSize, err := s.scanSegment(last, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Truncate(s.segmentPath(last), validSize); err != nil {
+		return nil, fmt.Errorf("截断段文件失败: %v", err)
+	}
+
+	if err := s.openSegment(last); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append 追加一条记录并同步到磁盘
+func (s *Store) Append(record *StoreRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := s.saveKeys(record)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if s.size > 0 && s.size+int64(len(payload))+recordHeaderSize > s.maxSegmentSize {
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+		if err := s.openSegment(s.segment + 1); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[recordHeaderSize:], payload)
+
+	if _, err := s.file.Write(buf); err != nil {
+		return fmt.Errorf("写入记录失败: %v", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("同步记录失败: %v", err)
+	}
+
+	s.size += int64(len(buf))
+	return nil
+}
+
+// Replay 按写入顺序回放所有记录
+func (s *Store) Replay(fn func(record *StoreRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	restore := func(record *StoreRecord) error {
+		s.restoreKeys(record)
+		return fn(record)
+	}
+	for _, segment := range segments {
+		if _, err := s.scanSegment(segment, restore); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadKeys 读取密钥文件，文件不存在时没有已保存的私钥
+func (s *Store) loadKeys() error {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, nodeKeyFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取密钥文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return fmt.Errorf("解析密钥文件失败: %v", err)
+	}
+	if s.keys.Wallets == nil {
+		s.keys.Wallets = make(map[string]string)
+	}
+	return nil
+}
+
+// saveKeys 把记录中的私钥写入密钥文件，返回去掉私钥后用于写入段文件的记录
+//
+// 密钥文件先写临时文件再改名，并且在记录写入之前完成，回放时总能找到记录对应的私钥。
+func (s *Store) saveKeys(record *StoreRecord) (*StoreRecord, error) {
+	hasWalletKey := record.Wallet != nil && record.Wallet.PrivateKey != ""
+	if record.ValidatorKey == "" && !hasWalletKey {
+		return record, nil
+	}
+
+	stripped := *record
+	if record.ValidatorKey != "" {
+		s.keys.ValidatorKey = record.ValidatorKey
+		stripped.ValidatorKey = ""
+	}
+	if hasWalletKey {
+		s.keys.Wallets[record.Wallet.Address] = record.Wallet.PrivateKey
+		wallet := *record.Wallet
+		wallet.PrivateKey = ""
+		stripped.Wallet = &wallet
+	}
+
+	data, err := json.MarshalIndent(&s.keys, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.dir, nodeKeyFile)
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return nil, fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	return &stripped, nil
+}
+
+// restoreKeys 把密钥文件中的私钥填回回放的记录，旧版本写在记录中的私钥保持不变
+func (s *Store) restoreKeys(record *StoreRecord) {
+	if record.Type == RecordInit && record.ValidatorKey == "" {
+		record.ValidatorKey = s.keys.ValidatorKey
+	}
+	if record.Wallet != nil && record.Wallet.PrivateKey == "" {
+		record.Wallet.PrivateKey = s.keys.Wallets[record.Wallet.Address]
+	}
+}
+
+// Close 关闭存储
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// segments 返回按编号排序的段文件列表
+func (s *Store) segments() ([]int, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, segmentPrefix+"*"+segmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]int, 0, len(matches))
+	for _, match := range matches {
+		var index int
+		if _, err := fmt.Sscanf(filepath.Base(match), segmentPrefix+"%06d"+segmentSuffix, &index); err != nil {
+			continue
+		}
+		segments = append(segments, index)
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
+
+func (s *Store) segmentPath(index int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s%06d%s", segmentPrefix, index, segmentSuffix))
+}
+
+// openSegment 以追加模式打开段文件
+func (s *Store) openSegment(index int) error {
+	file, err := os.OpenFile(s.segmentPath(index), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开段文件失败: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.segment = index
+	s.size = info.Size()
+	return nil
+}
+
+// scanSegment 读取段文件中的记录，返回最后一条完整记录结束的位置
+//
+// 只有最后一个段文件允许出现不完整的尾部记录，更早的段文件损坏视为错误。
+func (s *Store) scanSegment(index int, fn func(record *StoreRecord) error) (int64, error) {
+	file, err := os.Open(s.segmentPath(index))
+	if err != nil {
+		return 0, fmt.Errorf("打开段文件失败: %v", err)
+	}
+	defer file.Close()
+
+	isLast := s.file == nil || index >= s.segment
+	var offset int64
+	header := make([]byte, recordHeaderSize)
+
+	for {
+		if _, err := io.ReadFull(file, header); err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			if err == io.ErrUnexpectedEOF && isLast {
+				return offset, nil
+			}
+			return offset, fmt.Errorf("段文件 %d 在偏移 %d 处损坏: %v", index, offset, err)
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		checksum := binary.BigEndian.Uint32(header[4:8])
+		if int64(length) > s.maxSegmentSize {
+			if isLast {
+				return offset, nil
+			}
+			return offset, fmt.Errorf("段文件 %d 在偏移 %d 处记录长度无效", index, offset)
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(file, payload); err != nil {
+			if isLast {
+				return offset, nil
+			}
+			return offset, fmt.Errorf("段文件 %d 在偏移 %d 处损坏: %v", index, offset, err)
+		}
+
+		if crc32.ChecksumIEEE(payload) != checksum {
+			if isLast {
+				return offset, nil
+			}
+			return offset, fmt.Errorf("段文件 %d 在偏移 %d 处校验失败", index, offset)
+		}
+
+		if fn != nil {
+			var record StoreRecord
+			if err := json.Unmarshal(payload, &record); err != nil {
+				return offset, fmt.Errorf("解析记录失败: %v", err)
+			}
+			if err := fn(&record); err != nil {
+				return offset, err
+			}
+		}
+
+		offset += int64(recordHeaderSize) + int64(length)
+	}
+}
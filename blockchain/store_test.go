@@ -0,0 +1,159 @@
+package blockchain
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"a", "b"} {
+		if err := store.Append(&StoreRecord{Type: RecordPending, Transaction: NewDataTransaction(data)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// 模拟崩溃：写入半条记录
+	file, err := os.OpenFile(store.segmentPath(1), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 0, 0, 9, 1, 2})
+	file.Close()
+
+	store, err = OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Append(&StoreRecord{Type: RecordPending, Transaction: NewDataTransaction("c")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err = store.Replay(func(record *StoreRecord) error {
+		got = append(got, record.Transaction.Data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Fatalf("回放结果错误: %v", got)
+	}
+}
+
+func TestNodeRecoversFromStore(t *testing.T) {
+	dir := t.TempDir()
+
+	node, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := node.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+	if _, err := node.Transfer(node.GetMinerAddress(), alice.Address, 10, 1); err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+
+	minerBalance := node.GetBalance(node.GetMinerAddress())
+	txCount := len(node.GetTransactions())
+	node.Close()
+
+	recovered, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+
+	if recovered.GetHeight() != 3 {
+		t.Fatalf("高度应为3，实际为%d", recovered.GetHeight())
+	}
+	if recovered.GetMinerAddress() != node.GetMinerAddress() {
+		t.Fatalf("矿工地址未恢复")
+	}
+	if got := recovered.GetBalance(alice.Address); got != 10 {
+		t.Fatalf("余额应为10，实际为%d", got)
+	}
+	if got := recovered.GetBalance(recovered.GetMinerAddress()); got != minerBalance {
+		t.Fatalf("矿工余额应为%d，实际为%d", minerBalance, got)
+	}
+	if got := len(recovered.GetTransactions()); got != txCount {
+		t.Fatalf("交易历史应有%d条，实际为%d", txCount, got)
+	}
+
+	recovered.generateNewBlock()
+	if recovered.GetHeight() != 4 {
+		t.Fatalf("恢复后应从最新高度继续出块")
+	}
+}
+
+func TestStoreKeepsPrivateKeysOutOfSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	node, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := node.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.generateNewBlock()
+	secrets := []string{node.validatorKey, node.GetWallet(node.GetMinerAddress()).PrivateKey, alice.PrivateKey}
+	node.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, segmentPrefix+"*"+segmentSuffix))
+	for _, path := range append(segments, filepath.Join(dir, nodeKeyFile)) {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("%s 的权限应为0600，实际为%v", path, info.Mode().Perm())
+		}
+	}
+	for _, path := range segments {
+		data, _ := os.ReadFile(path)
+		for _, secret := range secrets {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Fatalf("段文件 %s 中不应包含明文私钥", path)
+			}
+		}
+	}
+
+	// 重启后私钥从密钥文件恢复，仍然可以签名
+	recovered, err := NewNode(NodeConfig{BlockTime: 1, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	if recovered.GetValidator() != node.GetValidator() {
+		t.Fatal("验证者身份未恢复")
+	}
+	if _, err := recovered.Transfer(recovered.GetMinerAddress(), alice.Address, 10, 1); err != nil {
+		t.Fatal(err)
+	}
+	recovered.generateNewBlock()
+	if got := recovered.GetBalance(alice.Address); got != 10 {
+		t.Fatalf("余额应为10，实际为%d", got)
+	}
+}
@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 交易的规范编码是 Bytes 输出的紧凑JSON：字段按 Transaction 结构体中的顺序排列，没有多余空白，
+// 可省略的字段为空时不出现。签名针对 SignBytes 的SHA-256摘要，交易ID是 SignBytes 的SHA-256，
+// 都不依赖公钥和签名本身，所以客户端可以先模拟得到签名字节，在本地签名后再广播。
+// 用户交易必须在 chain_id 字段填写本链的链ID，它是签名字节的一部分，其他链的交易无法在本链重放。
+
+// EncodeTransaction 返回交易的规范编码
+func EncodeTransaction(tx *Transaction) []byte {
+	return tx.Bytes()
+}
+
+// DecodeTransaction 解码交易的规范编码
+//
+// 不认识的字段、多余的数据和非规范的写法（字段顺序、空白、值为空的可省略字段）都会被拒绝，
+// 保证同一笔交易只有一种编码。
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var tx Transaction
+	if err := decoder.Decode(&tx); err != nil {
+		return nil, fmt.Errorf("交易编码无效: %v", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("交易编码之后有多余的数据")
+	}
+	if !bytes.Equal(tx.Bytes(), data) {
+		return nil, fmt.Errorf("交易不是规范编码")
+	}
+	if tx.ID != tx.Hash() {
+		return nil, fmt.Errorf("交易ID与内容不匹配")
+	}
+	return &tx, nil
+}
+
+// signed 判断交易是否带有签名
+func (tx *Transaction) signed() bool {
+	return tx.Signature != "" || len(tx.Signatures) > 0
+}
+
+// SimulationResult 模拟执行交易的结果，不修改任何状态
+type SimulationResult struct {
+	TxID      string           `json:"tx_id"`
+	ChainID   string           `json:"chain_id"`   // 签名字节中的链ID，必须与本链一致
+	SignBytes string           `json:"sign_bytes"` // 需要签名的字节（十六进制），签名对象是它的SHA-256摘要
+	Signed    bool             `json:"signed"`     // 交易是否已签名；未签名的交易跳过签名检查
+	Fee       Coin             `json:"fee"`
+	MinFee    int64            `json:"min_fee"`
+	Nonce     uint64           `json:"nonce"`            // 发送方下一笔交易应使用的序号（包括交易池中的交易）
+	Balances  map[string]Coins `json:"balances"`         // 交易执行后发送方和接收方的可用余额
+	Locked    map[string]Coins `json:"locked,omitempty"` // 交易执行后仍锁定的资金
+}
+
+// userTransaction 检查交易是否是用户可以提交的类型
+func userTransaction(tx *Transaction) error {
+	if tx.From == "system" || (tx.Type != TxTypeTransfer && tx.Type != TxTypeIssue) {
+		return fmt.Errorf("只能提交用户签名的转账或发行交易，不支持 %s", tx.Type)
+	}
+	return nil
+}
+
+// SubmitSignedTransaction 把客户端签好名的交易放入交易池并广播
+//
+// 节点不接触私钥，交易必须已经带有有效的签名。
+func (n *Node) SubmitSignedTransaction(tx *Transaction) error {
+	if err := userTransaction(tx); err != nil {
+		return err
+	}
+	if !tx.signed() {
+		return fmt.Errorf("交易没有签名")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, included := n.includedTxs[tx.ID]; included || n.mempool.Contains(tx.ID) {
+		return errKnownTransaction
+	}
+	if err := n.validatePending(tx); err != nil {
+		return err
+	}
+	return n.addPending(tx)
+}
+
+// SimulateTransaction 在当前状态上模拟执行交易，返回手续费和执行后的余额
+//
+// 交易可以不带签名，此时只检查余额、序号和手续费。模拟基于已提交的余额，
+// 不包括交易池中其他交易的影响，与交易进入交易池时的检查一致。
+func (n *Node) SimulateTransaction(tx *Transaction) (*SimulationResult, error) {
+	if err := userTransaction(tx); err != nil {
+		return nil, err
+	}
+	if tx.ID == "" {
+		tx.ID = tx.Hash()
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	signed := tx.signed()
+	if err := n.checkPending(tx, signed); err != nil {
+		return nil, err
+	}
+
+	// 在临时状态上执行，交易池中的同一发送方的交易不参与模拟，序号直接使用交易中的序号
+	height := 1
+	if len(n.chain) > 0 {
+		height = n.chain[len(n.chain)-1].Height + 1
+	}
+	overlay := n.walletManager.newStateOverlay()
+	overlay.unsigned = !signed
+	overlay.release(height, time.Now().Unix())
+	sender, _ := overlay.accountOf(tx.From)
+	sender.Nonce = tx.Nonce
+	overlay.accounts[tx.From] = sender
+	if err := overlay.apply(tx); err != nil {
+		return nil, err
+	}
+
+	result := &SimulationResult{
+		TxID:      tx.ID,
+		ChainID:   tx.ChainID,
+		SignBytes: hex.EncodeToString(tx.SignBytes()),
+		Signed:    signed,
+		Fee:       Coin{Denom: denomOf(tx.FeeDenom), Amount: tx.Fee},
+		MinFee:    n.genesis.FeePolicy.MinFee,
+		Nonce:     n.nextNonce(tx.From),
+		Balances:  make(map[string]Coins),
+	}
+	for _, address := range []string{tx.From, tx.To} {
+		account, _ := overlay.accountOf(address)
+		result.Balances[address] = account.coins()
+		if len(account.Locked) > 0 {
+			if result.Locked == nil {
+				result.Locked = make(map[string]Coins)
+			}
+			result.Locked[address] = lockedCoins(account.Locked)
+		}
+	}
+	return result, nil
+}
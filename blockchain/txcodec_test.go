@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestTransactionCanonicalEncoding(t *testing.T) {
+	tx := &Transaction{
+		Type:      TxTypeTransfer,
+		From:      testAddress("alice"),
+		To:        testAddress("bob"),
+		Amount:    10,
+		Fee:       3,
+		Timestamp: 1700000000,
+	}
+	tx.ID = tx.Hash()
+
+	data := EncodeTransaction(tx)
+	decoded, err := DecodeTransaction(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != tx.ID || decoded.To != tx.To {
+		t.Fatalf("解码后的交易 = %+v", decoded)
+	}
+
+	invalid := []string{
+		strings.Replace(string(data), `"amount":10`, `"amount": 10`, 1),
+		strings.Replace(string(data), `{"id"`, `{"extra":1,"id"`, 1),
+		strings.Replace(string(data), `"amount":10`, `"amount":11`, 1),
+		strings.Replace(string(data), `"nonce":0`, `"nonce":0,"denom":""`, 1),
+		string(data) + `{}`,
+	}
+	for _, s := range invalid {
+		if _, err := DecodeTransaction([]byte(s)); err == nil {
+			t.Fatalf("编码 %s 应被拒绝", s)
+		}
+	}
+}
+
+func TestClientSignedTransaction(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+	node.walletManager.setPrivateKey(alice.Address, "")
+
+	// 客户端构造未签名的交易，先模拟得到签名字节和执行后的余额
+	bob := testAddress("bob")
+	tx := &Transaction{
+		Type:      TxTypeTransfer,
+		From:      alice.Address,
+		To:        bob,
+		Amount:    100,
+		Fee:       3,
+		Nonce:     node.GetNonce(alice.Address),
+		Timestamp: 1700000000,
+		ChainID:   "test-chain",
+	}
+	result, err := node.SimulateTransaction(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Signed || result.ChainID != "test-chain" || result.Balances[alice.Address][BaseDenom] != 5000-103 || result.Balances[bob][BaseDenom] != 100 {
+		t.Fatalf("模拟结果 = %+v", result)
+	}
+	if node.GetBalance(bob) != 0 || node.GetMempoolEntry(tx.ID) != nil {
+		t.Fatal("模拟执行不应修改状态")
+	}
+	if err := node.SubmitSignedTransaction(tx); err == nil {
+		t.Fatal("未签名的交易不能广播")
+	}
+
+	overdraft := *tx
+	overdraft.Amount = 1 << 40
+	overdraft.ID = ""
+	if _, err := node.SimulateTransaction(&overdraft); err == nil {
+		t.Fatal("余额不足的交易模拟应失败")
+	}
+
+	// 在本地用私钥签名后以规范编码广播
+	signBytes, _ := hex.DecodeString(result.SignBytes)
+	if tx.Signature, err = SignBytes(alice.PrivateKey, signBytes); err != nil {
+		t.Fatal(err)
+	}
+	tx.PublicKey = alice.PublicKey
+	decoded, err := DecodeTransaction(EncodeTransaction(tx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err = node.SimulateTransaction(decoded); err != nil || !result.Signed {
+		t.Fatalf("签名后的模拟结果 = %+v, %v", result, err)
+	}
+	if err := node.SubmitSignedTransaction(decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SubmitSignedTransaction(decoded); err == nil {
+		t.Fatal("重复的交易应被拒绝")
+	}
+	node.generateNewBlock()
+	if node.GetBalance(bob) != 100 {
+		t.Fatalf("bob的余额 = %d，期望 100", node.GetBalance(bob))
+	}
+
+	// 篡改签名后的交易无法通过验证
+	forged := *decoded
+	forged.Nonce++
+	forged.Amount = 200
+	forged.ID = forged.Hash()
+	if err := node.SubmitSignedTransaction(&forged); err == nil {
+		t.Fatal("篡改后的交易应被拒绝")
+	}
+
+	// 为其他链签名的交易不能在本链上重放
+	replayed := forged
+	replayed.Amount = decoded.Amount
+	replayed.ChainID = "other-chain"
+	replayed.ID = replayed.Hash()
+	if replayed.Signature, err = SignBytes(alice.PrivateKey, replayed.SignBytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SubmitSignedTransaction(&replayed); err == nil {
+		t.Fatal("其他链的交易应被拒绝")
+	}
+	if err := node.SubmitSignedTransaction(&Transaction{ID: "mining-reward-9", Type: TxTypeMining, From: "system", To: bob, Amount: 1000}); err == nil {
+		t.Fatal("不能广播系统交易")
+	}
+}
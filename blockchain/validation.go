@@ -0,0 +1,124 @@
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// genesisPrevHash 创世区块的前一区块哈希
+var genesisPrevHash = strings.Repeat("0", 64)
+
+// maxFutureBlockTime 接受区块时，区块时间最多允许比本地时间超前多少
+const maxFutureBlockTime = 15 * time.Second
+
+// ChainValidationError 描述区块链校验失败的位置和原因
+type ChainValidationError struct {
+	Height int    // 第一个无效区块的高度
+	Reason string // 失败原因
+}
+
+func (e *ChainValidationError) Error() string {
+	return fmt.Sprintf("高度 %d 的区块无效: %s", e.Height, e.Reason)
+}
+
+// ValidateChain 按顺序校验整条区块链
+//
+// 依次检查每个区块的高度连续性、时间戳单调性、前一区块哈希链接、
+// 交易根、区块哈希和验证者签名，返回第一个无效区块对应的 *ChainValidationError。
+func ValidateChain(chain []*Block) error {
+	var prev *Block
+	for i, block := range chain {
+		if block == nil {
+			return &ChainValidationError{Height: i + 1, Reason: "区块为空"}
+		}
+		if err := validateBlock(block, prev); err != nil {
+			return &ChainValidationError{Height: block.Height, Reason: err.Error()}
+		}
+		prev = block
+	}
+	return nil
+}
+
+// validateBlock 校验单个区块及其与前一区块的关系，prev为nil表示创世区块
+func validateBlock(block, prev *Block) error {
+	if prev == nil {
+		if block.Height != 1 {
+			return fmt.Errorf("创世区块高度应为1，实际为%d", block.Height)
+		}
+		if block.PrevHash != genesisPrevHash {
+			return fmt.Errorf("创世区块的前一区块哈希必须全为0")
+		}
+	} else {
+		if block.Height != prev.Height+1 {
+			return fmt.Errorf("高度不连续：前一区块高度为%d", prev.Height)
+		}
+		if block.Timestamp.Before(prev.Timestamp) {
+			return fmt.Errorf("时间戳早于前一区块")
+		}
+		if block.PrevHash != prev.Hash {
+			return fmt.Errorf("前一区块哈希不匹配：期望 %s，实际 %s", prev.Hash, block.PrevHash)
+		}
+	}
+
+	if root := CalculateTxRoot(block.Transactions); block.TxRoot != root {
+		return fmt.Errorf("交易根不匹配：期望 %s，实际 %s", root, block.TxRoot)
+	}
+
+	if hash := calculateBlockHash(block); block.Hash != hash {
+		return fmt.Errorf("区块哈希不匹配：期望 %s，实际 %s", hash, block.Hash)
+	}
+
+	// 由创世文件生成的创世区块没有签名
+	if prev == nil && isDocumentGenesis(block) {
+		return nil
+	}
+	signature := block.Signature.R + block.Signature.S
+	if err := VerifySignature(block.Validator, []byte(block.Hash), signature); err != nil {
+		return fmt.Errorf("验证者签名无效: %v", err)
+	}
+
+	return nil
+}
+
+// checkBlockTime 拒绝时间超前本地时间过多的区块
+//
+// 区块时间由出块者决定，只要求不早于前一区块还不够：出块者可以把时间设到很远的将来，
+// 之后正常出块的节点都无法再生成时间不早于它的区块，按时间解锁的资金也会被提前释放。
+// 由创世文件生成的创世区块可以使用将来的创世时间。
+func checkBlockTime(block *Block, now time.Time) error {
+	if block.Height == 1 && isDocumentGenesis(block) {
+		return nil
+	}
+	if block.Timestamp.After(now.Add(maxFutureBlockTime)) {
+		return fmt.Errorf("区块时间 %s 超前本地时间超过 %v", block.Timestamp.Format(time.RFC3339), maxFutureBlockTime)
+	}
+	return nil
+}
+
+// ValidateChain 校验节点当前的整条区块链
+func (n *Node) ValidateChain() error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.validateChain(n.chain)
+}
+
+// validateChain 完整校验区块链，并检查每个区块是否符合节点共识引擎的规则
+func (n *Node) validateChain(chain []*Block) error {
+	if err := ValidateChain(chain); err != nil {
+		return err
+	}
+
+	byHash := make(map[string]*Block, len(chain))
+	lookup := func(hash string) *Block { return byHash[hash] }
+	var prev *Block
+	for _, block := range chain {
+		if err := n.engine.VerifyBlock(block, prev, lookup); err != nil {
+			return &ChainValidationError{Height: block.Height, Reason: err.Error()}
+		}
+		byHash[block.Hash] = block
+		prev = block
+	}
+	return nil
+}
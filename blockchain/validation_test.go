@@ -0,0 +1,99 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestChain(t *testing.T, blocks int) *Node {
+	t.Helper()
+
+	node, err := NewNode(NodeConfig{BlockTime: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := node.CreateGenesisBlock("genesis"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < blocks; i++ {
+		node.generateNewBlock()
+	}
+	return node
+}
+
+func TestImportRejectsTamperedChain(t *testing.T) {
+	node := newTestChain(t, 4)
+	if err := node.ValidateChain(); err != nil {
+		t.Fatalf("本地生成的区块链应当有效: %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "chain.json")
+	if err := node.ExportBlockchain(file); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewNode(NodeConfig{BlockTime: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.ImportBlockchain(file); err != nil {
+		t.Fatalf("导入有效区块链失败: %v", err)
+	}
+	if other.GetHeight() != 4 {
+		t.Fatalf("导入后高度应为4，实际为%d", other.GetHeight())
+	}
+
+	// 手工修改第3个区块的数据
+	chain := node.GetAllBlocks()
+	chain[2].Data = "篡改"
+	data, _ := json.Marshal(chain)
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := NewNode(NodeConfig{BlockTime: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fresh.ImportBlockchain(file)
+	var validationErr *ChainValidationError
+	if !errors.As(err, &validationErr) || validationErr.Height != 3 {
+		t.Fatalf("应当在高度3处拒绝导入，实际错误: %v", err)
+	}
+	if fresh.GetHeight() != 0 {
+		t.Fatal("导入失败时不应修改区块链")
+	}
+}
+
+func TestRejectsBlocksFromTheFuture(t *testing.T) {
+	node := newTestChain(t, 2)
+
+	node.mu.Lock()
+	block, _ := node.buildBlock()
+	block.Timestamp = block.Timestamp.Add(time.Hour)
+	node.engine.Seal(block, func() bool { return false })
+	block.Signature, _ = node.signBlock(block)
+	node.mu.Unlock()
+
+	if err := node.AddPeerBlock(block); err == nil {
+		t.Fatal("时间远超本地时间的区块应被拒绝")
+	}
+	if node.GetHeight() != 2 {
+		t.Fatalf("高度应保持为2，实际为%d", node.GetHeight())
+	}
+
+	// 稍快的时钟在允许范围内
+	node.mu.Lock()
+	block, _ = node.buildBlock()
+	block.Timestamp = block.Timestamp.Add(maxFutureBlockTime / 2)
+	node.engine.Seal(block, func() bool { return false })
+	block.Signature, _ = node.signBlock(block)
+	node.mu.Unlock()
+	if err := node.AddPeerBlock(block); err != nil {
+		t.Fatal(err)
+	}
+}
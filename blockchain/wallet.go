@@ -1,9 +1,9 @@
 package blockchain
 
 import (
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -14,51 +14,194 @@ type Wallet struct {
 	Address    string `json:"address"`
 	PrivateKey string `json:"private_key"`
 	PublicKey  string `json:"public_key"`
-	Balance    int64  `json:"balance"`
+	Balance    int64  `json:"balance"`          // 基础代币余额
+	Tokens     Coins  `json:"tokens,omitempty"` // 其他代币的余额
+	Nonce      uint64 `json:"nonce"`            // 下一笔转账必须使用的序号
+
+	Locked []LockedFunds `json:"locked,omitempty"` // 尚未解锁的资金，不计入可用余额
+}
+
+// accountState 账户在某个状态视图下的余额和下一个序号
+type accountState struct {
+	Balance int64
+	Tokens  Coins
+	Nonce   uint64
+	Locked  []LockedFunds
 }
 
+// 交易类型
+const (
+	TxTypeTransfer = "transfer" // 用户转账
+	TxTypeMining   = "mining"   // 挖矿奖励
+	TxTypeGenesis  = "genesis"  // 创世文件中的初始分配，只能出现在创世区块中
+	TxTypeFee      = "fee"      // 把区块中的手续费支付给出块者
+	TxTypeIssue    = "issue"    // 代币创建者发行自定义代币
+	TxTypeData     = "data"     // 只携带数据的交易
+)
+
 // Transaction 表示一笔交易
 type Transaction struct {
 	ID        string `json:"id"`
+	Type      string `json:"type"`
 	From      string `json:"from"`
 	To        string `json:"to"`
 	Amount    int64  `json:"amount"`
+	Denom     string `json:"denom,omitempty"` // 金额的代币名称，为空表示基础代币
 	Fee       int64  `json:"fee"`
+	FeeDenom  string `json:"fee_denom,omitempty"` // 手续费的代币名称，为空表示基础代币
+	Nonce     uint64 `json:"nonce"`               // 发送方账户的交易序号，防止重放
 	Timestamp int64  `json:"timestamp"`
+	ChainID   string `json:"chain_id,omitempty"`   // 交易所属的链ID，一并签名，防止交易在其他链上重放
+	Data      string `json:"data,omitempty"`       // 数据交易携带的内容
+	PublicKey string `json:"public_key,omitempty"` // 发送方公钥，用于验证签名
 	Signature string `json:"signature"`
+
+	LockCondition // 转账的解锁条件，为空表示立即到账
+
+	Multisig   *MultisigAccount   `json:"multisig,omitempty"`   // 发送方是多签账户时携带账户信息
+	Signatures []PartialSignature `json:"signatures,omitempty"` // 多签成员的签名
+}
+
+// Bytes 返回交易的完整编码，用于计算区块大小和Merkle叶子
+func (tx *Transaction) Bytes() []byte {
+	data, _ := json.Marshal(tx)
+	return data
+}
+
+// SignBytes 返回交易的规范签名字节（不含ID、公钥和签名本身）
+func (tx *Transaction) SignBytes() []byte {
+	doc := struct {
+		ChainID   string `json:"chain_id"`
+		Type      string `json:"type"`
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Amount    int64  `json:"amount"`
+		Denom     string `json:"denom,omitempty"`
+		Fee       int64  `json:"fee"`
+		FeeDenom  string `json:"fee_denom,omitempty"`
+		Nonce     uint64 `json:"nonce"`
+		Timestamp int64  `json:"timestamp"`
+		Data      string `json:"data,omitempty"`
+		LockCondition
+	}{
+		ChainID:       tx.ChainID,
+		Type:          tx.Type,
+		From:          tx.From,
+		To:            tx.To,
+		Amount:        tx.Amount,
+		Denom:         tx.Denom,
+		Fee:           tx.Fee,
+		FeeDenom:      tx.FeeDenom,
+		Nonce:         tx.Nonce,
+		Timestamp:     tx.Timestamp,
+		Data:          tx.Data,
+		LockCondition: tx.LockCondition,
+	}
+
+	data, _ := json.Marshal(doc)
+	return data
+}
+
+// Hash 返回由签名字节计算出的交易ID
+func (tx *Transaction) Hash() string {
+	hash := sha256.Sum256(tx.SignBytes())
+	return hex.EncodeToString(hash[:])
 }
 
 // WalletManager 钱包管理器
 type WalletManager struct {
 	wallets map[string]*Wallet
-	mu      sync.RWMutex
+	minted  Coins             // 每种代币的发行总量
+	burned  Coins             // 每种代币作为手续费被销毁的总量
+	denoms  map[string]string // 发行代币的名称到创建者地址
+	chainID string            // 本链的链ID，用户交易必须签入相同的链ID
+
+	multisigs   map[string]*MultisigAccount // 已登记的多签账户
+	multisigTxs map[string]*Transaction     // 正在收集签名的多签交易
+
+	mu sync.RWMutex
 }
 
 // NewWalletManager 创建钱包管理器
 func NewWalletManager() *WalletManager {
 	return &WalletManager{
 		wallets: make(map[string]*Wallet),
+		minted:  make(Coins),
+		burned:  make(Coins),
+		denoms:  make(map[string]string),
+
+		multisigs:   make(map[string]*MultisigAccount),
+		multisigTxs: make(map[string]*Transaction),
 	}
 }
 
 // CreateWallet 创建新钱包
-func (wm *WalletManager) CreateWallet() *Wallet {
+func (wm *WalletManager) CreateWallet() (*Wallet, error) {
+	wallet, err := wm.newWallet()
+	if err != nil {
+		return nil, err
+	}
+	wm.RestoreWallet(wallet)
+	return wallet, nil
+}
+
+// RestoreWallet 将已有的钱包加入管理器（用于从存储中恢复）
+//
+// 地址已经存在时（例如之前只作为收款方出现过）只补充密钥，保留余额。
+func (wm *WalletManager) RestoreWallet(wallet *Wallet) {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	existing, exists := wm.wallets[wallet.Address]
+	if !exists {
+		wm.wallets[wallet.Address] = wallet
+		return
+	}
+	existing.PublicKey = wallet.PublicKey
+	if wallet.PrivateKey != "" {
+		existing.PrivateKey = wallet.PrivateKey
+	}
+}
+
+// setPrivateKey 设置或清除（privateKey为空时）本地钱包的私钥，用于解锁和锁定加密钱包
+func (wm *WalletManager) setPrivateKey(address, privateKey string) {
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
-	privateKey := wm.generatePrivateKey()
-	publicKey := wm.generatePublicKey(privateKey)
+	if wallet, exists := wm.wallets[address]; exists {
+		wallet.PrivateKey = privateKey
+	}
+}
+
+// signingKey 加锁读取本地钱包的公钥和私钥
+func (wm *WalletManager) signingKey(address string) (publicKey, privateKey string, exists bool) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	wallet, exists := wm.wallets[address]
+	if !exists {
+		return "", "", false
+	}
+	return wallet.PublicKey, wallet.PrivateKey, true
+}
+
+// newWallet 生成新的密钥和地址，但不加入管理器
+func (wm *WalletManager) newWallet() (*Wallet, error) {
+	privateKey, err := GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := PublicKeyFromPrivate(privateKey)
+	if err != nil {
+		return nil, err
+	}
 	address := wm.generateAddress(publicKey)
 
-	wallet := &Wallet{
+	return &Wallet{
 		Address:    address,
 		PrivateKey: privateKey,
 		PublicKey:  publicKey,
-		Balance:    1000, // 初始余额1000币
-	}
-
-	wm.wallets[address] = wallet
-	return wallet
+	}, nil
 }
 
 // GetWallet 获取钱包
@@ -102,96 +245,200 @@ func (wm *WalletManager) GetBalance(address string) int64 {
 	return 0
 }
 
-// ValidateTransaction 验证交易
+// ValidateTransaction 基于当前钱包余额验证交易
 func (wm *WalletManager) ValidateTransaction(tx *Transaction) error {
 	wm.mu.RLock()
 	defer wm.mu.RUnlock()
 
-	if tx.From == "system" {
-		// 系统转账（挖矿奖励）总是有效的
-		return nil
-	}
+	return wm.validateTransaction(tx, wm.accountOf)
+}
 
-	fromWallet, exists := wm.wallets[tx.From]
+// accountOf 返回账户状态以及钱包是否存在，调用者需持有锁
+func (wm *WalletManager) accountOf(address string) (accountState, bool) {
+	wallet, exists := wm.wallets[address]
 	if !exists {
-		return fmt.Errorf("发送方钱包不存在")
+		return accountState{}, false
 	}
+	return accountState{Balance: wallet.Balance, Tokens: wallet.Tokens, Nonce: wallet.Nonce, Locked: wallet.Locked}, true
+}
 
-	totalAmount := tx.Amount + tx.Fee
-	if fromWallet.Balance < totalAmount {
-		return fmt.Errorf("余额不足：需要 %d，实际 %d", totalAmount, fromWallet.Balance)
-	}
+// account 加锁读取账户状态
+func (wm *WalletManager) account(address string) (accountState, bool) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
 
-	return nil
+	return wm.accountOf(address)
 }
 
-// ProcessTransaction 处理交易
-func (wm *WalletManager) ProcessTransaction(tx *Transaction) error {
-	if err := wm.ValidateTransaction(tx); err != nil {
+// validateTransaction 在给定的账户状态视图下验证交易
+func (wm *WalletManager) validateTransaction(tx *Transaction, accountOf func(string) (accountState, bool)) error {
+	return wm.checkTransaction(tx, accountOf, true)
+}
+
+// checkTransaction 在给定的账户状态视图下验证交易，verifySignatures为false时跳过签名检查（用于模拟未签名的交易）
+func (wm *WalletManager) checkTransaction(tx *Transaction, accountOf func(string) (accountState, bool), verifySignatures bool) error {
+	switch {
+	case tx.From == "system":
+		// 系统交易只能由出块节点放入区块，规则由 verifyBlockTransactions 检查
+		return fmt.Errorf("交易 %s 不能以系统身份发起", tx.ID)
+	case tx.Type == TxTypeData:
+		// 数据交易不涉及余额变动，但ID必须由内容计算得出，不能冒用其他交易的ID
+		if tx.ID != tx.Hash() {
+			return fmt.Errorf("交易ID与内容不匹配")
+		}
+		return nil
+	case tx.Type != TxTypeTransfer && tx.Type != TxTypeIssue:
+		return fmt.Errorf("不支持的交易类型: %s", tx.Type)
+	case tx.ChainID != wm.chainID:
+		return fmt.Errorf("交易属于链 %q，不是本链 %q", tx.ChainID, wm.chainID)
+	}
+
+	if tx.Amount <= 0 || tx.Fee < 0 {
+		return fmt.Errorf("转账金额必须大于0且手续费不能为负")
+	}
+	if err := ValidateAddress(tx.To); err != nil {
+		return fmt.Errorf("接收方地址无效: %v", err)
+	}
+	for _, denom := range []string{tx.Denom, tx.FeeDenom} {
+		if err := validateDenom(denomOf(denom)); err != nil {
+			return err
+		}
+	}
+	if tx.Type == TxTypeIssue && denomOf(tx.Denom) == BaseDenom {
+		return fmt.Errorf("基础代币 %s 只能由系统发行", BaseDenom)
+	}
+	if err := tx.LockCondition.validate(); err != nil {
 		return err
 	}
+	if tx.LockCondition.isSet() && tx.Type != TxTypeTransfer {
+		return fmt.Errorf("只有转账可以设置解锁条件")
+	}
 
-	wm.mu.Lock()
-	defer wm.mu.Unlock()
+	account, exists := accountOf(tx.From)
+	if !exists {
+		return fmt.Errorf("发送方钱包不存在")
+	}
 
-	// 扣除发送方余额
-	if tx.From != "system" {
-		if fromWallet, exists := wm.wallets[tx.From]; exists {
-			fromWallet.Balance -= (tx.Amount + tx.Fee)
+	if verifySignatures {
+		if err := wm.verifySignatures(tx); err != nil {
+			return err
 		}
 	}
+	if tx.ID != tx.Hash() {
+		return fmt.Errorf("交易ID与内容不匹配")
+	}
 
-	// 增加接收方余额
-	if toWallet, exists := wm.wallets[tx.To]; exists {
-		toWallet.Balance += tx.Amount
-	} else {
-		// 创建新钱包（只有地址）
-		wm.wallets[tx.To] = &Wallet{
-			Address: tx.To,
-			Balance: tx.Amount,
+	// 序号必须正好等于账户的下一个序号
+	if tx.Nonce < account.Nonce {
+		return fmt.Errorf("序号 %d 已被使用（下一个序号为 %d），交易可能被重放", tx.Nonce, account.Nonce)
+	}
+	if tx.Nonce > account.Nonce {
+		return fmt.Errorf("序号不连续：期望 %d，实际 %d", account.Nonce, tx.Nonce)
+	}
+
+	// 发行交易新增代币，发送方只需支付手续费；金额和手续费是同一种代币时合并检查
+	required := Coins{}
+	required.add(tx.FeeDenom, tx.Fee)
+	if tx.Type == TxTypeTransfer {
+		total, err := addAmounts(required.AmountOf(tx.Denom), tx.Amount)
+		if err != nil {
+			return fmt.Errorf("转账金额与手续费之和超出范围")
+		}
+		required[denomOf(tx.Denom)] = total
+	}
+	for _, denom := range required.Denoms() {
+		if balance := account.amountOf(denom); balance < required[denom] {
+			return fmt.Errorf("余额不足：需要 %d %s，实际 %d", required[denom], denom, balance)
 		}
 	}
 
 	return nil
 }
 
-// CreateTransaction 创建交易
-func (wm *WalletManager) CreateTransaction(from, to string, amount, fee int64) *Transaction {
-	txData := fmt.Sprintf("%s%s%d%d", from, to, amount, fee)
-	hash := sha256.Sum256([]byte(txData))
-	
-	return &Transaction{
-		ID:        hex.EncodeToString(hash[:])[:16],
+// verifySignatures 验证交易的签名：公钥必须属于发送方，且签名必须能用该公钥验证；
+// 多签账户需要达到门限数量的成员签名
+func (wm *WalletManager) verifySignatures(tx *Transaction) error {
+	if tx.Multisig != nil {
+		return verifyMultisig(tx)
+	}
+	if tx.PublicKey == "" || tx.Signature == "" {
+		return fmt.Errorf("交易缺少公钥或签名")
+	}
+	if wm.generateAddress(tx.PublicKey) != tx.From {
+		return fmt.Errorf("公钥与发送方地址不匹配")
+	}
+	if err := VerifySignature(tx.PublicKey, tx.SignBytes(), tx.Signature); err != nil {
+		return fmt.Errorf("交易签名无效: %v", err)
+	}
+	return nil
+}
+
+// ProcessTransaction 处理交易
+func (wm *WalletManager) ProcessTransaction(tx *Transaction) error {
+	return wm.ApplyTransactions([]*Transaction{tx})
+}
+
+// CreateTransaction 创建使用指定序号的基础代币转账，并用发送方钱包的私钥签名
+func (wm *WalletManager) CreateTransaction(from, to string, amount, fee int64, nonce uint64) (*Transaction, error) {
+	return wm.CreateCoinTransaction(TxTypeTransfer, from, to, Coin{Denom: BaseDenom, Amount: amount}, Coin{Denom: BaseDenom, Amount: fee}, nonce, LockCondition{})
+}
+
+// CreateCoinTransaction 创建指定代币的转账或发行交易，并用发送方钱包的私钥签名
+func (wm *WalletManager) CreateCoinTransaction(txType, from, to string, amount, fee Coin, nonce uint64, lock LockCondition) (*Transaction, error) {
+	tx := &Transaction{
+		Type:      txType,
 		From:      from,
 		To:        to,
-		Amount:    amount,
-		Fee:       fee,
+		Amount:    amount.Amount,
+		Denom:     txDenom(amount.Denom),
+		Fee:       fee.Amount,
+		FeeDenom:  txDenom(fee.Denom),
+		Nonce:     nonce,
 		Timestamp: time.Now().Unix(),
-		Signature: wm.signTransaction(txData),
+		ChainID:   wm.chainID,
+
+		LockCondition: lock,
 	}
-}
+	tx.ID = tx.Hash()
 
-// 生成私钥
-func (wm *WalletManager) generatePrivateKey() string {
-	key := make([]byte, 32)
-	rand.Read(key)
-	return hex.EncodeToString(key)
+	if err := wm.signTransaction(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
 }
 
-// 生成公钥
-func (wm *WalletManager) generatePublicKey(privateKey string) string {
-	hash := sha256.Sum256([]byte(privateKey + "public"))
-	return hex.EncodeToString(hash[:])
+// NewDataTransaction 创建只携带数据、不涉及余额变动的交易
+func NewDataTransaction(data string) *Transaction {
+	tx := &Transaction{
+		Type:      TxTypeData,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	}
+	tx.ID = tx.Hash()
+	return tx
 }
 
 // 生成地址
 func (wm *WalletManager) generateAddress(publicKey string) string {
-	hash := sha256.Sum256([]byte(publicKey))
-	return "cosmos" + hex.EncodeToString(hash[:])[:20]
+	return addressOf(publicKey)
 }
 
-// 签名交易（简化版）
-func (wm *WalletManager) signTransaction(data string) string {
-	hash := sha256.Sum256([]byte(data + "signature"))
-	return hex.EncodeToString(hash[:])[:32]
+// 使用发送方私钥签名交易
+func (wm *WalletManager) signTransaction(tx *Transaction) error {
+	publicKey, privateKey, exists := wm.signingKey(tx.From)
+	if !exists {
+		return fmt.Errorf("发送方钱包不存在")
+	}
+	if privateKey == "" {
+		return fmt.Errorf("发送方钱包没有私钥或尚未解锁，无法签名")
+	}
+
+	signature, err := SignBytes(privateKey, tx.SignBytes())
+	if err != nil {
+		return err
+	}
+
+	tx.PublicKey = publicKey
+	tx.Signature = signature
+	return nil
 } 
\ No newline at end of file
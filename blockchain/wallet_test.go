@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateTransactionVerifiesSignature(t *testing.T) {
+	wm := NewWalletManager()
+	alice, err := wm.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := wm.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wm.UpdateBalance(alice.Address, 1000)
+
+	tx, err := wm.CreateTransaction(alice.Address, bob.Address, 10, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wm.ValidateTransaction(tx); err != nil {
+		t.Fatalf("合法交易验证失败: %v", err)
+	}
+
+	// 篡改金额后签名不再有效
+	tampered := *tx
+	tampered.Amount = 500
+	if err := wm.ValidateTransaction(&tampered); err == nil {
+		t.Fatal("篡改后的交易不应通过验证")
+	}
+
+	// 用别人的密钥签名冒充发送方
+	forged := *tx
+	forged.PublicKey = bob.PublicKey
+	forged.Signature, _ = SignBytes(bob.PrivateKey, forged.SignBytes())
+	if err := wm.ValidateTransaction(&forged); err == nil {
+		t.Fatal("公钥与发送方不匹配的交易不应通过验证")
+	}
+
+	unsigned := *tx
+	unsigned.Signature = ""
+	if err := wm.ValidateTransaction(&unsigned); err == nil {
+		t.Fatal("未签名的交易不应通过验证")
+	}
+}
+
+func TestValidateTransactionEnforcesNonce(t *testing.T) {
+	wm := NewWalletManager()
+	alice, _ := wm.CreateWallet()
+	bob, _ := wm.CreateWallet()
+	wm.UpdateBalance(alice.Address, 1000)
+
+	first, _ := wm.CreateTransaction(alice.Address, bob.Address, 10, 1, 0)
+	same, _ := wm.CreateTransaction(alice.Address, bob.Address, 10, 1, 1)
+	if first.ID == same.ID {
+		t.Fatal("相同内容、不同序号的交易ID不应相同")
+	}
+
+	gap, _ := wm.CreateTransaction(alice.Address, bob.Address, 10, 1, 2)
+	if err := wm.ValidateTransaction(gap); err == nil || !strings.Contains(err.Error(), "不连续") {
+		t.Fatalf("跳过序号的交易应被拒绝，实际错误: %v", err)
+	}
+
+	if err := wm.ApplyTransactions([]*Transaction{first}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wm.ValidateTransaction(first); err == nil || !strings.Contains(err.Error(), "重放") {
+		t.Fatalf("重放的交易应被拒绝，实际错误: %v", err)
+	}
+	if err := wm.ValidateTransaction(same); err != nil {
+		t.Fatalf("使用下一个序号的交易应当有效: %v", err)
+	}
+
+	// 修改序号会使签名失效
+	replayed := *first
+	replayed.Nonce = 1
+	if err := wm.ValidateTransaction(&replayed); err == nil {
+		t.Fatal("修改序号后的交易不应通过验证")
+	}
+}
+
+func TestTransferRejectsAmountOverflow(t *testing.T) {
+	alice, err := NewWalletManager().newWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := newEconomicsNode(t, testGenesisDoc(alice.Address), alice)
+	bob := testAddress("bob")
+
+	// 金额加手续费超出int64范围时不能绕过余额检查
+	if _, err := node.Transfer(alice.Address, bob, math.MaxInt64, 2); err == nil {
+		t.Fatal("金额与手续费之和溢出的转账应被拒绝")
+	}
+	node.generateNewBlock()
+	if node.GetBalance(alice.Address) != 5000 || node.GetBalance(bob) != 0 {
+		t.Fatal("溢出的转账不应改变余额")
+	}
+}
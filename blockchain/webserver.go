@@ -1,12 +1,21 @@
 package blockchain
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 // WebServer 提供区块链的HTTP接口
@@ -17,6 +26,8 @@ type WebServer struct {
 	server   *http.Server
 	stopChan chan struct{}
 	mu       sync.Mutex
+
+	localSigning bool // 是否允许本机请求使用节点保存的私钥签名交易
 }
 
 // NewWebServer 创建一个新的Web服务器实例
@@ -29,6 +40,16 @@ func NewWebServer(node *Node, port int, webDir string) *WebServer {
 	}
 }
 
+// SetLocalSigning 设置是否开放由节点代为签名的接口（转账、发行、多签提议和签名、替换交易）
+//
+// 默认关闭，开启后也只接受来自本机的请求；其他情况下客户端应自行签名，
+// 再通过 /api/tx/broadcast 广播。
+func (ws *WebServer) SetLocalSigning(enabled bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.localSigning = enabled
+}
+
 // Start 启动Web服务器
 func (ws *WebServer) Start() error {
 	ws.mu.Lock()
@@ -45,20 +66,50 @@ func (ws *WebServer) Start() error {
 
 	// API 端点 - 使用CORS中间件包装
 	mux.HandleFunc("/api/chain/info", ws.corsMiddleware(ws.getChainInfoHandler))
+	mux.HandleFunc("/api/chain/validate", ws.corsMiddleware(ws.validateChainHandler))
+	mux.HandleFunc("/api/chain/reorgs", ws.corsMiddleware(ws.getReorgsHandler))
+	mux.HandleFunc("/api/chain/side", ws.corsMiddleware(ws.getSideBlocksHandler))
 	mux.HandleFunc("/api/blocks", ws.corsMiddleware(ws.getBlocksHandler))
 	mux.HandleFunc("/api/block", ws.corsMiddleware(ws.getBlockHandler))
 	mux.HandleFunc("/api/genesis", ws.corsMiddleware(ws.createGenesisHandler))
+	mux.HandleFunc("/api/supply", ws.corsMiddleware(ws.getSupplyHandler))
+	mux.HandleFunc("/api/denoms", ws.corsMiddleware(ws.getDenomsHandler))
 	mux.HandleFunc("/api/mining/start", ws.corsMiddleware(ws.startMiningHandler))
 	mux.HandleFunc("/api/mining/stop", ws.corsMiddleware(ws.stopMiningHandler))
 	mux.HandleFunc("/api/transaction", ws.corsMiddleware(ws.addTransactionHandler))
+	mux.HandleFunc("/api/tx", ws.corsMiddleware(ws.getReceiptHandler))
+	mux.HandleFunc("/api/tx/wait", ws.corsMiddleware(ws.waitTxHandler))
+	mux.HandleFunc("/api/tx/proof", ws.corsMiddleware(ws.getTxProofHandler))
+	mux.HandleFunc("/api/tx/failed", ws.corsMiddleware(ws.getFailedTransactionsHandler))
+	mux.HandleFunc("/api/tx/broadcast", ws.corsMiddleware(ws.broadcastTxHandler))
+	mux.HandleFunc("/api/tx/simulate", ws.corsMiddleware(ws.simulateTxHandler))
+	mux.HandleFunc("/api/mempool", ws.corsMiddleware(ws.getMempoolHandler))
+	mux.HandleFunc("/api/mempool/tx", ws.corsMiddleware(ws.getMempoolTxHandler))
+	mux.HandleFunc("/api/mempool/replace", ws.corsMiddleware(ws.localSigningOnly(ws.replaceTxHandler)))
+	mux.HandleFunc("/api/events/ws", ws.corsMiddleware(ws.eventsWebSocketHandler))
+	mux.HandleFunc("/api/events/sse", ws.corsMiddleware(ws.eventsSSEHandler))
 	
 	// 钱包相关API
 	mux.HandleFunc("/api/wallet/create", ws.corsMiddleware(ws.createWalletHandler))
 	mux.HandleFunc("/api/wallet/list", ws.corsMiddleware(ws.listWalletsHandler))
+	mux.HandleFunc("/api/wallet/import", ws.corsMiddleware(ws.importWalletHandler))
+	mux.HandleFunc("/api/wallet/export", ws.corsMiddleware(ws.exportWalletHandler))
+	mux.HandleFunc("/api/wallet/unlock", ws.corsMiddleware(ws.unlockWalletHandler))
+	mux.HandleFunc("/api/wallet/lock", ws.corsMiddleware(ws.lockWalletHandler))
+	mux.HandleFunc("/api/wallet/mnemonic", ws.corsMiddleware(ws.createHDWalletHandler))
+	mux.HandleFunc("/api/wallet/recover", ws.corsMiddleware(ws.recoverHDWalletsHandler))
 	mux.HandleFunc("/api/wallet/balance", ws.corsMiddleware(ws.getBalanceHandler))
-	mux.HandleFunc("/api/wallet/transfer", ws.corsMiddleware(ws.transferHandler))
+	mux.HandleFunc("/api/wallet/transfer", ws.corsMiddleware(ws.localSigningOnly(ws.transferHandler)))
+	mux.HandleFunc("/api/wallet/issue", ws.corsMiddleware(ws.localSigningOnly(ws.issueTokenHandler)))
 	mux.HandleFunc("/api/wallet/transactions", ws.corsMiddleware(ws.getTransactionsHandler))
 	mux.HandleFunc("/api/wallet/miner", ws.corsMiddleware(ws.getMinerInfoHandler))
+	
+	// 多签账户API
+	mux.HandleFunc("/api/multisig/create", ws.corsMiddleware(ws.createMultisigHandler))
+	mux.HandleFunc("/api/multisig/list", ws.corsMiddleware(ws.listMultisigsHandler))
+	mux.HandleFunc("/api/multisig/propose", ws.corsMiddleware(ws.localSigningOnly(ws.proposeMultisigHandler)))
+	mux.HandleFunc("/api/multisig/sign", ws.corsMiddleware(ws.localSigningOnly(ws.signMultisigHandler)))
+	mux.HandleFunc("/api/multisig/tx", ws.corsMiddleware(ws.getMultisigTxHandler))
 
 	addr := fmt.Sprintf(":%d", ws.port)
 	ws.server = &http.Server{
@@ -91,25 +142,114 @@ func (ws *WebServer) getChainInfoHandler(w http.ResponseWriter, r *http.Request)
 	info := struct {
 		Height        int    `json:"height"`
 		PendingTxCount int    `json:"pendingTxCount"`
+		StateHash     string `json:"stateHash"`
 		Status        string `json:"status"`
 	}{
 		Height:        ws.node.GetHeight(),
-		PendingTxCount: len(ws.node.pendingTransactions),
+		PendingTxCount: ws.node.GetMempoolStats().Size,
+		StateHash:     ws.node.GetStateHash(),
 		Status:        "运行中",
 	}
 
 	ws.sendJSONResponse(w, info)
 }
 
-// getBlocksHandler 返回所有区块
+// validateChainHandler 校验整条区块链
+func (ws *WebServer) validateChainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result := struct {
+		Valid         bool   `json:"valid"`
+		Height        int    `json:"height"`
+		InvalidHeight int    `json:"invalidHeight,omitempty"`
+		Error         string `json:"error,omitempty"`
+	}{
+		Valid:  true,
+		Height: ws.node.GetHeight(),
+	}
+
+	if err := ws.node.ValidateChain(); err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+		if validationErr, ok := err.(*ChainValidationError); ok {
+			result.InvalidHeight = validationErr.Height
+		}
+	}
+
+	ws.sendJSONResponse(w, result)
+}
+
+// getReorgsHandler 返回最近的链重组事件
+func (ws *WebServer) getReorgsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws.sendJSONResponse(w, ws.node.GetReorgEvents())
+}
+
+// getSupplyHandler 返回代币的流通量、发行量和销毁量，denom参数为空时返回基础代币
+func (ws *WebServer) getSupplyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	denom := r.URL.Query().Get("denom")
+	if denom == "" || denom == BaseDenom {
+		ws.sendJSONResponse(w, ws.node.GetSupply())
+		return
+	}
+	supply, err := ws.node.GetDenomSupply(denom)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ws.sendJSONResponse(w, supply)
+}
+
+// getDenomsHandler 返回所有发行代币的创建者和流通情况
+func (ws *WebServer) getDenomsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws.sendJSONResponse(w, ws.node.GetDenoms())
+}
+
+// getSideBlocksHandler 返回不在主链上的分支区块
+func (ws *WebServer) getSideBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws.sendJSONResponse(w, ws.node.GetSideBlocks())
+}
+
+// getBlocksHandler 分页返回主链上的区块，查询参数见 parsePageQuery
 func (ws *WebServer) getBlocksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
 		return
 	}
 
-	blocks := ws.node.GetAllBlocks()
-	ws.sendJSONResponse(w, blocks)
+	query, err := ws.parsePageQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := ws.node.QueryBlocks(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, page)
 }
 
 // getBlockHandler 返回特定高度的区块
@@ -140,10 +280,20 @@ func (ws *WebServer) getBlockHandler(w http.ResponseWriter, r *http.Request) {
 	ws.sendJSONResponse(w, block)
 }
 
-// createGenesisHandler 创建创世区块
+// createGenesisHandler 创建创世区块（POST），或获取链参数和创世区块哈希（GET）
 func (ws *WebServer) createGenesisHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		ws.sendJSONResponse(w, struct {
+			Genesis     *GenesisDoc `json:"genesis"`
+			GenesisHash string      `json:"genesis_hash"`
+		}{
+			Genesis:     ws.node.GetGenesisDoc(),
+			GenesisHash: ws.node.GetGenesisHash(),
+		})
+		return
+	}
 	if r.Method != http.MethodPost {
-		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		http.Error(w, "只支持GET和POST方法", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -238,7 +388,10 @@ func (ws *WebServer) addTransactionHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	ws.node.AddTransaction(request.Data)
+	if err := ws.node.AddTransaction(request.Data); err != nil {
+		http.Error(w, "添加交易失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	ws.sendJSONResponse(w, struct {
 		Success bool   `json:"success"`
@@ -249,94 +402,220 @@ func (ws *WebServer) addTransactionHandler(w http.ResponseWriter, r *http.Reques
 	})
 }
 
-// 辅助函数: 发送JSON响应
-func (ws *WebServer) sendJSONResponse(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("发送JSON响应失败: %v", err)
-		http.Error(w, "内部服务器错误", http.StatusInternalServerError)
+// getReceiptHandler 返回交易的回执：状态（pending/included/failed）、所在区块和位置、手续费或失败原因
+func (ws *WebServer) getReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	txID := r.URL.Query().Get("id")
+	if txID == "" {
+		http.Error(w, "缺少id参数", http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := ws.node.GetReceipt(txID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
+	ws.sendJSONResponse(w, receipt)
 }
 
-// 辅助函数: 解析JSON请求体
-func (ws *WebServer) decodeJSONBody(r *http.Request, dst interface{}) error {
-	if r.Header.Get("Content-Type") != "application/json" {
-		return fmt.Errorf("需要Content-Type: application/json")
+// waitTxHandler 长轮询：等待交易上链或失败后返回回执
+//
+// timeout参数是最长等待的秒数（默认30，最大120），超时后返回状态仍为pending的回执，客户端可以再次请求。
+func (ws *WebServer) waitTxHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
 	}
 
-	return json.NewDecoder(r.Body).Decode(dst)
+	txID := r.URL.Query().Get("id")
+	if txID == "" {
+		http.Error(w, "缺少id参数", http.StatusBadRequest)
+		return
+	}
+	timeout := 30
+	if s := r.URL.Query().Get("timeout"); s != "" {
+		var err error
+		if timeout, err = strconv.Atoi(s); err != nil || timeout < 0 || timeout > 120 {
+			http.Error(w, "timeout必须是0到120之间的秒数", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	receipt, err := ws.node.WaitForTransaction(ctx, txID)
+	if receipt == nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ws.sendJSONResponse(w, receipt)
 }
 
-// 钱包相关API处理函数
+// getTxProofHandler 返回交易的Merkle包含证明
+func (ws *WebServer) getTxProofHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
 
-// createWalletHandler 创建新钱包
-func (ws *WebServer) createWalletHandler(w http.ResponseWriter, r *http.Request) {
+	txID := r.URL.Query().Get("id")
+	if txID == "" {
+		http.Error(w, "缺少id参数", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := ws.node.GetTransactionProof(txID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ws.sendJSONResponse(w, proof)
+}
+
+// broadcastTxHandler 接受客户端签好名的交易，放入交易池并广播给其他节点
+//
+// 请求中的交易可以是规范编码的十六进制（tx_bytes），也可以是JSON对象（tx）。
+func (ws *WebServer) broadcastTxHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
 		return
 	}
 
-	wallet := ws.node.CreateWallet()
-	
+	tx, err := ws.decodeTxRequest(r)
+	if err != nil {
+		http.Error(w, "无效的交易: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ws.node.SubmitSignedTransaction(tx); err != nil {
+		http.Error(w, "广播交易失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ws.sendJSONResponse(w, struct {
 		Success bool   `json:"success"`
-		Message string `json:"message"`
-		Wallet  *Wallet `json:"wallet"`
+		TxID    string `json:"tx_id"`
 	}{
 		Success: true,
-		Message: "钱包创建成功",
-		Wallet:  wallet,
+		TxID:    tx.ID,
 	})
 }
 
-// listWalletsHandler 获取所有钱包列表
-func (ws *WebServer) listWalletsHandler(w http.ResponseWriter, r *http.Request) {
+// simulateTxHandler 模拟执行交易（可以不带签名），返回签名字节、手续费和执行后的余额
+func (ws *WebServer) simulateTxHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tx, err := ws.decodeTxRequest(r)
+	if err != nil {
+		http.Error(w, "无效的交易: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := ws.node.SimulateTransaction(tx)
+	if err != nil {
+		http.Error(w, "模拟执行失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, result)
+}
+
+// 辅助函数: 从请求中解析交易，tx_bytes是规范编码的十六进制，tx是JSON对象（ID为空时自动计算）
+func (ws *WebServer) decodeTxRequest(r *http.Request) (*Transaction, error) {
+	var request struct {
+		TxBytes string          `json:"tx_bytes"`
+		Tx      json.RawMessage `json:"tx"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		return nil, err
+	}
+
+	var tx *Transaction
+	switch {
+	case request.TxBytes != "":
+		data, err := hex.DecodeString(request.TxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("tx_bytes不是有效的十六进制")
+		}
+		if tx, err = DecodeTransaction(data); err != nil {
+			return nil, err
+		}
+	case len(request.Tx) > 0:
+		decoder := json.NewDecoder(bytes.NewReader(request.Tx))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&tx); err != nil || tx == nil {
+			return nil, fmt.Errorf("tx不是有效的交易对象")
+		}
+		if tx.ID == "" {
+			tx.ID = tx.Hash()
+		}
+	default:
+		return nil, fmt.Errorf("需要tx_bytes或tx")
+	}
+
+	if err := ValidateAddress(tx.From); err != nil {
+		return nil, fmt.Errorf("from无效: %v", err)
+	}
+	if err := ValidateAddress(tx.To); err != nil {
+		return nil, fmt.Errorf("to无效: %v", err)
+	}
+	return tx, nil
+}
+
+// getMempoolHandler 返回交易池统计信息和按打包优先级排列的交易
+func (ws *WebServer) getMempoolHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
 		return
 	}
 
-	wallets := ws.node.GetAllWallets()
-	ws.sendJSONResponse(w, wallets)
+	ws.sendJSONResponse(w, struct {
+		Stats        MempoolStats    `json:"stats"`
+		Transactions []*MempoolEntry `json:"transactions"`
+	}{
+		Stats:        ws.node.GetMempoolStats(),
+		Transactions: ws.node.GetMempoolEntries(),
+	})
 }
 
-// getBalanceHandler 获取钱包余额
-func (ws *WebServer) getBalanceHandler(w http.ResponseWriter, r *http.Request) {
+// getMempoolTxHandler 返回交易池中的单笔交易
+func (ws *WebServer) getMempoolTxHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
 		return
 	}
 
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		http.Error(w, "缺少address参数", http.StatusBadRequest)
+	txID := r.URL.Query().Get("id")
+	if txID == "" {
+		http.Error(w, "缺少id参数", http.StatusBadRequest)
 		return
 	}
 
-	balance := ws.node.GetBalance(address)
-	
-	ws.sendJSONResponse(w, struct {
-		Address string `json:"address"`
-		Balance int64  `json:"balance"`
-	}{
-		Address: address,
-		Balance: balance,
-	})
+	entry := ws.node.GetMempoolEntry(txID)
+	if entry == nil {
+		http.Error(w, "交易不在交易池中", http.StatusNotFound)
+		return
+	}
+
+	ws.sendJSONResponse(w, entry)
 }
 
-// transferHandler 处理转账请求
-func (ws *WebServer) transferHandler(w http.ResponseWriter, r *http.Request) {
+// replaceTxHandler 用更高的手续费替换交易池中的转账
+func (ws *WebServer) replaceTxHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var request struct {
-		From   string `json:"from"`
-		To     string `json:"to"`
-		Amount int64  `json:"amount"`
+		ID  string `json:"id"`
+		Fee int64  `json:"fee"`
 	}
 
 	if err := ws.decodeJSONBody(r, &request); err != nil {
@@ -344,76 +623,912 @@ func (ws *WebServer) transferHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if request.From == "" || request.To == "" || request.Amount <= 0 {
-		http.Error(w, "转账参数无效", http.StatusBadRequest)
+	if request.ID == "" || request.Fee <= 0 {
+		http.Error(w, "替换参数无效", http.StatusBadRequest)
 		return
 	}
 
-	err := ws.node.Transfer(request.From, request.To, request.Amount)
+	tx, err := ws.node.ReplaceTransaction(request.ID, request.Fee)
 	if err != nil {
-		http.Error(w, "转账失败: "+err.Error(), http.StatusBadRequest)
+		http.Error(w, "替换失败: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	ws.sendJSONResponse(w, struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
+		Success       bool   `json:"success"`
+		Message       string `json:"message"`
+		TransactionID string `json:"transaction_id"`
 	}{
-		Success: true,
-		Message: "转账成功",
+		Success:       true,
+		Message:       "交易已替换，等待打包进区块",
+		TransactionID: tx.ID,
 	})
 }
 
-// getTransactionsHandler 获取交易历史
-func (ws *WebServer) getTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+// 事件订阅的连接参数
+const (
+	eventWriteTimeout = 10 * time.Second // 单次写入事件的最长时间，写不出去的连接被断开
+	eventPingInterval = 30 * time.Second // 空闲时发送心跳的间隔
+)
+
+// eventUpgrader 与其他接口一样允许跨域订阅
+var eventUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// eventsWebSocketHandler 通过WebSocket推送节点事件，每个事件是一条JSON文本消息
+//
+// 查询参数见 subscribeEvents。客户端跟不上时连接以1013（稍后重试）关闭，
+// 可以带上since参数重连补发遗漏的事件。
+func (ws *WebServer) eventsWebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
 		return
 	}
 
-	transactions := ws.node.GetTransactions()
-	ws.sendJSONResponse(w, transactions)
+	sub, ok := ws.subscribeEvents(w, r, r.URL.Query().Get("since"))
+	if !ok {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	conn, err := eventUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 已经返回了错误响应
+		return
+	}
+	defer conn.Close()
+
+	// 客户端不需要发送消息，读协程只处理控制帧并发现连接断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(eventPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				code := websocket.CloseGoingAway
+				if sub.Err() == ErrSlowSubscriber {
+					code = websocket.CloseTryAgainLater
+				}
+				reason := ""
+				if err := sub.Err(); err != nil {
+					reason = err.Error()
+				}
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(eventWriteTimeout))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
 }
 
-// getMinerInfoHandler 获取矿工信息
-func (ws *WebServer) getMinerInfoHandler(w http.ResponseWriter, r *http.Request) {
+// eventsSSEHandler 通过Server-Sent Events推送节点事件
+//
+// 每个事件的id是Seq，event是事件类型，data是JSON。订阅被节点结束时先发送一个close事件；
+// 浏览器的EventSource断线后会带上Last-Event-ID自动重连，节点补发遗漏的事件。
+func (ws *WebServer) eventsSSEHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
 		return
 	}
 
-	minerAddress := ws.node.GetMinerAddress()
-	minerBalance := ws.node.GetBalance(minerAddress)
-	miningStats := ws.node.GetMiningStats()
+	since := r.URL.Query().Get("since")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		since = id
+	}
+	sub, ok := ws.subscribeEvents(w, r, since)
+	if !ok {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
 
-	ws.sendJSONResponse(w, struct {
-		Address string                 `json:"address"`
-		Balance int64                  `json:"balance"`
-		Stats   map[string]interface{} `json:"stats"`
-	}{
-		Address: minerAddress,
-		Balance: minerBalance,
-		Stats:   miningStats,
-	})
+	ping := time.NewTicker(eventPingInterval)
+	defer ping.Stop()
+	for {
+		var message string
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				reason := ""
+				if err := sub.Err(); err != nil {
+					reason = err.Error()
+				}
+				rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+				fmt.Fprintf(w, "event: close\ndata: %s\n\n", reason)
+				rc.Flush()
+				return
+			}
+			data, _ := json.Marshal(event)
+			message = fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+		case <-ping.C:
+			message = ": ping\n\n"
+		case <-r.Context().Done():
+			return
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		if _, err := fmt.Fprint(w, message); err != nil || rc.Flush() != nil {
+			return
+		}
+	}
 }
 
-// corsMiddleware CORS中间件，允许跨域请求
-func (ws *WebServer) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// 设置CORS头
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "86400")
+// subscribeEvents 按查询参数订阅节点事件，参数无效时返回错误响应并返回false
+//
+// 查询参数：types是逗号分隔的事件类型，address只接收与该地址有关的交易和区块，
+// since补发该序号之后的事件，buffer是缓冲的事件数（默认64，最大1024）。
+func (ws *WebServer) subscribeEvents(w http.ResponseWriter, r *http.Request, since string) (*Subscription, bool) {
+	query := r.URL.Query()
+	var filter EventFilter
+	if types := query.Get("types"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+	if address := query.Get("address"); address != "" {
+		if !ws.checkAddress(w, "address", address) {
+			return nil, false
+		}
+		filter.Address = address
+	}
 
-		// 处理预检请求
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+	var seq uint64
+	if since != "" {
+		var err error
+		if seq, err = strconv.ParseUint(since, 10, 64); err != nil {
+			http.Error(w, "since必须是事件序号", http.StatusBadRequest)
+			return nil, false
 		}
+	}
+	buffer := DefaultEventBuffer
+	if s := query.Get("buffer"); s != "" {
+		var err error
+		if buffer, err = strconv.Atoi(s); err != nil || buffer < 1 || buffer > 1024 {
+			http.Error(w, "buffer必须是1到1024之间的整数", http.StatusBadRequest)
+			return nil, false
+		}
+	}
 
-		// 调用实际的处理函数
-		next(w, r)
+	sub, err := ws.node.SubscribeEvents(filter, seq, buffer)
+	if err == ErrEventBusClosed {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return sub, true
+}
+
+// 辅助函数: 发送JSON响应
+func (ws *WebServer) sendJSONResponse(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("发送JSON响应失败: %v", err)
+		http.Error(w, "内部服务器错误", http.StatusInternalServerError)
+	}
+}
+
+// 辅助函数: 解析JSON请求体
+func (ws *WebServer) decodeJSONBody(r *http.Request, dst interface{}) error {
+	if r.Header.Get("Content-Type") != "application/json" {
+		return fmt.Errorf("需要Content-Type: application/json")
+	}
+
+	return json.NewDecoder(r.Body).Decode(dst)
+}
+
+// 辅助函数: 解析分页查询参数
+//
+// from_height、to_height是高度范围，since、until是区块时间范围（Unix秒），都是闭区间；
+// address按发送方或接收方过滤交易；order是asc或desc；limit是每页条数；
+// offset跳过的条数，或cursor上一页返回的next_cursor。
+func (ws *WebServer) parsePageQuery(r *http.Request) (PageQuery, error) {
+	values := r.URL.Query()
+	query := PageQuery{
+		Address: values.Get("address"),
+		Order:   values.Get("order"),
+		Cursor:  values.Get("cursor"),
+	}
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"from_height", &query.FromHeight},
+		{"to_height", &query.ToHeight},
+		{"offset", &query.Offset},
+		{"limit", &query.Limit},
+	}
+	for _, param := range ints {
+		if s := values.Get(param.name); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return query, fmt.Errorf("%s参数必须是整数", param.name)
+			}
+			*param.dst = v
+		}
+	}
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"since", &query.Since},
+		{"until", &query.Until},
+	}
+	for _, param := range times {
+		if s := values.Get(param.name); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return query, fmt.Errorf("%s参数必须是Unix时间（秒）", param.name)
+			}
+			*param.dst = time.Unix(v, 0)
+		}
+	}
+	if !query.Until.IsZero() {
+		// 截止时间包括这一整秒内的区块
+		query.Until = query.Until.Add(time.Second - time.Nanosecond)
+	}
+	return query, nil
+}
+
+// 辅助函数: 检查请求中的地址，无效时返回400错误并返回false
+func (ws *WebServer) checkAddress(w http.ResponseWriter, name, address string) bool {
+	if err := ValidateAddress(address); err != nil {
+		http.Error(w, name+"参数无效: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// 钱包相关API处理函数
+
+// createWalletHandler 创建新钱包，私钥用请求中的口令加密保存到密钥库，响应中不包含私钥
+func (ws *WebServer) createWalletHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Passphrase == "" {
+		http.Error(w, "缺少passphrase参数", http.StatusBadRequest)
+		return
+	}
+
+	wallet, err := ws.node.CreateEncryptedWallet(request.Passphrase)
+	if err != nil {
+		http.Error(w, "创建钱包失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	
+	ws.sendJSONResponse(w, struct {
+		Success bool        `json:"success"`
+		Message string      `json:"message"`
+		Wallet  *WalletInfo `json:"wallet"`
+	}{
+		Success: true,
+		Message: "钱包创建成功",
+		Wallet:  wallet,
+	})
+}
+
+// listWalletsHandler 获取所有钱包列表（不包含私钥）
+func (ws *WebServer) listWalletsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws.sendJSONResponse(w, ws.node.ListWallets())
+}
+
+// importWalletHandler 导入密钥库文件，口令用于校验文件，导入后钱包处于锁定状态
+func (ws *WebServer) importWalletHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Keystore   *KeystoreFile `json:"keystore"`
+		Passphrase string        `json:"passphrase"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Keystore == nil || request.Passphrase == "" {
+		http.Error(w, "需要keystore和passphrase", http.StatusBadRequest)
+		return
+	}
+
+	wallet, err := ws.node.ImportKeystore(request.Keystore, request.Passphrase)
+	if err != nil {
+		http.Error(w, "导入钱包失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, wallet)
+}
+
+// exportWalletHandler 导出钱包的密钥库文件，私钥保持加密
+func (ws *WebServer) exportWalletHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "缺少address参数", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkAddress(w, "address", address) {
+		return
+	}
+
+	file, err := ws.node.ExportKeystore(address)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ws.sendJSONResponse(w, file)
+}
+
+// unlockWalletHandler 用口令解锁钱包，duration（秒）大于0时到期后自动锁定
+func (ws *WebServer) unlockWalletHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Address    string `json:"address"`
+		Passphrase string `json:"passphrase"`
+		Duration   int64  `json:"duration"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Address == "" || request.Passphrase == "" || request.Duration < 0 {
+		http.Error(w, "需要address和passphrase，duration不能为负", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkAddress(w, "address", request.Address) {
+		return
+	}
+
+	if err := ws.node.UnlockWallet(request.Address, request.Passphrase, time.Duration(request.Duration)*time.Second); err != nil {
+		http.Error(w, "解锁钱包失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}{
+		Success: true,
+		Message: "钱包已解锁",
+	})
+}
+
+// lockWalletHandler 锁定钱包，清除内存中的私钥
+func (ws *WebServer) lockWalletHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Address string `json:"address"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Address == "" {
+		http.Error(w, "缺少address参数", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkAddress(w, "address", request.Address) {
+		return
+	}
+
+	if err := ws.node.LockWallet(request.Address); err != nil {
+		http.Error(w, "锁定钱包失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}{
+		Success: true,
+		Message: "钱包已锁定",
+	})
+}
+
+// createHDWalletHandler 生成新的助记词并创建它派生的第一个账户
+//
+// 助记词只在这个响应中返回一次，节点不保存，需要用户自行备份。
+func (ws *WebServer) createHDWalletHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Passphrase == "" {
+		http.Error(w, "缺少passphrase参数", http.StatusBadRequest)
+		return
+	}
+
+	mnemonic, wallet, err := ws.node.CreateHDWallet(request.Passphrase)
+	if err != nil {
+		http.Error(w, "创建钱包失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ws.sendJSONResponse(w, struct {
+		Mnemonic string      `json:"mnemonic"`
+		Wallet   *WalletInfo `json:"wallet"`
+	}{
+		Mnemonic: mnemonic,
+		Wallet:   wallet,
+	})
+}
+
+// recoverHDWalletsHandler 从助记词恢复从start开始的count个账户（count默认为1），恢复的钱包保持锁定
+func (ws *WebServer) recoverHDWalletsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Mnemonic   string `json:"mnemonic"`
+		Passphrase string `json:"passphrase"`
+		Start      uint32 `json:"start"`
+		Count      uint32 `json:"count"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Mnemonic == "" || request.Passphrase == "" {
+		http.Error(w, "需要mnemonic和passphrase", http.StatusBadRequest)
+		return
+	}
+	if request.Count == 0 {
+		request.Count = 1
+	}
+
+	wallets, err := ws.node.RecoverHDWallets(request.Mnemonic, request.Passphrase, request.Start, request.Count)
+	if err != nil {
+		http.Error(w, "恢复钱包失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, wallets)
+}
+
+// getBalanceHandler 获取钱包余额，balance是denom参数指定的代币（默认基础代币）的可用余额，
+// coins包含所有代币的可用余额，locked和locks是尚未解锁的资金
+func (ws *WebServer) getBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "缺少address参数", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkAddress(w, "address", address) {
+		return
+	}
+	denom := denomOf(r.URL.Query().Get("denom"))
+
+	coins := ws.node.GetCoins(address)
+	locks := ws.node.GetLocked(address)
+	locked := lockedCoins(locks)
+	
+	ws.sendJSONResponse(w, struct {
+		Address string        `json:"address"`
+		Denom   string        `json:"denom"`
+		Balance int64         `json:"balance"`
+		Locked  int64         `json:"locked"`
+		Total   int64         `json:"total"`
+		Coins   Coins         `json:"coins"`
+		Locks   []LockedFunds `json:"locks"`
+		Nonce   uint64        `json:"nonce"`
+	}{
+		Address: address,
+		Denom:   denom,
+		Balance: coins.AmountOf(denom),
+		Locked:  locked.AmountOf(denom),
+		Total:   coins.AmountOf(denom) + locked.AmountOf(denom),
+		Coins:   coins,
+		Locks:   locks,
+		Nonce:   ws.node.GetNonce(address),
+	})
+}
+
+// transferHandler 处理转账请求
+func (ws *WebServer) transferHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		From     string `json:"from"`
+		To       string `json:"to"`
+		Amount   int64  `json:"amount"`
+		Denom    string `json:"denom"`     // 可选，默认转账基础代币
+		Fee      int64  `json:"fee"`       // 可选，默认使用链参数中的默认手续费
+		FeeDenom string `json:"fee_denom"` // 可选，默认用基础代币支付手续费
+
+		UnlockHeight int   `json:"unlock_height"` // 可选，接收方在这个高度之前不能使用这笔资金
+		UnlockTime   int64 `json:"unlock_time"`   // 可选，接收方在这个Unix时间之前不能使用这笔资金
+	}
+
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.From == "" || request.To == "" || request.Amount <= 0 || request.Fee < 0 {
+		http.Error(w, "转账参数无效", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkAddress(w, "from", request.From) || !ws.checkAddress(w, "to", request.To) {
+		return
+	}
+	if request.Fee == 0 {
+		request.Fee = ws.node.DefaultFee()
+	}
+
+	amount := Coin{Denom: denomOf(request.Denom), Amount: request.Amount}
+	fee := Coin{Denom: denomOf(request.FeeDenom), Amount: request.Fee}
+	lock := LockCondition{UnlockHeight: request.UnlockHeight, UnlockTime: request.UnlockTime}
+	tx, err := ws.node.TransferLocked(request.From, request.To, amount, fee, lock)
+	if err != nil {
+		http.Error(w, "转账失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws.sendJSONResponse(w, struct {
+		Success       bool   `json:"success"`
+		Message       string `json:"message"`
+		TransactionID string `json:"transaction_id"`
+	}{
+		Success:       true,
+		Message:       "转账已提交，等待打包进区块",
+		TransactionID: tx.ID,
+	})
+}
+
+// issueTokenHandler 由创建者发行代币
+func (ws *WebServer) issueTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Creator string `json:"creator"`
+		To      string `json:"to"` // 可选，默认发行给创建者自己
+		Denom   string `json:"denom"`
+		Amount  int64  `json:"amount"`
+		Fee     int64  `json:"fee"` // 可选，默认使用链参数中的默认手续费
+	}
+
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if request.Creator == "" || request.Denom == "" || request.Amount <= 0 || request.Fee < 0 {
+		http.Error(w, "发行参数无效", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkAddress(w, "creator", request.Creator) || (request.To != "" && !ws.checkAddress(w, "to", request.To)) {
+		return
+	}
+	if request.Fee == 0 {
+		request.Fee = ws.node.DefaultFee()
+	}
+
+	tx, err := ws.node.IssueToken(request.Creator, request.To, request.Denom, request.Amount, request.Fee)
+	if err != nil {
+		http.Error(w, "发行失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws.sendJSONResponse(w, struct {
+		Success       bool   `json:"success"`
+		Message       string `json:"message"`
+		TransactionID string `json:"transaction_id"`
+	}{
+		Success:       true,
+		Message:       "发行已提交，等待打包进区块",
+		TransactionID: tx.ID,
+	})
+}
+
+// getTransactionsHandler 分页获取交易历史，可以按地址过滤，查询参数见 parsePageQuery
+func (ws *WebServer) getTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := ws.parsePageQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := ws.node.QueryTransactions(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, page)
+}
+
+// getFailedTransactionsHandler 获取打包失败的交易及原因
+func (ws *WebServer) getFailedTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws.sendJSONResponse(w, ws.node.GetFailedTransactions())
+}
+
+// getMinerInfoHandler 获取矿工信息
+func (ws *WebServer) getMinerInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	minerAddress := ws.node.GetMinerAddress()
+	minerBalance := ws.node.GetBalance(minerAddress)
+	miningStats := ws.node.GetMiningStats()
+
+	ws.sendJSONResponse(w, struct {
+		Address string                 `json:"address"`
+		Balance int64                  `json:"balance"`
+		Stats   map[string]interface{} `json:"stats"`
+	}{
+		Address: minerAddress,
+		Balance: minerBalance,
+		Stats:   miningStats,
+	})
+}
+
+// createMultisigHandler 由公钥和门限创建多签账户
+func (ws *WebServer) createMultisigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		PubKeys   []string `json:"pub_keys"`
+		Threshold int      `json:"threshold"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	account, err := ws.node.CreateMultisig(request.PubKeys, request.Threshold)
+	if err != nil {
+		http.Error(w, "创建多签账户失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, account)
+}
+
+// listMultisigsHandler 返回所有已登记的多签账户
+func (ws *WebServer) listMultisigsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws.sendJSONResponse(w, ws.node.GetMultisigs())
+}
+
+// proposeMultisigHandler 发起从多签账户转出的交易，返回的交易ID用于收集签名
+func (ws *WebServer) proposeMultisigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		From     string `json:"from"`
+		To       string `json:"to"`
+		Amount   int64  `json:"amount"`
+		Denom    string `json:"denom"`
+		Fee      int64  `json:"fee"` // 可选，默认使用链参数中的默认手续费
+		FeeDenom string `json:"fee_denom"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.From == "" || request.To == "" || request.Amount <= 0 || request.Fee < 0 {
+		http.Error(w, "转账参数无效", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkAddress(w, "from", request.From) || !ws.checkAddress(w, "to", request.To) {
+		return
+	}
+	if request.Fee == 0 {
+		request.Fee = ws.node.DefaultFee()
+	}
+
+	amount := Coin{Denom: denomOf(request.Denom), Amount: request.Amount}
+	fee := Coin{Denom: denomOf(request.FeeDenom), Amount: request.Fee}
+	proposal, err := ws.node.ProposeMultisigTransfer(request.From, request.To, amount, fee)
+	if err != nil {
+		http.Error(w, "发起多签交易失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, proposal)
+}
+
+// signMultisigHandler 为多签交易添加成员签名
+//
+// 请求中只给出signer时由该本地钱包签名；也可以直接提交pub_key和signature（对交易签名字节的签名）。
+func (ws *WebServer) signMultisigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持POST方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		TxID      string `json:"tx_id"`
+		Signer    string `json:"signer"`
+		PubKey    string `json:"pub_key"`
+		Signature string `json:"signature"`
+	}
+	if err := ws.decodeJSONBody(r, &request); err != nil {
+		http.Error(w, "无效的请求数据: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.TxID == "" || (request.Signer == "" && (request.PubKey == "" || request.Signature == "")) {
+		http.Error(w, "需要tx_id，以及signer或pub_key和signature", http.StatusBadRequest)
+		return
+	}
+	if request.Signer != "" && !ws.checkAddress(w, "signer", request.Signer) {
+		return
+	}
+
+	proposal, err := ws.node.SignMultisigTransaction(request.TxID, request.Signer, request.PubKey, request.Signature)
+	if err != nil {
+		http.Error(w, "签名失败: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws.sendJSONResponse(w, proposal)
+}
+
+// getMultisigTxHandler 返回多签交易的签名进度
+func (ws *WebServer) getMultisigTxHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持GET方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	proposal := ws.node.GetMultisigProposal(r.URL.Query().Get("id"))
+	if proposal == nil {
+		http.Error(w, "多签交易不存在", http.StatusNotFound)
+		return
+	}
+	ws.sendJSONResponse(w, proposal)
+}
+
+// corsMiddleware CORS中间件，允许跨域请求
+func (ws *WebServer) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 设置CORS头
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+
+		// 处理预检请求
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// 调用实际的处理函数
+		next(w, r)
+	}
+}
+
+// localSigningOnly 只在开启了本机签名、且请求来自本机时调用由节点代为签名的处理函数
+func (ws *WebServer) localSigningOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws.mu.Lock()
+		enabled := ws.localSigning
+		ws.mu.Unlock()
+
+		if !enabled || !isLocalRequest(r) {
+			http.Error(w, "节点未开放代为签名，请在客户端签名后通过 /api/tx/broadcast 广播交易", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isLocalRequest 判断请求是否来自本机
+//
+// 浏览器中其他网站的页面也能向本机发请求，所以带有Origin头时还要求它指向本机。
+func isLocalRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !isLoopbackHost(host) {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !isLoopbackHost(u.Hostname()) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLoopbackHost 判断主机名是否为localhost或回环地址
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
 	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
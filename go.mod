@@ -1,3 +1,10 @@
 module cosmos-demo
 
 go 1.24.3
+
+require (
+	github.com/cosmos/go-bip39 v1.0.0
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.0
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/crypto v0.38.0
+)
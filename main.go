@@ -7,7 +7,9 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 	
 	"cosmos-demo/blockchain"
 )
@@ -15,8 +17,17 @@ import (
 func main() {
 	// 定义命令行参数
 	portFlag := flag.Int("port", 8080, "Web服务器端口")
-	blockTimeFlag := flag.Int("blocktime", 10, "区块生成间隔（秒）")
+	blockTimeFlag := flag.Int("blocktime", 10, "区块生成间隔（秒），使用创世文件时以其中的block_time为准")
 	webDir := flag.String("webdir", "./web", "Web文件目录路径")
+	dataDir := flag.String("datadir", "./data", "区块数据目录（为空时不持久化）")
+	p2pAddr := flag.String("p2p", ":26656", "P2P监听地址")
+	seedsFlag := flag.String("seeds", "", "种子节点地址，多个地址用逗号分隔，如 127.0.0.1:26656")
+	consensusFlag := flag.String("consensus", "solo", "共识引擎：solo（定时出块）、pow（工作量证明）、poa（权威证明）或 bft（进程内多验证者BFT）")
+	difficultyFlag := flag.Uint("difficulty", 18, "工作量证明的初始难度（哈希前导零比特数）")
+	genesisFlag := flag.String("genesis", "", "创世文件路径（决定创世区块、初始分配和链参数，权威证明从中读取验证者集合）")
+	validatorKeyFlag := flag.String("validator-key", "", "验证者私钥文件路径（为空时随机生成；BFT共识自行管理验证者私钥，不能使用）")
+	validatorsFlag := flag.Int("validators", 4, "BFT共识在进程内运行的验证者数量")
+	localSigningFlag := flag.Bool("local-signing", false, "允许本机请求使用节点保存的私钥签名交易（转账、发行、多签和替换交易）")
 	flag.Parse()
 	
 	// 确保web目录存在
@@ -24,11 +35,93 @@ func main() {
 		log.Fatalf("Web目录不存在: %s", *webDir)
 	}
 	
-	// 创建区块链节点
-	node := blockchain.NewNode(*blockTimeFlag)
+	// 读取创世文件和验证者密钥
+	engineOpts := blockchain.EngineOptions{
+		BlockTime:  time.Duration(*blockTimeFlag) * time.Second,
+		Difficulty: uint32(*difficultyFlag),
+	}
+	var genesis *blockchain.GenesisDoc
+	if *genesisFlag != "" {
+		var err error
+		if genesis, err = blockchain.LoadGenesis(*genesisFlag); err != nil {
+			log.Fatalf("%v", err)
+		}
+		engineOpts.BlockTime = time.Duration(genesis.BlockTime) * time.Second
+		engineOpts.Validators = genesis.ValidatorKeys()
+		log.Printf("已加载创世文件: 链ID %s，哈希 %s", genesis.ChainID, genesis.Hash())
+	}
+	var validatorKey string
+	if *validatorKeyFlag != "" {
+		data, err := os.ReadFile(*validatorKeyFlag)
+		if err != nil {
+			log.Fatalf("读取验证者密钥失败: %v", err)
+		}
+		validatorKey = strings.TrimSpace(string(data))
+	}
+	
+	nodeCfg := blockchain.NodeConfig{
+		BlockTime:    int(engineOpts.BlockTime / time.Second),
+		DataDir:      *dataDir,
+		ValidatorKey: validatorKey,
+		Genesis:      genesis,
+	}
+	
+	// 创建区块链节点（从数据目录恢复已有的区块链）
+	var node *blockchain.Node
+	var bftPeers []*blockchain.Node
+	if *consensusFlag == "bft" {
+		// BFT共识：第一个验证者对外提供服务，其余验证者只在进程内运行。
+		// 所有验证者立即加入共识，创建创世区块后自动开始出块
+		bftCfg := blockchain.DefaultBFTConfig()
+		bftCfg.BlockTime = engineOpts.BlockTime
+		nodes, err := blockchain.NewBFTCluster(*validatorsFlag, nodeCfg, bftCfg)
+		if err != nil {
+			log.Fatalf("创建BFT验证者失败: %v", err)
+		}
+		node, bftPeers = nodes[0], nodes[1:]
+		for _, n := range nodes {
+			n.StartMining()
+		}
+	} else {
+		engine, err := blockchain.NewConsensusEngine(*consensusFlag, engineOpts)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		nodeCfg.Engine = engine
+		if node, err = blockchain.NewNode(nodeCfg); err != nil {
+			log.Fatalf("创建节点失败: %v", err)
+		}
+	}
+	log.Printf("共识引擎: %s，验证者公钥: %s", *consensusFlag, node.GetValidator())
+	
+	// 启动P2P网络，连接种子节点并同步缺失的区块
+	var seeds []string
+	for _, seed := range strings.Split(*seedsFlag, ",") {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			seeds = append(seeds, seed)
+		}
+	}
+	p2pServer, err := blockchain.NewP2PServer(node, blockchain.P2PConfig{
+		ListenAddr: *p2pAddr,
+		Seeds:      seeds,
+	})
+	if err != nil {
+		log.Fatalf("创建P2P网络失败: %v", err)
+	}
+	if err := p2pServer.Start(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	log.Printf("P2P网络监听在 %s，种子节点: %v", p2pServer.Addr(), seeds)
+	
+	// 已有创世区块时（恢复了已有区块链或使用了创世文件），从最新高度继续生成区块
+	if height := node.GetHeight(); height > 0 {
+		log.Printf("区块链当前高度: %d", height)
+		node.StartMining()
+	}
 	
 	// 创建Web服务器
 	webServer := blockchain.NewWebServer(node, *portFlag, *webDir)
+	webServer.SetLocalSigning(*localSigningFlag)
 	
 	// 启动Web服务器
 	go func() {
@@ -45,8 +138,12 @@ func main() {
 	<-sigCh
 	fmt.Println("\n正在关闭服务...")
 	
-	// 停止挖矿
+	// 停止挖矿和网络
 	node.StopMining()
+	for _, peer := range bftPeers {
+		peer.StopMining()
+	}
+	p2pServer.Stop()
 	
 	// 导出区块链状态
 	stateFile := "blockchain_final_state.json"
@@ -57,5 +154,12 @@ func main() {
 		log.Printf("区块链状态已保存到: %s", absPath)
 	}
 	
+	if err := node.Close(); err != nil {
+		log.Printf("关闭数据存储失败: %v", err)
+	}
+	for _, peer := range bftPeers {
+		peer.Close()
+	}
+	
 	fmt.Println("程序已安全退出")
 } 
\ No newline at end of file
@@ -17,21 +17,55 @@ function initializeApp() {
     console.log('⚙️ 初始化应用');
     updateChainStatus();
     
+    // 优先订阅节点事件，浏览器不支持时退回定时轮询
+    if (window.EventSource) {
+        subscribeEvents();
+        return;
+    }
+    
     // 每5秒更新一次状态
     setInterval(updateChainStatus, 5000);
     
     // 每10秒更新一次钱包和交易数据
-    setInterval(() => {
-        if (currentTab === 'wallet') {
-            loadWallets();
-        }
-        if (currentTab === 'transactions') {
-            loadTransactions();
-        }
-        if (currentTab === 'mining') {
-            loadMinerInfo();
-        }
-    }, 10000);
+    setInterval(refreshCurrentTab, 10000);
+}
+
+// 刷新当前标签页的数据
+function refreshCurrentTab() {
+    if (currentTab === 'wallet') {
+        loadWallets();
+    }
+    if (currentTab === 'transactions') {
+        loadTransactions();
+    }
+    if (currentTab === 'mining') {
+        loadMinerInfo();
+    }
+}
+
+// 订阅节点事件（SSE），有新区块、新交易或挖矿状态变化时刷新页面数据
+// 连接断开后浏览器会带上最后收到的事件ID自动重连，节点补发期间遗漏的事件
+function subscribeEvents() {
+    const source = new EventSource('http://localhost:8080/api/events/sse');
+    let timer = null;
+    
+    // 短时间内的多个事件合并为一次刷新
+    const refresh = () => {
+        if (timer) return;
+        timer = setTimeout(() => {
+            timer = null;
+            updateChainStatus();
+            refreshCurrentTab();
+        }, 500);
+    };
+    
+    ['new_block', 'new_tx', 'tx_failed', 'reorg', 'mining_started', 'mining_stopped'].forEach(type => {
+        source.addEventListener(type, refresh);
+    });
+    source.addEventListener('close', event => {
+        console.warn('事件订阅被节点结束:', event.data);
+    });
+    return source;
 }
 
 // 设置事件监听器
@@ -321,7 +355,7 @@ function displayBlockDetails(block) {
     const elements = {
         'block-detail-height': block.height,
         'block-detail-timestamp': new Date(block.timestamp).toLocaleString(),
-        'block-detail-data': block.data || '无数据',
+        'block-detail-data': block.data || `${(block.transactions || []).length} 笔交易`,
         'block-detail-prev-hash': block.prev_hash || '无',
         'block-detail-hash': block.hash,
         'block-detail-validator': block.validator || '未知'
@@ -339,9 +373,10 @@ function displayBlockDetails(block) {
 // 加载区块列表
 async function loadBlocks() {
     try {
-        const blocks = await apiCall('/api/blocks');
-        console.log('📦 区块列表:', blocks?.length || 0, '个区块');
-        displayBlocks(blocks);
+        // 只显示最新的一页区块
+        const page = await apiCall('/api/blocks?order=desc&limit=50');
+        console.log('📦 区块列表:', page.blocks.length, '/', page.total, '个区块');
+        displayBlocks(page.blocks);
     } catch (error) {
         console.error('加载区块失败:', error);
     }
@@ -362,12 +397,12 @@ function displayBlocks(blocks) {
         return;
     }
 
-    blocks.reverse().forEach(block => {
+    blocks.forEach(block => {
         const row = document.createElement('tr');
         row.innerHTML = `
             <td>${block.height}</td>
             <td>${new Date(block.timestamp).toLocaleString()}</td>
-            <td>${block.data || '无数据'}</td>
+            <td>${block.data || `${(block.transactions || []).length} 笔交易`}</td>
             <td title="${block.hash}">${block.hash ? block.hash.substring(0, 16) + '...' : '无'}</td>
             <td>
                 <button class="view-block-btn btn-secondary" onclick="viewBlock(${block.height})">
@@ -389,8 +424,16 @@ async function viewBlock(height) {
 
 // 创建钱包
 async function createWallet() {
+    const passphrase = prompt('请输入用于加密新钱包私钥的口令:');
+    if (!passphrase) {
+        showMessage('创建钱包需要口令', 'error');
+        return;
+    }
     try {
-        const result = await apiCall('/api/wallet/create', { method: 'POST' });
+        const result = await apiCall('/api/wallet/create', {
+            method: 'POST',
+            body: JSON.stringify({ passphrase })
+        });
         console.log('创建钱包结果:', result);
         
         if (result.wallet) {
@@ -501,10 +544,12 @@ async function submitTransfer() {
     const fromAddressSelect = document.getElementById('from-address');
     const toAddressInput = document.getElementById('to-address');
     const transferAmountInput = document.getElementById('transfer-amount');
+    const transferFeeInput = document.getElementById('transfer-fee');
     
     const fromAddress = fromAddressSelect ? fromAddressSelect.value : '';
     const toAddress = toAddressInput ? toAddressInput.value : '';
     const amount = transferAmountInput ? parseInt(transferAmountInput.value) : 0;
+    const fee = transferFeeInput && transferFeeInput.value ? parseInt(transferFeeInput.value) : 0;
 
     if (!fromAddress || !toAddress || !amount) {
         showMessage('请填写完整的转账信息', 'error');
@@ -522,14 +567,16 @@ async function submitTransfer() {
             body: JSON.stringify({
                 from: fromAddress,
                 to: toAddress,
-                amount: amount
+                amount: amount,
+                fee: fee
             })
         });
         
         console.log('转账结果:', result);
         
         if (result.transaction_id) {
-            showMessage(`转账成功! 交易ID: ${result.transaction_id}`, 'success');
+            showMessage(`转账已提交，等待打包! 交易ID: ${result.transaction_id}`, 'success');
+            waitForTransaction(result.transaction_id);
         } else {
             showMessage('转账成功!', 'success');
         }
@@ -547,6 +594,26 @@ async function submitTransfer() {
     }
 }
 
+// 等待交易上链或失败，并刷新相关数据
+async function waitForTransaction(txId) {
+    try {
+        let receipt = await apiCall(`/api/tx/wait?id=${encodeURIComponent(txId)}&timeout=60`);
+        while (receipt.status === 'pending') {
+            receipt = await apiCall(`/api/tx/wait?id=${encodeURIComponent(txId)}&timeout=60`);
+        }
+
+        if (receipt.status === 'included') {
+            showMessage(`交易已打包进区块 #${receipt.height}（第 ${receipt.index + 1} 笔）`, 'success');
+        } else {
+            showMessage(`交易失败: ${receipt.reason || '未知原因'}`, 'error');
+        }
+        loadWallets();
+        loadTransactions();
+    } catch (error) {
+        console.error('等待交易上链失败:', error);
+    }
+}
+
 // 加载矿工信息
 async function loadMinerInfo() {
     try {
@@ -583,9 +650,10 @@ function displayMinerInfo(miner) {
 // 加载交易历史
 async function loadTransactions() {
     try {
-        const txData = await apiCall('/api/wallet/transactions');
-        console.log('📋 交易历史:', txData?.length || 0, '笔交易');
-        transactions = txData || [];
+        // 只显示最新的一页交易
+        const page = await apiCall('/api/wallet/transactions?order=desc&limit=100');
+        console.log('📋 交易历史:', page.transactions.length, '/', page.total, '笔交易');
+        transactions = page.transactions;
         displayTransactions(transactions);
     } catch (error) {
         console.error('加载交易历史失败:', error);
@@ -607,7 +675,7 @@ function displayTransactions(transactions) {
         return;
     }
 
-    transactions.reverse().forEach(tx => {
+    transactions.forEach(tx => {
         const row = document.createElement('tr');
         row.innerHTML = `
             <td title="${tx.id}">${tx.id ? tx.id.substring(0, 16) + '...' : '无'}</td>
@@ -628,6 +696,7 @@ function getTransactionType(type) {
     switch(type) {
         case 'transfer': return '用户转账';
         case 'mining': return '挖矿奖励';
+        case 'genesis': return '创世分配';
         default: return '未知';
     }
 }